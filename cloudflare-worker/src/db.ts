@@ -609,3 +609,42 @@ export async function pruneOldRefererDaily(db: D1Database, cutoffDate: number):
 		.run();
 	return result.meta?.changes || 0;
 }
+
+// ============================================
+// 对等缓存节点注册表 (cache_nodes 表)
+// ============================================
+
+// CacheNode 单个节点的注册记录, last_seen 为毫秒时间戳
+export interface CacheNode {
+	node_id: string;
+	url: string;
+	last_seen: number;
+}
+
+// upsertCacheNode 写入节点心跳, 按 node_id 覆盖
+export async function upsertCacheNode(db: D1Database, node: CacheNode): Promise<void> {
+	await db
+		.prepare(
+			`INSERT INTO cache_nodes (node_id, url, last_seen)
+       VALUES (?1, ?2, ?3)
+       ON CONFLICT(node_id) DO UPDATE SET
+         url = excluded.url,
+         last_seen = excluded.last_seen`
+		)
+		.bind(node.node_id, node.url, node.last_seen)
+		.run();
+}
+
+// getCacheNodes 读取 last_seen >= since 的节点
+export async function getCacheNodes(db: D1Database, since: number): Promise<CacheNode[]> {
+	const result = await db
+		.prepare(
+			`SELECT node_id, url, last_seen
+         FROM cache_nodes
+        WHERE last_seen >= ?1
+        ORDER BY node_id`
+		)
+		.bind(since)
+		.all<CacheNode>();
+	return result.results || [];
+}
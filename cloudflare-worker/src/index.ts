@@ -331,6 +331,32 @@ export default {
 				return jsonResponse({ success: true, deleted }, 200, corsHeaders);
 			}
 
+			// ============================================
+			// Cache Nodes API (对等缓存节点注册表)
+			// ============================================
+			if (path === '/nodes' && request.method === 'POST') {
+				const body = await request.json<{ node: db.CacheNode }>();
+				if (!body.node || !body.node.node_id || !body.node.url) {
+					return jsonResponse(
+						{ error: 'Invalid request: node with node_id and url required' },
+						400,
+						corsHeaders
+					);
+				}
+				await db.upsertCacheNode(env.DB, {
+					node_id: body.node.node_id,
+					url: body.node.url,
+					last_seen: body.node.last_seen || Date.now(),
+				});
+				return jsonResponse({ success: true }, 200, corsHeaders);
+			}
+
+			if (path === '/nodes' && request.method === 'GET') {
+				const since = parseInt(url.searchParams.get('since') || '0');
+				const data = await db.getCacheNodes(env.DB, since);
+				return jsonResponse({ success: true, data }, 200, corsHeaders);
+			}
+
 			// ============================================
 			// 根路径 - API 信息
 			// ============================================
@@ -380,6 +406,10 @@ export default {
 								'POST /metrics/referer-daily': 'Batch upload node-local referer daily buckets',
 								'DELETE /metrics/referer-daily?cutoff_date': 'Prune old referer daily buckets',
 							},
+							'Cache Nodes': {
+								'GET /nodes?since': 'Get peer cache nodes seen since a timestamp (ms)',
+								'POST /nodes': 'Register or refresh a peer cache node',
+							},
 						},
 					},
 					200,
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// blobStore 内容寻址的缓存文件存储: 以 SHA-256 为文件名, 多个缓存键指向同一内容时共享同一文件。
+//
+// 每个 blob 记录引用它的缓存键数量, 只有引用归零时才删除磁盘文件;
+// 过期 / 淘汰 / 清理等流程一律通过 CacheManager.removeKey 释放引用, 不直接删除文件。
+type blobStore struct {
+	mu    sync.Mutex
+	blobs map[string]*blobEntry // hash -> blob
+}
+
+type blobEntry struct {
+	blob *blob
+	refs int
+}
+
+// blob 一份内容文件及其运行时状态, 由内容相同的缓存键共享;
+// 每个缓存键的元数据 (Content-Type / Last-Modified / 路径前缀 / 访问信息等) 各自保存在 CacheItem 中
+type blob struct {
+	hash     string
+	filePath string
+	size     int64
+
+	verifiedAt   atomic.Int64              // 上次确认文件存在的时间 (UnixNano), 见 fileAlive
+	memBody      atomic.Pointer[[]byte]    // 内存层中的内容副本, nil 表示只在磁盘
+	memRef       atomic.Bool               // 内存层 CLOCK 访问标记
+	variantState [numVariants]atomic.Int32 // 各压缩变体状态, 见 encoding.go
+}
+
+func newBlobStore() *blobStore {
+	return &blobStore{blobs: make(map[string]*blobEntry)}
+}
+
+// acquireExisting 查找已存在的 blob 并增加引用; 文件已被外部删除时丢弃该 blob 并返回 nil
+func (bs *blobStore) acquireExisting(hash string) *blob {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	entry, ok := bs.blobs[hash]
+	if !ok {
+		return nil
+	}
+	if _, err := os.Stat(entry.blob.filePath); err != nil {
+		delete(bs.blobs, hash)
+		return nil
+	}
+	entry.refs++
+	return entry.blob
+}
+
+// acquire 为新写入的文件登记 blob 并增加引用; 同一内容已被并发写入时返回已登记的 blob
+func (bs *blobStore) acquire(hash, filePath string, size int64) *blob {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if entry, ok := bs.blobs[hash]; ok {
+		entry.refs++
+		return entry.blob
+	}
+	b := &blob{hash: hash, filePath: filePath, size: size}
+	bs.blobs[hash] = &blobEntry{blob: b, refs: 1}
+	return b
+}
+
+// release 释放一个引用, 引用归零时丢弃该 blob 并删除磁盘文件 (含压缩变体); 返回 blob 是否被丢弃以及是否删除了文件
+// 只有 b 仍是该 hash 登记的 blob 时才计数 (blob 已被丢弃或重建时忽略)
+func (bs *blobStore) release(b *blob) (dropped bool, fileDeleted bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	entry, ok := bs.blobs[b.hash]
+	if !ok || entry.blob != b {
+		return false, false
+	}
+	entry.refs--
+	if entry.refs > 0 {
+		return false, false
+	}
+	delete(bs.blobs, b.hash)
+	removeVariants(b.filePath)
+	if err := os.Remove(b.filePath); err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[Cache] WARN Failed to remove file: %s", b.filePath)
+		}
+		return true, false
+	}
+	return true, true
+}
+
+// refs 返回 hash 的引用数
+func (bs *blobStore) refs(hash string) int {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if entry, ok := bs.blobs[hash]; ok {
+		return entry.refs
+	}
+	return 0
+}
+
+// owns 判断 path 是否为 hash 当前登记的 blob 文件 (启动对账用)
+func (bs *blobStore) owns(hash, path string) bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	entry, ok := bs.blobs[hash]
+	return ok && entry.blob.filePath == path
+}
+
+// variants 返回已生成变体 v 的 blob 数
+func (bs *blobStore) variants(v int) int {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	n := 0
+	for _, entry := range bs.blobs {
+		if entry.blob.variantState[v].Load() == variantReady {
+			n++
+		}
+	}
+	return n
+}
+
+// snapshot 返回当前登记的全部 blob
+func (bs *blobStore) snapshot() []*blob {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	blobs := make([]*blob, 0, len(bs.blobs))
+	for _, entry := range bs.blobs {
+		blobs = append(blobs, entry.blob)
+	}
+	return blobs
+}
+
+// reset 清空登记 (文件由调用方统一删除)
+func (bs *blobStore) reset() {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.blobs = make(map[string]*blobEntry)
+}
+
+// stats 返回 blob 数与物理占用 (每个文件只计一次)
+func (bs *blobStore) stats() (count int, physicalSize int64) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	for _, entry := range bs.blobs {
+		physicalSize += entry.blob.size
+	}
+	return len(bs.blobs), physicalSize
+}
+
+// newItem 创建指向 b 的缓存项 (b 的引用已由调用方 acquire), 文件信息取自 blob
+func newItem(b *blob) *CacheItem {
+	return &CacheItem{FilePath: b.filePath, Size: b.size, Hash: b.hash, blob: b}
+}
+
+// storeKey 把 key 指向 item (item.blob 的引用已由调用方 acquire); 覆盖旧值时释放旧 blob 的引用
+func (cm *CacheManager) storeKey(key CacheKey, item *CacheItem) {
+	cm.statuses.Delete(key)
+	cm.purged.Delete(key)
+	if old, loaded := cm.items.Swap(key, item); loaded {
+		cm.lruCache.Delete(key)
+		cm.releaseBlob(old.(*CacheItem).blob)
+	}
+}
+
+// releaseBlob 释放 blob 的一个引用, blob 被丢弃时同时移出内存层; 返回是否删除了磁盘文件
+func (cm *CacheManager) releaseBlob(b *blob) bool {
+	dropped, fileDeleted := cm.blobs.release(b)
+	if dropped {
+		cm.memory.remove(b)
+	}
+	return fileDeleted
+}
+
+// removeKey 删除缓存键并释放其 blob 引用; 返回 key 是否存在以及是否删除了磁盘文件
+func (cm *CacheManager) removeKey(key CacheKey) (removed bool, fileDeleted bool) {
+	value, ok := cm.items.LoadAndDelete(key)
+	cm.lruCache.Delete(key)
+	cm.purged.Delete(key)
+	if !ok {
+		return false, false
+	}
+	return true, cm.releaseBlob(value.(*CacheItem).blob)
+}
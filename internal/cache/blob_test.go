@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"proxy-go/internal/config"
+	"testing"
+	"time"
+)
+
+func TestBlobStoreRefCounting(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+
+	put := func(target, body string) (CacheKey, *CacheItem) {
+		req := httptest.NewRequest("GET", target, nil)
+		key := cm.GenerateCacheKey(req, false)
+		item, err := cm.Put(key, &http.Response{StatusCode: 200, Header: make(http.Header), Request: req}, []byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, item
+	}
+
+	keyA, shared := put("/a/logo.png", "same-bytes")
+	keyB, _ := put("/b/logo.png", "same-bytes")
+	put("/c/other.png", "other")
+
+	stats := cm.GetStats()
+	if stats.TotalSize != 25 || stats.PhysicalSize != 15 || stats.BlobCount != 2 || stats.DedupSavedBytes != 10 {
+		t.Fatalf("stats = %+v", stats)
+	}
+
+	// 删除其中一个键, 共享文件仍被另一个键引用
+	if n, _ := cm.ClearCacheByPrefix("/a", PurgeOptions{}); n != 1 {
+		t.Fatalf("cleared %d, want 1", n)
+	}
+	if _, err := os.Stat(shared.FilePath); err != nil {
+		t.Fatalf("shared blob removed while still referenced: %v", err)
+	}
+	if _, found, _ := cm.Get(keyB, httptest.NewRequest("GET", "/b/logo.png", nil), false); !found {
+		t.Fatal("/b/logo.png should still hit")
+	}
+
+	// 覆盖写入新内容后旧 blob 不再被引用, 文件被删除
+	put("/b/logo.png", "new-bytes")
+	if _, err := os.Stat(shared.FilePath); !os.IsNotExist(err) {
+		t.Fatalf("unreferenced blob should be removed, stat err = %v", err)
+	}
+	if _, ok := cm.items.Load(keyA); ok {
+		t.Fatal("/a/logo.png should be gone")
+	}
+	if stats := cm.GetStats(); stats.BlobCount != 2 || stats.PhysicalSize != stats.TotalSize {
+		t.Fatalf("stats after overwrite = %+v", stats)
+	}
+}
+
+func TestSharedBlobKeepsPerKeyMetadata(t *testing.T) {
+	dir := t.TempDir()
+	pathMap := map[string]config.PathConfig{
+		"/img":  {CacheConfig: &config.CacheConfig{MaxAge: 120}},
+		"/docs": {},
+	}
+	cm, err := NewCacheManager(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.SetKeyPolicies(pathMap)
+
+	put := func(target, contentType string, lastModified time.Time) (CacheKey, *CacheItem) {
+		req := httptest.NewRequest("GET", target, nil)
+		key := cm.GenerateCacheKey(req, false)
+		header := make(http.Header)
+		header.Set("Content-Type", contentType)
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+		item, err := cm.Put(key, &http.Response{StatusCode: 200, Header: header, Request: req}, []byte("same-bytes"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, item
+	}
+
+	imgTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	docTime := time.Date(2025, 6, 7, 8, 9, 10, 0, time.UTC)
+	imgKey, img := put("/img/a.bin", "image/png", imgTime)
+	docKey, doc := put("/docs/a.bin", "text/plain", docTime)
+
+	check := func(stage string, img, doc *CacheItem) {
+		t.Helper()
+		if img == doc || img.FilePath != doc.FilePath {
+			t.Fatalf("%s: keys should be distinct items sharing one file", stage)
+		}
+		if img.ContentType != "image/png" || !img.LastModified.Equal(imgTime) || img.PathPrefix != "/img" {
+			t.Fatalf("%s: /img item = %s %v %q", stage, img.ContentType, img.LastModified, img.PathPrefix)
+		}
+		if doc.ContentType != "text/plain" || !doc.LastModified.Equal(docTime) || doc.PathPrefix != "/docs" {
+			t.Fatalf("%s: /docs item = %s %v %q", stage, doc.ContentType, doc.LastModified, doc.PathPrefix)
+		}
+	}
+	check("put", img, doc)
+	if got := cm.blobs.refs(img.Hash); got != 2 {
+		t.Fatalf("blob refs = %d, want 2", got)
+	}
+
+	if err := cm.SaveIndex(); err != nil {
+		t.Fatal(err)
+	}
+	cm.Stop()
+
+	restored, err := NewCacheManager(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(restored.Stop)
+	restored.SetKeyPolicies(pathMap)
+	img, _, _ = restored.Get(imgKey, httptest.NewRequest("GET", "/img/a.bin", nil), false)
+	doc, _, _ = restored.Get(docKey, httptest.NewRequest("GET", "/docs/a.bin", nil), false)
+	if img == nil || doc == nil {
+		t.Fatal("both keys should survive a restart")
+	}
+	check("restore", img, doc)
+}
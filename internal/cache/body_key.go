@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"proxy-go/internal/config"
+)
+
+// BodyKey 返回 POST / QUERY 请求缓存键的请求体维度 (CacheKey.Body): 方法 + 请求体 SHA-256。
+// 配置了 CanonicalJSON 或 IgnoreFields 时先规范化 JSON 请求体, 键顺序、空白与被忽略字段不影响缓存键;
+// 请求体不是合法 JSON 时按原始字节计算
+func BodyKey(method string, body []byte, cfg *config.BodyCacheConfig) string {
+	if cfg != nil && (cfg.CanonicalJSON || len(cfg.IgnoreFields) > 0) {
+		if canonical, ok := canonicalJSON(body, cfg.IgnoreFields); ok {
+			body = canonical
+		}
+	}
+	sum := sha256.Sum256(body)
+	return strings.ToUpper(method) + ":" + hex.EncodeToString(sum[:])
+}
+
+// canonicalJSON 解析 JSON 并删除 ignore 中的字段后重新编码 (对象键按字典序, 无多余空白, 数字保持原样)
+func canonicalJSON(body []byte, ignore []string) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return nil, false
+	}
+	for _, field := range ignore {
+		if field = strings.TrimSpace(field); field != "" {
+			deleteJSONField(v, strings.Split(field, "."))
+		}
+	}
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	return canonical, true
+}
+
+// deleteJSONField 按路径删除字段; 路径经过数组时作用于数组的每个元素 (如 GraphQL 批量请求)
+func deleteJSONField(v interface{}, path []string) {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(node, path[0])
+			return
+		}
+		if child, ok := node[path[0]]; ok {
+			deleteJSONField(child, path[1:])
+		}
+	case []interface{}:
+		for _, elem := range node {
+			deleteJSONField(elem, path)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"testing"
+
+	"proxy-go/internal/config"
+)
+
+func TestBodyKey(t *testing.T) {
+	canonical := &config.BodyCacheConfig{CanonicalJSON: true, IgnoreFields: []string{"requestId", "extensions.trace"}}
+	base := BodyKey("POST", []byte(`{"query":"{ a }","variables":{"id":1,"n":2}}`), canonical)
+
+	same := []string{
+		`{"variables":{"n":2,"id":1},"query":"{ a }"}`,
+		"{\n  \"query\": \"{ a }\",\n  \"variables\": {\"id\": 1, \"n\": 2},\n  \"requestId\": \"r-9\"\n}",
+	}
+	for _, body := range same {
+		if got := BodyKey("post", []byte(body), canonical); got != base {
+			t.Errorf("BodyKey(%s) = %s, want %s", body, got, base)
+		}
+	}
+
+	different := []string{
+		`{"query":"{ a }","variables":{"id":2,"n":2}}`,
+		`{"query":"{ a }","variables":{"id":1.0,"n":2}}`,
+		`{"query":"{ a }","variables":{"id":1,"n":2},"extensions":{"other":1}}`,
+	}
+	for _, body := range different {
+		if got := BodyKey("POST", []byte(body), canonical); got == base {
+			t.Errorf("BodyKey(%s) should differ", body)
+		}
+	}
+	traced := func(trace string) string {
+		return BodyKey("POST", []byte(`{"query":"{ a }","extensions":{"trace":"`+trace+`"}}`), canonical)
+	}
+	if traced("t1") != traced("t2") {
+		t.Error("nested ignored field should not affect the key")
+	}
+	if BodyKey("QUERY", []byte(`{"query":"{ a }","variables":{"id":1,"n":2}}`), canonical) == base {
+		t.Error("method should be part of the key")
+	}
+
+	// 未开启规范化时按原始字节; 非 JSON 请求体同样按原始字节
+	if BodyKey("POST", []byte(`{"a":1,"b":2}`), nil) == BodyKey("POST", []byte(`{"b":2,"a":1}`), nil) {
+		t.Error("raw bodies with different bytes should differ")
+	}
+	if BodyKey("POST", []byte("q=1&x=2"), canonical) != BodyKey("POST", []byte("q=1&x=2"), nil) {
+		t.Error("non-JSON body should be hashed as is")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package cache
+
+import "os"
+
+// fileDiskUsage 无法读取块信息的平台按 4KB 块估算
+func fileDiskUsage(info os.FileInfo) int64 {
+	return roundToBlock(info.Size())
+}
+
+// diskFreeSpace 不支持的平台返回 false, 可用空间下限不生效
+func diskFreeSpace(dir string) (int64, bool) {
+	return 0, false
+}
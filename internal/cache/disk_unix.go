@@ -0,0 +1,25 @@
+//go:build unix
+
+package cache
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileDiskUsage 返回文件实际占用的磁盘空间 (按已分配块计)
+func fileDiskUsage(info os.FileInfo) int64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int64(st.Blocks) * 512
+	}
+	return roundToBlock(info.Size())
+}
+
+// diskFreeSpace 返回 dir 所在文件系统对非特权用户可用的空间
+func diskFreeSpace(dir string) (int64, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, false
+	}
+	return int64(st.Bavail) * int64(st.Bsize), true
+}
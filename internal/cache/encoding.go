@@ -0,0 +1,387 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// 内容编码: 缓存只保存未压缩 (identity) 内容, 可压缩的对象在首次被支持 br / gzip 的客户端命中时
+// 在后台生成对应的压缩变体 (<blob>.br / <blob>.gz, 与 blob 同生命周期), 响应时按 Accept-Encoding 的偏好选择;
+// 旧版本缓存的 gzip 内容对不支持 gzip 的客户端即时解压。
+const (
+	gzipVariantSuffix = ".gz"
+	brVariantSuffix   = ".br"
+	brVariantLevel    = 5        // brotli 压缩级别: 压缩率接近 gzip -9 的同时保持较快的生成速度
+	variantMinSize    = 1 << 10  // 小于 1KB 的内容压缩收益不足以抵消开销
+	variantMaxSize    = 64 << 20 // 超过 64MB 的内容不生成变体, 避免长时间占用 CPU
+	variantMinSaving  = 10       // 压缩后至少节省 10% 才保留变体 (百分比)
+	variantConcurrent = 2        // 同时生成变体的最大数量
+)
+
+// 压缩变体下标 (blob.variantState); 顺序即 q 值相同时的偏好, br 压缩率更高排在前面
+const (
+	variantBr = iota
+	variantGzip
+	numVariants
+)
+
+// variantCodec 一种压缩变体的编码名、文件后缀与编码器
+type variantCodec struct {
+	encoding  string
+	suffix    string
+	newWriter func(io.Writer) io.WriteCloser
+}
+
+var variantCodecs = [numVariants]variantCodec{
+	variantBr: {"br", brVariantSuffix, func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, brVariantLevel)
+	}},
+	variantGzip: {"gzip", gzipVariantSuffix, func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	}},
+}
+
+// 变体状态 (blob.variantState)
+const (
+	variantUnknown  int32 = iota // 尚未尝试
+	variantBuilding              // 后台生成中
+	variantReady                 // 变体文件可用
+	variantNone                  // 不适合压缩或压缩收益不足
+)
+
+// ErrEncodingUnsupported 缓存内容的编码客户端不接受且无法即时解码, 调用方应使缓存失效后回源
+var ErrEncodingUnsupported = errors.New("cached content encoding not acceptable")
+
+// Content 按客户端 Accept-Encoding 选出的缓存内容表示
+type Content struct {
+	Body     io.Reader // 磁盘文件与内存副本可 Seek (支持 Range), 即时解压的内容不可 Seek
+	Encoding string    // Content-Encoding, 空串表示未压缩
+	ETag     string    // 该表示的强校验值, 不同编码的表示互不相同
+	vary     bool
+	closers  []io.Closer
+}
+
+// Close 释放内容占用的文件与解压器
+func (c *Content) Close() error {
+	var first error
+	for i := len(c.closers) - 1; i >= 0; i-- {
+		if err := c.closers[i].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// SetHeaders 设置 ETag / Content-Encoding / Vary 响应头, 应在 CacheItem.SetValidators 之后调用
+func (c *Content) SetHeaders(h http.Header) {
+	h.Set("ETag", c.ETag)
+	if c.Encoding != "" {
+		h.Set("Content-Encoding", c.Encoding)
+	} else {
+		h.Del("Content-Encoding")
+	}
+	if c.vary {
+		AddVary(h, "Accept-Encoding")
+	}
+}
+
+// OpenContent 按 Accept-Encoding 打开 item 的内容:
+//   - 未压缩内容: 返回客户端接受的变体中 q 值最高且已生成的一个 (同为最高时优先 br), 否则返回原内容
+//     (并在后台生成客户端接受的变体)
+//   - 已压缩内容: 客户端接受该编码时原样返回; gzip 内容对不接受 gzip 的客户端即时解压
+//   - 其它编码客户端不接受时返回 ErrEncodingUnsupported
+//
+// 文件打开失败时返回对应错误 (文件已被删除)
+func (cm *CacheManager) OpenContent(item *CacheItem, acceptEncoding string) (*Content, error) {
+	stored := strings.ToLower(strings.TrimSpace(item.ContentEncoding))
+	if stored == "" || stored == "identity" {
+		vary := Compressible(item.ContentType)
+		if vary {
+			for _, v := range preferredVariants(acceptEncoding) {
+				if !cm.variantReady(item, v) {
+					continue
+				}
+				codec := variantCodecs[v]
+				if file, err := os.Open(item.FilePath + codec.suffix); err == nil {
+					return &Content{Body: file, Encoding: codec.encoding, ETag: variantETag(item, codec.encoding), vary: true, closers: []io.Closer{file}}, nil
+				}
+				// 变体文件已被删除, 下次命中时重新生成
+				item.blob.variantState[v].CompareAndSwap(variantReady, variantUnknown)
+			}
+		}
+		body, closer, err := cm.openBlob(item)
+		if err != nil {
+			return nil, err
+		}
+		return &Content{Body: body, ETag: item.ETag(), vary: vary, closers: closer}, nil
+	}
+
+	if AcceptsEncoding(acceptEncoding, stored) {
+		body, closer, err := cm.openBlob(item)
+		if err != nil {
+			return nil, err
+		}
+		return &Content{Body: body, Encoding: item.ContentEncoding, ETag: item.ETag(), vary: true, closers: closer}, nil
+	}
+	if stored != "gzip" && stored != "x-gzip" {
+		return nil, ErrEncodingUnsupported
+	}
+
+	body, closer, err := cm.openBlob(item)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		for _, c := range closer {
+			c.Close()
+		}
+		return nil, ErrEncodingUnsupported
+	}
+	return &Content{Body: zr, ETag: variantETag(item, "identity"), vary: true, closers: append(closer, zr)}, nil
+}
+
+// openBlob 打开缓存内容: 内存层中的小对象直接使用内存副本, 否则打开磁盘文件
+func (cm *CacheManager) openBlob(item *CacheItem) (io.ReadSeeker, []io.Closer, error) {
+	if body, ok := cm.MemoryBody(item); ok {
+		return bytes.NewReader(body), nil, nil
+	}
+	file, err := os.Open(item.FilePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, []io.Closer{file}, nil
+}
+
+// preferredVariants 返回客户端愿意接收的压缩变体 (见 PrefersEncoding), 按 q 值从高到低排列 (q 值相同时按 variantCodecs 顺序)
+func preferredVariants(acceptEncoding string) []int {
+	var qs [numVariants]float64
+	variants := make([]int, 0, numVariants)
+	for v, codec := range variantCodecs {
+		if PrefersEncoding(acceptEncoding, codec.encoding) {
+			qs[v] = encodingQ(acceptEncoding, codec.encoding)
+			variants = append(variants, v)
+		}
+	}
+	sort.SliceStable(variants, func(i, j int) bool {
+		return qs[variants[i]] > qs[variants[j]]
+	})
+	return variants
+}
+
+// variantReady 返回变体 v 是否可用; 尚未尝试且内容适合压缩时在后台生成 (本次仍返回 false)
+func (cm *CacheManager) variantReady(item *CacheItem, v int) bool {
+	state := &item.blob.variantState[v]
+	switch state.Load() {
+	case variantReady:
+		return true
+	case variantUnknown:
+	default:
+		return false
+	}
+	if item.Size < variantMinSize || item.Size > variantMaxSize {
+		state.Store(variantNone)
+		return false
+	}
+	if !state.CompareAndSwap(variantUnknown, variantBuilding) {
+		return false
+	}
+	select {
+	case cm.variantSem <- struct{}{}:
+		go func() {
+			defer func() { <-cm.variantSem }()
+			state.Store(cm.buildVariant(item.blob, v))
+		}()
+	default:
+		// 生成并发已满, 留给之后的命中
+		state.Store(variantUnknown)
+	}
+	return false
+}
+
+// buildVariant 生成 b 的变体 v 的文件, 返回新的变体状态
+func (cm *CacheManager) buildVariant(b *blob, v int) int32 {
+	codec := variantCodecs[v]
+	path := b.filePath + codec.suffix
+	if _, err := os.Stat(path); err == nil {
+		return variantReady // 重启前已生成
+	}
+
+	src, err := os.Open(b.filePath)
+	if err != nil {
+		return variantUnknown
+	}
+	defer src.Close()
+	tmp, err := os.CreateTemp(cm.tempDir(), "temp-"+codec.encoding+"-*")
+	if err != nil {
+		log.Printf("[Cache] ERR Failed to create %s variant: %v", codec.encoding, err)
+		return variantUnknown
+	}
+	tmpPath := tmp.Name()
+
+	zw := codec.newWriter(tmp)
+	buf := GetBuffer(32 * 1024)
+	_, err = io.CopyBuffer(zw, src, buf)
+	PutBuffer(buf)
+	if err == nil {
+		err = zw.Close()
+	}
+	var size int64
+	if err == nil {
+		var info os.FileInfo
+		if info, err = tmp.Stat(); err == nil {
+			size = info.Size()
+		}
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		log.Printf("[Cache] ERR Failed to create %s variant for %s: %v", codec.encoding, b.hash, err)
+		return variantUnknown
+	}
+	if size*100 > b.size*(100-variantMinSaving) {
+		os.Remove(tmpPath)
+		return variantNone
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		log.Printf("[Cache] ERR Failed to create %s variant for %s: %v", codec.encoding, b.hash, err)
+		return variantUnknown
+	}
+	// 生成期间 blob 已被释放时, 释放流程可能没有看到变体文件
+	if !cm.blobs.owns(b.hash, b.filePath) {
+		os.Remove(path)
+		return variantNone
+	}
+	cm.noteNewBlob(size, false)
+	return variantReady
+}
+
+// removeVariants 删除 blob 的全部变体文件
+func removeVariants(filePath string) {
+	for _, codec := range variantCodecs {
+		os.Remove(filePath + codec.suffix)
+	}
+}
+
+// variantBlobName 去掉变体文件名的后缀, 返回所属 blob 的文件名; 不是变体文件时原样返回
+func variantBlobName(name string) string {
+	for _, codec := range variantCodecs {
+		if base, ok := strings.CutSuffix(name, codec.suffix); ok {
+			return base
+		}
+	}
+	return name
+}
+
+// variantETag 返回 item 某一编码表示的 ETag
+func variantETag(item *CacheItem, encoding string) string {
+	return `"` + item.Hash + "-" + encoding + `"`
+}
+
+// Compressible 判断内容类型是否适合压缩 (文本类; 图片 / 视频 / 压缩包等已压缩的格式不再压缩)
+func Compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/javascript", "application/x-javascript", "application/ecmascript",
+		"application/json", "application/manifest+json", "application/ld+json",
+		"application/xml", "application/xhtml+xml", "application/rss+xml", "application/atom+xml",
+		"application/wasm", "application/x-font-ttf", "application/vnd.ms-fontobject",
+		"font/ttf", "font/otf", "image/svg+xml", "image/x-icon", "image/vnd.microsoft.icon", "image/bmp":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// AcceptsEncoding 按 Accept-Encoding (含 q 值) 判断客户端是否接受 coding;
+// 没有 Accept-Encoding 时只接受未压缩内容, identity 除非被显式排除总是可接受
+func AcceptsEncoding(header, coding string) bool {
+	return encodingQ(header, coding) > 0
+}
+
+// PrefersEncoding 判断客户端是否愿意接收 coding 编码的内容: 接受该编码, 且显式给出 identity (或 *) 的 q 值时
+// 不低于它 (如 "gzip;q=0.1, identity" 更想要未压缩内容)
+func PrefersEncoding(header, coding string) bool {
+	q := encodingQ(header, coding)
+	if identity, listed := listedQ(header, "identity"); listed && q < identity {
+		return false
+	}
+	return q > 0
+}
+
+// encodingQ 返回 Accept-Encoding 中 coding 的 q 值, 0 表示不接受
+func encodingQ(header, coding string) float64 {
+	if q, listed := listedQ(header, coding); listed {
+		return q
+	}
+	if coding == "" || strings.EqualFold(coding, "identity") {
+		return 1
+	}
+	return 0
+}
+
+// listedQ 返回 Accept-Encoding 中为 coding 列出的 q 值 (含 * 通配); listed 为 false 表示未列出
+func listedQ(header, coding string) (float64, bool) {
+	coding = strings.ToLower(coding)
+	if coding == "" {
+		coding = "identity"
+	}
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		switch {
+		case name == coding || (coding == "gzip" && name == "x-gzip"):
+			return max(q, 0), true
+		case name == "*":
+			wildcard = q
+		}
+	}
+	if wildcard >= 0 {
+		return wildcard, true
+	}
+	return 0, false
+}
+
+// AddVary 向 Vary 响应头追加 value (已包含时不重复)
+func AddVary(h http.Header, value string) {
+	for _, existing := range h.Values("Vary") {
+		for _, v := range strings.Split(existing, ",") {
+			if v = strings.TrimSpace(v); v == "*" || strings.EqualFold(v, value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := []struct {
+		header, coding string
+		want           bool
+	}{
+		{"", "gzip", false},
+		{"", "identity", true},
+		{"gzip, deflate, br", "gzip", true},
+		{"br", "gzip", false},
+		{"GZIP;q=0.5", "gzip", true},
+		{"gzip;q=0", "gzip", false},
+		{"x-gzip", "gzip", true},
+		{"*", "gzip", true},
+		{"*;q=0", "gzip", false},
+		{"gzip, *;q=0", "identity", false},
+		{"identity;q=0, gzip", "identity", false},
+		{"br", "identity", true},
+	}
+	for _, tt := range tests {
+		if got := AcceptsEncoding(tt.header, tt.coding); got != tt.want {
+			t.Errorf("AcceptsEncoding(%q, %q) = %v, want %v", tt.header, tt.coding, got, tt.want)
+		}
+	}
+}
+
+func TestPreferredVariants(t *testing.T) {
+	tests := map[string][]int{
+		"":                               {},
+		"gzip, deflate, br":              {variantBr, variantGzip},
+		"gzip;q=1, br;q=0.5":             {variantGzip, variantBr},
+		"br;q=0, gzip":                   {variantGzip},
+		"*":                              {variantBr, variantGzip},
+		"identity;q=.5, x-gzip;q=.8":     {variantGzip},
+		"gzip;q=0.1, identity":           {},
+		"br;q=0.5, gzip, identity;q=0.8": {variantGzip},
+	}
+	for header, want := range tests {
+		if got := preferredVariants(header); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("preferredVariants(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+// putEncoded 写入 body 并返回缓存项
+func putEncoded(t *testing.T, cm *CacheManager, path, contentType, encoding string, body []byte) *CacheItem {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	header := http.Header{"Content-Type": {contentType}}
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	item, err := cm.Put(cm.GenerateCacheKey(req, false), &http.Response{StatusCode: 200, Header: header, Request: req}, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
+
+func readContent(t *testing.T, c *Content) []byte {
+	t.Helper()
+	defer c.Close()
+	body, err := io.ReadAll(c.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestOpenContentVariants(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+
+	body := []byte(strings.Repeat("console.log('hello');\n", 200))
+	item := putEncoded(t, cm, "/app.js", "application/javascript", "", body)
+
+	// 首次命中返回未压缩内容并在后台生成客户端接受的变体
+	c, err := cm.OpenContent(item, "gzip, br")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Encoding != "" || !bytes.Equal(readContent(t, c), body) {
+		t.Fatalf("first hit encoding = %q, want identity", c.Encoding)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for item.blob.variantState[variantGzip].Load() != variantReady || item.blob.variantState[variantBr].Load() != variantReady {
+		if time.Now().After(deadline) {
+			t.Fatalf("variant states = %d / %d", item.blob.variantState[variantGzip].Load(), item.blob.variantState[variantBr].Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"br": func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"gzip": func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+	}
+	// 两种变体都可用时按 q 值选择, q 值相同时优先 br
+	for ae, want := range map[string]string{"gzip, br": "br", "gzip;q=1, br;q=0.5": "gzip", "gzip": "gzip"} {
+		c, err = cm.OpenContent(item, ae)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := http.Header{}
+		c.SetHeaders(h)
+		if h.Get("Content-Encoding") != want || h.Get("Vary") != "Accept-Encoding" || h.Get("ETag") != variantETag(item, want) {
+			t.Fatalf("%q: variant headers = %v", ae, h)
+		}
+		zr, err := decoders[want](bytes.NewReader(readContent(t, c)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := io.ReadAll(zr); !bytes.Equal(got, body) {
+			t.Fatalf("%s variant does not decode to the cached body", want)
+		}
+	}
+
+	// 不接受压缩或更偏好未压缩内容的客户端拿到未压缩内容; 各表示的 ETag 都能用于条件请求
+	for _, ae := range []string{"", "gzip;q=0.1, identity"} {
+		c, err = cm.OpenContent(item, ae)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Encoding != "" || c.ETag != item.ETag() || !bytes.Equal(readContent(t, c), body) {
+			t.Fatalf("%q: client should get the uncompressed body", ae)
+		}
+	}
+	r := httptest.NewRequest("GET", "/app.js", nil)
+	for _, encoding := range []string{"gzip", "br"} {
+		r.Header.Set("If-None-Match", variantETag(item, encoding))
+		if !item.NotModified(r) {
+			t.Fatalf("%s variant ETag should validate", encoding)
+		}
+	}
+
+	// 释放 blob 时一并删除变体文件
+	if stats := cm.GetStats(); stats.GzipVariants != 1 || stats.BrVariants != 1 {
+		t.Fatalf("variants = %d gzip / %d br, want 1 / 1", stats.GzipVariants, stats.BrVariants)
+	}
+	cm.InvalidateCacheItem(cm.GenerateCacheKey(r, false))
+	for _, suffix := range []string{gzipVariantSuffix, brVariantSuffix} {
+		if _, err := os.Stat(item.FilePath + suffix); !os.IsNotExist(err) {
+			t.Fatalf("%s variant not removed: %v", suffix, err)
+		}
+	}
+}
+
+func TestOpenContentSkipsIncompressible(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+
+	small := putEncoded(t, cm, "/small.js", "application/javascript", "", []byte("x=1"))
+	image := putEncoded(t, cm, "/a.png", "image/png", "", bytes.Repeat([]byte{0x89}, 4096))
+	for _, item := range []*CacheItem{small, image} {
+		c, err := cm.OpenContent(item, "gzip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := http.Header{}
+		c.SetHeaders(h)
+		c.Close()
+		if c.Encoding != "" {
+			t.Fatalf("%s: encoding = %q", item.ContentType, c.Encoding)
+		}
+		for v := range item.blob.variantState {
+			if state := item.blob.variantState[v].Load(); state == variantBuilding || state == variantReady {
+				t.Fatalf("%s: variant state = %d", item.ContentType, state)
+			}
+		}
+		if vary := h.Get("Vary"); (item == image) != (vary == "") {
+			t.Fatalf("%s: Vary = %q", item.ContentType, vary)
+		}
+	}
+}
+
+func TestOpenContentDecompressesStoredGzip(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+
+	body := []byte(strings.Repeat("{\"a\":1}", 100))
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write(body)
+	zw.Close()
+	item := putEncoded(t, cm, "/data.json", "application/json", "gzip", compressed.Bytes())
+
+	// 接受 gzip 时原样返回
+	c, err := cm.OpenContent(item, "gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Encoding != "gzip" || !bytes.Equal(readContent(t, c), compressed.Bytes()) {
+		t.Fatal("gzip client should get the stored gzip body")
+	}
+
+	// 不接受任何编码时即时解压
+	c, err = cm.OpenContent(item, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, seekable := c.Body.(io.Seeker); seekable || c.Encoding != "" {
+		t.Fatalf("decoded content: encoding = %q, seekable = %v", c.Encoding, seekable)
+	}
+	if !bytes.Equal(readContent(t, c), body) {
+		t.Fatal("decoded body mismatch")
+	}
+
+	// 无法解码的编码交给调用方回源
+	br := putEncoded(t, cm, "/data.br", "application/json", "br", []byte("not really brotli"))
+	if _, err := cm.OpenContent(br, "gzip"); !errors.Is(err, ErrEncodingUnsupported) {
+		t.Fatalf("err = %v, want ErrEncodingUnsupported", err)
+	}
+	if c, err := cm.OpenContent(br, "br"); err != nil || c.Encoding != "br" {
+		t.Fatalf("br client: err = %v", err)
+	} else {
+		c.Close()
+	}
+}
@@ -0,0 +1,344 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"path/filepath"
+	"proxy-go/internal/config"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultHighWatermark = 95
+	defaultLowWatermark  = 85
+	diskBlockSize        = 4096 // 无法读取块信息时的估算粒度
+	admissionSample      = 8    // TinyLFU 准入时抽样比较的现有缓存项数量
+
+	// diskMeasureInterval 两次遍历缓存目录测量占用的最小间隔, 也是收缩无法回到水位以下时的冷却时间
+	diskMeasureInterval = 30 * time.Second
+)
+
+// ErrNotAdmitted 新对象未通过 TinyLFU 准入, 不写入缓存
+var ErrNotAdmitted = errors.New("object rejected by cache admission policy")
+
+// evictionConfig 淘汰策略与磁盘水位, 热更新时整体替换
+type evictionConfig struct {
+	policy  string
+	high    int   // 高水位 (max_cache_size 的百分比)
+	low     int   // 低水位
+	minFree int64 // 磁盘可用空间下限 (字节), 0 表示不检查
+}
+
+// newEvictionConfig 校验淘汰配置并补全默认值
+func newEvictionConfig(cfg *config.CacheConfig) (*evictionConfig, error) {
+	ev := &evictionConfig{policy: config.EvictionLRU, high: defaultHighWatermark, low: defaultLowWatermark}
+	if cfg == nil {
+		return ev, nil
+	}
+	policy, err := normalizeEvictionPolicy(cfg.EvictionPolicy)
+	if err != nil {
+		return nil, err
+	}
+	ev.policy = policy
+	if cfg.HighWatermark != 0 {
+		ev.high = cfg.HighWatermark
+	}
+	if cfg.LowWatermark != 0 {
+		ev.low = cfg.LowWatermark
+	}
+	if ev.high < 1 || ev.high > 100 || ev.low < 1 || ev.low >= ev.high {
+		return nil, fmt.Errorf("invalid watermarks: require 0 < low_watermark < high_watermark <= 100")
+	}
+	if cfg.MinFreeSpace < 0 {
+		return nil, fmt.Errorf("min_free_space must not be negative")
+	}
+	ev.minFree = cfg.MinFreeSpace * 1024 * 1024
+	return ev, nil
+}
+
+// normalizeEvictionPolicy 统一策略名大小写, 空值为 lru
+func normalizeEvictionPolicy(policy string) (string, error) {
+	switch p := strings.ToLower(strings.TrimSpace(policy)); p {
+	case "":
+		return config.EvictionLRU, nil
+	case config.EvictionLRU, config.EvictionLFU, config.EvictionGreedyDual, config.EvictionTinyLFU:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown eviction policy: %s", policy)
+	}
+}
+
+// setEviction 替换淘汰配置; 首次启用 tinylfu 时创建频率草图
+func (cm *CacheManager) setEviction(ev *evictionConfig) {
+	if ev.policy == config.EvictionTinyLFU && cm.sketch.Load() == nil {
+		cm.sketch.CompareAndSwap(nil, newFrequencySketch())
+	}
+	cm.eviction.Store(ev)
+}
+
+func (cm *CacheManager) evictionConfig() *evictionConfig {
+	if ev := cm.eviction.Load(); ev != nil {
+		return ev
+	}
+	return &evictionConfig{policy: config.EvictionLRU, high: defaultHighWatermark, low: defaultLowWatermark}
+}
+
+// poolEvictionPolicy 独立路径可单独指定淘汰策略, 未指定或无效时沿用全局策略
+func (cm *CacheManager) poolEvictionPolicy(policy *pathPolicy) string {
+	if policy != nil && policy.cache.EvictionPolicy != "" {
+		if p, err := normalizeEvictionPolicy(policy.cache.EvictionPolicy); err == nil {
+			return p
+		}
+	}
+	return cm.evictionConfig().policy
+}
+
+// recordEvictionAccess 为淘汰策略记录一次访问: tinylfu 累计频率 (含未命中), greedydual 命中时刷新优先级
+func (cm *CacheManager) recordEvictionAccess(key CacheKey, item *CacheItem) {
+	switch cm.evictionConfig().policy {
+	case config.EvictionTinyLFU:
+		if s := cm.sketch.Load(); s != nil {
+			s.increment(key)
+		}
+	case config.EvictionGreedyDual:
+		if item != nil {
+			item.gdPriority.Store(math.Float64bits(cm.greedyDualValue(item)))
+		}
+	}
+}
+
+// greedyDualValue GDSF 优先级 H = L + 访问次数 / 大小, L 为最近一次淘汰项的优先级 (随淘汰单调上升)
+func (cm *CacheManager) greedyDualValue(item *CacheItem) float64 {
+	clock := math.Float64frombits(cm.gdClock.Load())
+	return clock + float64(atomic.LoadInt64(&item.AccessCount))/float64(max(item.Size, 1))
+}
+
+// raiseGreedyDualClock 淘汰时把 L 提升到被淘汰项的优先级
+func (cm *CacheManager) raiseGreedyDualClock(priority float64) {
+	for {
+		old := cm.gdClock.Load()
+		if priority <= math.Float64frombits(old) || cm.gdClock.CompareAndSwap(old, math.Float64bits(priority)) {
+			return
+		}
+	}
+}
+
+// evictionOrder 按淘汰优先级排序, 分值相同按最后访问时间, 越靠前越先淘汰
+type evictionOrder struct {
+	entries []cleanupEntry
+	scores  []float64
+}
+
+func (o evictionOrder) Len() int { return len(o.entries) }
+func (o evictionOrder) Less(i, j int) bool {
+	if o.scores[i] != o.scores[j] {
+		return o.scores[i] < o.scores[j]
+	}
+	return o.entries[i].item.lastAccess.Load() < o.entries[j].item.lastAccess.Load()
+}
+func (o evictionOrder) Swap(i, j int) {
+	o.entries[i], o.entries[j] = o.entries[j], o.entries[i]
+	o.scores[i], o.scores[j] = o.scores[j], o.scores[i]
+}
+
+// sortForEviction 按策略排序淘汰候选
+func (cm *CacheManager) sortForEviction(entries []cleanupEntry, policy string) {
+	scores := make([]float64, len(entries))
+	sketch := cm.sketch.Load()
+	for i, e := range entries {
+		switch policy {
+		case config.EvictionLFU:
+			scores[i] = float64(atomic.LoadInt64(&e.item.AccessCount))
+		case config.EvictionGreedyDual:
+			scores[i] = e.item.priorityOr(cm.greedyDualValue(e.item))
+		case config.EvictionTinyLFU:
+			if sketch != nil {
+				scores[i] = float64(sketch.estimate(e.key))
+			}
+		}
+	}
+	sort.Sort(evictionOrder{entries: entries, scores: scores})
+}
+
+// priorityOr 返回已记录的 GreedyDual 优先级, 尚未记录时返回 fallback
+func (item *CacheItem) priorityOr(fallback float64) float64 {
+	if bits := item.gdPriority.Load(); bits != 0 {
+		return math.Float64frombits(bits)
+	}
+	return fallback
+}
+
+// evictEntry 淘汰一个缓存键, 返回是否删除以及释放的磁盘空间 (共享文件仍被引用时为 0)
+func (cm *CacheManager) evictEntry(e cleanupEntry, policy string) (bool, int64) {
+	removed, fileDeleted := cm.removeKey(e.key)
+	if !removed {
+		return false, 0
+	}
+	cm.evictions.Add(1)
+	if policy == config.EvictionGreedyDual {
+		cm.raiseGreedyDualClock(e.item.priorityOr(cm.greedyDualValue(e.item)))
+	}
+	log.Printf("[Cache] DEL %s (evicted, %s)", e.key.URL, policy)
+	if fileDeleted {
+		return true, roundToBlock(e.item.Size)
+	}
+	return true, 0
+}
+
+// admit TinyLFU 准入: 磁盘占用将超过高水位时, 新对象的估计频率须高于抽样现有缓存项的最低频率
+func (cm *CacheManager) admit(key CacheKey, size int64) bool {
+	ev := cm.evictionConfig()
+	if ev.policy != config.EvictionTinyLFU {
+		return true
+	}
+	sketch := cm.sketch.Load()
+	if sketch == nil || cm.diskUsage.Load()+roundToBlock(size) <= cm.maxCacheSize*int64(ev.high)/100 {
+		return true
+	}
+
+	victimFreq, sampled := uint32(math.MaxUint32), 0
+	cm.items.Range(func(k, _ interface{}) bool {
+		victimFreq = min(victimFreq, sketch.estimate(k.(CacheKey)))
+		sampled++
+		return sampled < admissionSample
+	})
+	if sampled == 0 || sketch.estimate(key) > victimFreq {
+		return true
+	}
+	cm.admissionRejected.Add(1)
+	log.Printf("[Cache] SKIP %s: 访问频率不足, 未通过准入 (tinylfu)", key.URL)
+	return false
+}
+
+// roundToBlock 按文件系统块向上取整
+func roundToBlock(size int64) int64 {
+	return (size + diskBlockSize - 1) / diskBlockSize * diskBlockSize
+}
+
+// measureDiskUsage 遍历缓存目录累计实际磁盘占用 (按块计, 含临时文件与索引文件)
+func (cm *CacheManager) measureDiskUsage() int64 {
+	var total int64
+	filepath.WalkDir(cm.cacheDir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += fileDiskUsage(info)
+		}
+		return nil
+	})
+	return total
+}
+
+// noteNewBlob 新文件写入后累加磁盘占用, 需要收缩时异步执行 (见 diskOverLimits);
+// isolated 表示文件属于独立路径分区 (由各自配额管理, 不计入全局水位)
+func (cm *CacheManager) noteNewBlob(size int64, isolated bool) {
+	size = roundToBlock(size)
+	usage := cm.diskUsage.Add(size)
+	if isolated {
+		cm.isolatedUsage.Add(size)
+	}
+	if cm.diskOverLimits(usage, isolated) {
+		go cm.enforceDiskLimits()
+	}
+}
+
+// diskOverLimits 判断是否需要收缩: 全局分区 (不含独立路径分区) 超过高水位, 或磁盘可用空间低于下限;
+// 正在收缩或处于冷却期时返回 false
+func (cm *CacheManager) diskOverLimits(usage int64, isolated bool) bool {
+	if cm.evicting.Load() || time.Now().UnixNano() < cm.enforceAfter.Load() {
+		return false
+	}
+	ev := cm.evictionConfig()
+	if !isolated && usage-cm.isolatedUsage.Load() > cm.maxCacheSize*int64(ev.high)/100 {
+		return true
+	}
+	if ev.minFree > 0 {
+		if free, ok := diskFreeSpace(cm.cacheDir); ok && free < ev.minFree {
+			return true
+		}
+	}
+	return false
+}
+
+// enforceDiskLimits 重新测量缓存目录实际占用, 全局分区超过高水位时按淘汰策略删除到低水位;
+// 磁盘可用空间低于下限时额外释放差额 (加上高低水位之差, 避免反复触发)。
+// 独立路径的缓存项由各自的配额管理 (见 cleanup), 不计入全局水位, 也不因全局水位被淘汰;
+// 只有全局分区不足以释放可用空间差额时才淘汰独立路径的缓存项。
+// 目录遍历间隔不小于 diskMeasureInterval; 收缩后仍无法回到水位以下时进入冷却, 期间新文件不再触发收缩
+func (cm *CacheManager) enforceDiskLimits() {
+	if !cm.evicting.CompareAndSwap(false, true) {
+		return
+	}
+	defer cm.evicting.Store(false)
+
+	ev := cm.evictionConfig()
+	now := time.Now()
+	usage := cm.diskUsage.Load()
+	if now.UnixNano()-cm.diskMeasuredAt.Load() >= int64(diskMeasureInterval) {
+		usage = cm.measureDiskUsage()
+		cm.diskUsage.Store(usage)
+		cm.diskMeasuredAt.Store(now.UnixNano())
+	}
+
+	var global, isolated []cleanupEntry
+	var isolatedUsage int64
+	cm.items.Range(func(k, v interface{}) bool {
+		entry := cleanupEntry{key: k.(CacheKey), item: v.(*CacheItem)}
+		if cm.isolatedPolicy(entry.item.PathPrefix) != nil {
+			isolated = append(isolated, entry)
+			isolatedUsage += roundToBlock(entry.item.Size)
+		} else {
+			global = append(global, entry)
+		}
+		return true
+	})
+	cm.isolatedUsage.Store(isolatedUsage)
+
+	var need, diskNeed int64
+	if globalUsage := usage - isolatedUsage; globalUsage > cm.maxCacheSize*int64(ev.high)/100 {
+		need = globalUsage - cm.maxCacheSize*int64(ev.low)/100
+	}
+	if ev.minFree > 0 {
+		if free, ok := diskFreeSpace(cm.cacheDir); ok && free < ev.minFree {
+			diskNeed = ev.minFree - free + cm.maxCacheSize*int64(ev.high-ev.low)/100
+		}
+	}
+	if need <= 0 && diskNeed <= 0 {
+		// 独立分区或其它文件占满时全局分区无可淘汰, 冷却后再检查
+		cm.enforceAfter.Store(now.Add(diskMeasureInterval).UnixNano())
+		return
+	}
+
+	cm.sortForEviction(global, ev.policy)
+	entries := global
+	if diskNeed > 0 {
+		cm.sortForEviction(isolated, ev.policy)
+		entries = append(entries, isolated...)
+	}
+	need = max(need, diskNeed)
+
+	var freed int64
+	evicted := 0
+	for _, entry := range entries {
+		if freed >= need {
+			break
+		}
+		removed, n := cm.evictEntry(entry, ev.policy)
+		if removed {
+			evicted++
+		}
+		freed += n
+	}
+	cm.diskUsage.Add(-freed)
+	if freed < need {
+		cm.enforceAfter.Store(now.Add(diskMeasureInterval).UnixNano())
+	}
+	log.Printf("[Cache] Evicted %d items (%s) by %s, disk usage %s", evicted, formatBytes(freed), ev.policy, formatBytes(usage-freed))
+}
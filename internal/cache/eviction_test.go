@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"proxy-go/internal/config"
+	"testing"
+	"time"
+)
+
+func newEvictionTestManager(t *testing.T, policy string) (*CacheManager, func(target string, size int) (CacheKey, *CacheItem, error)) {
+	t.Helper()
+	cm, err := NewCacheManager(t.TempDir(), &config.CacheConfig{MaxAge: 30, CleanupTick: 5, MaxCacheSize: 1, EvictionPolicy: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+	put := func(target string, size int) (CacheKey, *CacheItem, error) {
+		req := httptest.NewRequest("GET", target, nil)
+		key := cm.GenerateCacheKey(req, false)
+		body := bytes.Repeat([]byte(target[len(target)-1:]), size)
+		item, err := cm.Put(key, &http.Response{StatusCode: 200, Header: make(http.Header), Request: req}, body)
+		return key, item, err
+	}
+	return cm, put
+}
+
+func TestSortForEvictionPolicies(t *testing.T) {
+	now := time.Now()
+	entry := func(url string, size, accessCount int64, lastAccess time.Time) cleanupEntry {
+		item := &CacheItem{Size: size, AccessCount: accessCount}
+		item.setLastAccess(lastAccess)
+		return cleanupEntry{key: CacheKey{URL: url}, item: item}
+	}
+	entries := func() []cleanupEntry {
+		// old: 最久未访问但访问多; big: 大且访问少; hot: 最近访问的小文件
+		return []cleanupEntry{
+			entry("/hot", 10, 2, now),
+			entry("/old", 10, 50, now.Add(-time.Hour)),
+			entry("/big", 1<<20, 5, now.Add(-time.Minute)),
+		}
+	}
+	cm := &CacheManager{}
+	for policy, want := range map[string]string{
+		config.EvictionLRU:        "/old",
+		config.EvictionLFU:        "/hot",
+		config.EvictionGreedyDual: "/big",
+	} {
+		e := entries()
+		cm.sortForEviction(e, policy)
+		if e[0].key.URL != want {
+			t.Errorf("%s evicts %s first, want %s", policy, e[0].key.URL, want)
+		}
+	}
+}
+
+func TestEnforceDiskLimitsUsesDiskUsage(t *testing.T) {
+	cm, put := newEvictionTestManager(t, config.EvictionLRU)
+	var keys []CacheKey
+	for i := 0; i < 10; i++ {
+		key, item, err := put(fmt.Sprintf("/f/%d", i), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		item.setLastAccess(time.Now().Add(-time.Duration(10-i) * time.Minute))
+		keys = append(keys, key)
+	}
+
+	// 100 字节的文件逻辑大小只有 1000 字节, 但实际磁盘占用按块计算
+	usage := cm.measureDiskUsage()
+	if usage < 10*100 {
+		t.Fatalf("disk usage = %d", usage)
+	}
+	cm.maxCacheSize = usage / 2
+	cm.enforceDiskLimits()
+
+	ev := cm.evictionConfig()
+	if got := cm.measureDiskUsage(); got > cm.maxCacheSize*int64(ev.low)/100 {
+		t.Fatalf("disk usage %d above low watermark", got)
+	}
+	if _, ok := cm.items.Load(keys[0]); ok {
+		t.Fatal("least recently used item should be evicted")
+	}
+	if _, ok := cm.items.Load(keys[9]); !ok {
+		t.Fatal("most recently used item should be kept")
+	}
+	if stats := cm.GetStats(); stats.Evictions == 0 || stats.DiskUsage != cm.diskUsage.Load() {
+		t.Fatalf("stats = %+v", stats)
+	}
+}
+
+func TestEnforceDiskLimitsSkipsIsolatedPaths(t *testing.T) {
+	cm, put := newEvictionTestManager(t, config.EvictionLRU)
+	cm.SetKeyPolicies(map[string]config.PathConfig{
+		"/video": {CacheConfig: &config.CacheConfig{MaxCacheSize: 1}},
+	})
+	// 独立路径的缓存项最久未访问, 全局水位淘汰时仍不应被删除
+	videoKey, video, err := put("/video/a.mp4", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	video.setLastAccess(time.Now().Add(-time.Hour))
+	var keys []CacheKey
+	for i := 0; i < 10; i++ {
+		key, item, err := put(fmt.Sprintf("/f/%d", i), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		item.setLastAccess(time.Now().Add(-time.Duration(10-i) * time.Minute))
+		keys = append(keys, key)
+	}
+
+	cm.maxCacheSize = cm.measureDiskUsage() / 2
+	cm.enforceDiskLimits()
+
+	if _, ok := cm.items.Load(videoKey); !ok {
+		t.Fatal("item in an isolated path should not be evicted by the global watermark")
+	}
+	if _, ok := cm.items.Load(keys[0]); ok {
+		t.Fatal("least recently used global item should be evicted")
+	}
+}
+
+func TestIsolatedUsageDoesNotTriggerGlobalEviction(t *testing.T) {
+	cm, put := newEvictionTestManager(t, config.EvictionLRU)
+	cm.SetKeyPolicies(map[string]config.PathConfig{
+		"/video": {CacheConfig: &config.CacheConfig{MaxCacheSize: 1}},
+	})
+	for i := 0; i < 8; i++ {
+		if _, _, err := put(fmt.Sprintf("/video/%d", i), 100); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 独立分区单独已超过全局上限, 全局分区写入少量文件不应触发收缩
+	cm.maxCacheSize = cm.diskUsage.Load() / 2
+	if _, _, err := put("/f/a", 100); err != nil {
+		t.Fatal(err)
+	}
+	if cm.diskOverLimits(cm.diskUsage.Load(), false) {
+		t.Fatal("isolated pools should not count toward the global watermark")
+	}
+
+	// 没有可淘汰的全局项时进入冷却, 冷却期内不再遍历目录
+	cm.enforceDiskLimits()
+	measuredAt := cm.diskMeasuredAt.Load()
+	if measuredAt == 0 || cm.diskOverLimits(cm.diskUsage.Load(), false) {
+		t.Fatalf("measured at %d, should be cooling down", measuredAt)
+	}
+	cm.enforceDiskLimits()
+	if cm.diskMeasuredAt.Load() != measuredAt {
+		t.Fatal("disk usage should not be re-measured within the interval")
+	}
+}
+
+func TestTinyLFUAdmission(t *testing.T) {
+	cm, put := newEvictionTestManager(t, config.EvictionTinyLFU)
+	for i := 0; i < 4; i++ {
+		key, _, err := put(fmt.Sprintf("/hot/%d", i), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 5; j++ {
+			cm.Get(key, httptest.NewRequest("GET", key.URL, nil), false)
+		}
+	}
+	// 模拟磁盘已接近上限
+	cm.maxCacheSize = cm.diskUsage.Load()
+
+	if _, _, err := put("/cold/x", 100); err != ErrNotAdmitted {
+		t.Fatalf("cold object err = %v, want ErrNotAdmitted", err)
+	}
+
+	req := httptest.NewRequest("GET", "/popular/y", nil)
+	key := cm.GenerateCacheKey(req, false)
+	for j := 0; j < 8; j++ {
+		cm.Get(key, req, false)
+	}
+	if _, _, err := put("/popular/y", 100); err != nil {
+		t.Fatalf("frequently requested object should be admitted: %v", err)
+	}
+	if cm.GetStats().AdmissionRejected != 1 {
+		t.Fatal("rejection should be counted")
+	}
+}
+
+func TestNewEvictionConfigValidation(t *testing.T) {
+	if _, err := newEvictionConfig(&config.CacheConfig{EvictionPolicy: "random"}); err == nil {
+		t.Fatal("unknown policy should be rejected")
+	}
+	if _, err := newEvictionConfig(&config.CacheConfig{HighWatermark: 80, LowWatermark: 90}); err == nil {
+		t.Fatal("low watermark above high should be rejected")
+	}
+	ev, err := newEvictionConfig(&config.CacheConfig{EvictionPolicy: "GreedyDual", MinFreeSpace: 1})
+	if err != nil || ev.policy != config.EvictionGreedyDual || ev.high != 95 || ev.low != 85 || ev.minFree != 1024*1024 {
+		t.Fatalf("ev = %+v, err = %v", ev, err)
+	}
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// indexFileName 缓存索引文件, 与缓存文件放在同一目录; 清理接口会跳过 .json 文件
+const indexFileName = "index.json"
+
+// indexEntry 持久化的缓存项元数据, 每个缓存键一条 (同内容的多个键共享文件)
+type indexEntry struct {
+	Key             CacheKey  `json:"key"`
+	Hash            string    `json:"hash"`
+	ContentType     string    `json:"content_type,omitempty"`
+	ContentEncoding string    `json:"content_encoding,omitempty"`
+	Size            int64     `json:"size"`
+	CreatedAt       time.Time `json:"created_at"`
+	LastAccess      time.Time `json:"last_access"`
+	AccessCount     int64     `json:"access_count"`
+	PathPrefix      string    `json:"path_prefix,omitempty"`
+	Tags            []string  `json:"tags,omitempty"`
+	LastModified    time.Time `json:"last_modified,omitempty"`
+	Stale           bool      `json:"stale,omitempty"` // 已软清理, 恢复后仍需重新验证
+
+	// 状态码缓存条目 (Status 非 0) 没有内容文件, 只有状态码、响应头与过期时间
+	Status    int         `json:"status,omitempty"`
+	Header    http.Header `json:"header,omitempty"`
+	ExpiresAt time.Time   `json:"expires_at,omitempty"`
+}
+
+// indexEntryOf 生成 key 的索引条目
+func (cm *CacheManager) indexEntryOf(key CacheKey, item *CacheItem) indexEntry {
+	return indexEntry{
+		Key:             key,
+		Hash:            item.Hash,
+		ContentType:     item.ContentType,
+		ContentEncoding: item.ContentEncoding,
+		Size:            item.Size,
+		CreatedAt:       item.CreatedAt,
+		LastAccess:      item.LastAccess(),
+		AccessCount:     item.AccessCount,
+		PathPrefix:      item.PathPrefix,
+		Tags:            cm.tags.tagsOf(key),
+		LastModified:    item.LastModified,
+		Stale:           cm.isPurged(key),
+	}
+}
+
+// SaveIndex 把缓存索引 (含标签) 写入磁盘, 先写临时文件再原子替换
+func (cm *CacheManager) SaveIndex() error {
+	cm.indexMu.Lock()
+	defer cm.indexMu.Unlock()
+
+	var entries []indexEntry
+	cm.items.Range(func(k, v interface{}) bool {
+		entries = append(entries, cm.indexEntryOf(k.(CacheKey), v.(*CacheItem)))
+		return true
+	})
+	entries = append(entries, cm.statusIndexEntries()...)
+
+	tmp, err := os.CreateTemp(cm.tempDir(), "temp-index-*")
+	if err != nil {
+		return fmt.Errorf("failed to create index temp file: %v", err)
+	}
+	if err := json.NewEncoder(tmp).Encode(entries); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache index: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache index: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(cm.cacheDir, indexFileName)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to replace cache index: %v", err)
+	}
+	return nil
+}
+
+// loadIndex 启动时恢复缓存索引; 文件已丢失或已过期的条目被跳过, 其文件随后由 cleanStaleFiles 回收
+func (cm *CacheManager) loadIndex() error {
+	data, err := os.ReadFile(filepath.Join(cm.cacheDir, indexFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read cache index: %v", err)
+	}
+
+	var entries []indexEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse cache index: %v", err)
+	}
+
+	restored := 0
+	for _, e := range entries {
+		if e.Status != 0 {
+			if cm.restoreStatus(e) {
+				restored++
+			}
+			continue
+		}
+		if e.Hash == "" || cm.indexEntryExpired(e) {
+			continue
+		}
+		if cm.restoreEntry(e) {
+			restored++
+		}
+	}
+	log.Printf("[Cache] Restored %d cache items from index", restored)
+	return nil
+}
+
+// indexEntryExpired 按条目所属路径生效的 TTL 判断是否过期
+// 启动时路径策略尚未载入 (SetKeyPolicies 在创建管理器之后调用), 带路径前缀的条目无法确定 TTL,
+// 先保留下来, 由命中检查与定时清理按 maxAgeFor 回收
+func (cm *CacheManager) indexEntryExpired(e indexEntry) bool {
+	if e.PathPrefix != "" && cm.pathPolicies.Load() == nil {
+		return false
+	}
+	return time.Since(e.LastAccess) > cm.maxAgeFor(&CacheItem{PathPrefix: e.PathPrefix})
+}
+
+// restoreEntry 按索引条目登记缓存键; 内容既未登记也不在磁盘上时返回 false
+func (cm *CacheManager) restoreEntry(e indexEntry) bool {
+	b := cm.blobs.acquireExisting(e.Hash)
+	if b == nil {
+		filePath := cm.blobPath(e.Hash)
+		if _, err := os.Stat(filePath); err != nil {
+			return false
+		}
+		b = cm.blobs.acquire(e.Hash, filePath, e.Size)
+	}
+	item := newItem(b)
+	item.ContentType = e.ContentType
+	item.ContentEncoding = e.ContentEncoding
+	item.setLastAccess(e.LastAccess)
+	item.CreatedAt = e.CreatedAt
+	item.AccessCount = e.AccessCount
+	item.PathPrefix = e.PathPrefix
+	item.Tags = e.Tags
+	item.LastModified = e.LastModified
+	cm.storeKey(e.Key, item)
+	cm.tags.set(e.Key, e.Tags)
+	if e.Stale {
+		cm.purged.Store(e.Key, time.Now())
+	}
+	return true
+}
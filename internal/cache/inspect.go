@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultEntryPageSize = 50
+	maxEntryPageSize     = 500
+)
+
+// ErrEntryNotFound 缓存条目不存在 (或已过期 / 被清理)
+var ErrEntryNotFound = errors.New("cache entry not found")
+
+// EntryQuery 缓存条目查询条件, 零值字段不参与过滤
+type EntryQuery struct {
+	Search      string        // URL 子串
+	Prefix      string        // URL 前缀
+	ContentType string        // Content-Type 前缀, 如 "image/"
+	MinSize     int64         // 字节
+	MaxSize     int64         // 字节
+	MinAge      time.Duration // 写入至今的最短时间
+	MaxAge      time.Duration // 写入至今的最长时间
+	Sort        string        // last_access (默认) / created / size / access_count / url
+	Offset      int
+	Limit       int // 默认 50, 最大 500
+}
+
+// EntryInfo 单个缓存条目的元数据
+type EntryInfo struct {
+	ID              string      `json:"id"` // 缓存键的哈希, 用于查看详情与下载内容
+	URL             string      `json:"url"`
+	AcceptHeaders   string      `json:"accept_headers,omitempty"`
+	UserAgent       string      `json:"user_agent,omitempty"`
+	Vary            string      `json:"vary,omitempty"`
+	Body            string      `json:"body,omitempty"` // POST / QUERY 请求的方法与请求体哈希
+	Hash            string      `json:"hash,omitempty"`
+	ContentType     string      `json:"content_type,omitempty"`
+	ContentEncoding string      `json:"content_encoding,omitempty"`
+	Size            int64       `json:"size"`
+	CreatedAt       time.Time   `json:"created_at"`
+	LastAccess      time.Time   `json:"last_access"`
+	AccessCount     int64       `json:"access_count"`
+	TTL             int64       `json:"ttl"` // 剩余有效时间 (秒), 内容缓存每次命中后重新计算
+	PathPrefix      string      `json:"path_prefix,omitempty"`
+	Tags            []string    `json:"tags,omitempty"`
+	InMemory        bool        `json:"in_memory"`
+	Stale           bool        `json:"stale,omitempty"`  // 已软清理, 下次访问时重新验证
+	Status          int         `json:"status,omitempty"` // 状态码缓存条目的状态码, 内容缓存为 0
+	Header          http.Header `json:"header,omitempty"` // 状态码缓存保存的响应头
+}
+
+// EntryPage 分页查询结果
+type EntryPage struct {
+	Total   int         `json:"total"`
+	Offset  int         `json:"offset"`
+	Limit   int         `json:"limit"`
+	Entries []EntryInfo `json:"entries"`
+}
+
+// EntryID 返回缓存键在检查接口中的 ID
+func EntryID(key CacheKey) string {
+	return fmt.Sprintf("%016x", key.Hash())
+}
+
+// matchMeta 按 URL / 类型 / 大小 / 写入时间过滤
+func (q *EntryQuery) matchMeta(url, contentType string, size int64, age time.Duration) bool {
+	if q.Search != "" && !strings.Contains(url, q.Search) {
+		return false
+	}
+	if q.Prefix != "" && !strings.HasPrefix(url, q.Prefix) {
+		return false
+	}
+	if q.ContentType != "" && !strings.HasPrefix(strings.ToLower(contentType), strings.ToLower(q.ContentType)) {
+		return false
+	}
+	if (q.MinSize > 0 && size < q.MinSize) || (q.MaxSize > 0 && size > q.MaxSize) {
+		return false
+	}
+	if (q.MinAge > 0 && age < q.MinAge) || (q.MaxAge > 0 && age > q.MaxAge) {
+		return false
+	}
+	return true
+}
+
+// Entries 按条件分页列出缓存条目 (含状态码缓存)
+func (cm *CacheManager) Entries(q EntryQuery) EntryPage {
+	now := time.Now()
+	var entries []EntryInfo
+
+	cm.items.Range(func(k, v interface{}) bool {
+		key := k.(CacheKey)
+		item := v.(*CacheItem)
+		if q.matchMeta(key.URL, item.ContentType, item.Size, now.Sub(item.CreatedAt)) {
+			entries = append(entries, cm.itemInfo(key, item, now))
+		}
+		return true
+	})
+	cm.statuses.Range(func(k, v interface{}) bool {
+		key := k.(CacheKey)
+		entry := v.(*StatusEntry)
+		if now.Before(entry.ExpiresAt) && q.matchMeta(key.URL, entry.Header.Get("Content-Type"), 0, now.Sub(entry.CreatedAt)) {
+			entries = append(entries, statusInfo(key, entry, now))
+		}
+		return true
+	})
+
+	sortEntries(entries, q.Sort)
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultEntryPageSize
+	}
+	limit = min(limit, maxEntryPageSize)
+	offset := max(q.Offset, 0)
+
+	page := EntryPage{Total: len(entries), Offset: offset, Limit: limit, Entries: []EntryInfo{}}
+	if offset < len(entries) {
+		page.Entries = entries[offset:min(offset+limit, len(entries))]
+	}
+	return page
+}
+
+func sortEntries(entries []EntryInfo, by string) {
+	var less func(a, b *EntryInfo) bool
+	switch by {
+	case "created":
+		less = func(a, b *EntryInfo) bool { return a.CreatedAt.After(b.CreatedAt) }
+	case "size":
+		less = func(a, b *EntryInfo) bool { return a.Size > b.Size }
+	case "access_count":
+		less = func(a, b *EntryInfo) bool { return a.AccessCount > b.AccessCount }
+	case "url":
+		less = func(a, b *EntryInfo) bool { return a.URL < b.URL }
+	default:
+		less = func(a, b *EntryInfo) bool { return a.LastAccess.After(b.LastAccess) }
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if less(&entries[i], &entries[j]) {
+			return true
+		}
+		if less(&entries[j], &entries[i]) {
+			return false
+		}
+		return entries[i].ID < entries[j].ID // 同值时顺序稳定, 翻页不重复
+	})
+}
+
+// Entry 返回 ID 对应缓存条目的完整元数据
+func (cm *CacheManager) Entry(id string) (EntryInfo, error) {
+	now := time.Now()
+	if key, item, ok := cm.findItem(id); ok {
+		return cm.itemInfo(key, item, now), nil
+	}
+	var (
+		info  EntryInfo
+		found bool
+	)
+	cm.statuses.Range(func(k, v interface{}) bool {
+		key := k.(CacheKey)
+		entry := v.(*StatusEntry)
+		if EntryID(key) == id && now.Before(entry.ExpiresAt) {
+			info, found = statusInfo(key, entry, now), true
+			return false
+		}
+		return true
+	})
+	if !found {
+		return EntryInfo{}, ErrEntryNotFound
+	}
+	return info, nil
+}
+
+// OpenEntry 打开 ID 对应缓存条目的内容 (内存层副本或磁盘文件), 调用方负责调用返回的 close
+func (cm *CacheManager) OpenEntry(id string) (EntryInfo, io.ReadSeeker, func(), error) {
+	key, item, ok := cm.findItem(id)
+	if !ok {
+		return EntryInfo{}, nil, nil, ErrEntryNotFound
+	}
+	info := cm.itemInfo(key, item, time.Now())
+	// 直接读取内存副本, 不经过 MemoryBody, 避免管理接口的访问影响内存层的提升与命中统计
+	if body := item.blob.memBody.Load(); body != nil {
+		return info, bytes.NewReader(*body), func() {}, nil
+	}
+	file, err := os.Open(item.FilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return EntryInfo{}, nil, nil, ErrEntryNotFound
+		}
+		return EntryInfo{}, nil, nil, err
+	}
+	return info, file, func() { file.Close() }, nil
+}
+
+// findItem 按 ID 查找内容缓存项 (遍历, 仅供管理接口使用)
+func (cm *CacheManager) findItem(id string) (CacheKey, *CacheItem, bool) {
+	var (
+		key   CacheKey
+		item  *CacheItem
+		found bool
+	)
+	cm.items.Range(func(k, v interface{}) bool {
+		if EntryID(k.(CacheKey)) == id {
+			key, item, found = k.(CacheKey), v.(*CacheItem), true
+			return false
+		}
+		return true
+	})
+	return key, item, found
+}
+
+func (cm *CacheManager) itemInfo(key CacheKey, item *CacheItem, now time.Time) EntryInfo {
+	return EntryInfo{
+		ID:              EntryID(key),
+		URL:             key.URL,
+		AcceptHeaders:   key.AcceptHeaders,
+		UserAgent:       key.UserAgent,
+		Vary:            key.Vary,
+		Body:            key.Body,
+		Hash:            item.Hash,
+		ContentType:     item.ContentType,
+		ContentEncoding: item.ContentEncoding,
+		Size:            item.Size,
+		CreatedAt:       item.CreatedAt,
+		LastAccess:      item.LastAccess(),
+		AccessCount:     atomic.LoadInt64(&item.AccessCount),
+		TTL:             max(int64((cm.maxAgeFor(item)-now.Sub(item.LastAccess()))/time.Second), 0),
+		PathPrefix:      item.PathPrefix,
+		Tags:            cm.tags.tagsOf(key),
+		InMemory:        item.blob.memBody.Load() != nil,
+		Stale:           cm.isPurged(key),
+	}
+}
+
+func statusInfo(key CacheKey, entry *StatusEntry, now time.Time) EntryInfo {
+	return EntryInfo{
+		ID:            EntryID(key),
+		URL:           key.URL,
+		AcceptHeaders: key.AcceptHeaders,
+		UserAgent:     key.UserAgent,
+		Vary:          key.Vary,
+		Body:          key.Body,
+		ContentType:   entry.Header.Get("Content-Type"),
+		CreatedAt:     entry.CreatedAt,
+		LastAccess:    entry.CreatedAt,
+		TTL:           max(int64(entry.ExpiresAt.Sub(now)/time.Second), 0),
+		Status:        entry.StatusCode,
+		Header:        entry.Header,
+	}
+}
@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"net/http"
+	"net/url"
+	"proxy-go/internal/config"
+	"sort"
+	"strings"
+)
+
+// keyPolicyFor 返回 path 命中的缓存键策略; 未配置返回 nil (沿用完整 URL 作为键)
+func (cm *CacheManager) keyPolicyFor(path string) *config.CacheKeyConfig {
+	if policy := cm.pathPolicyFor(path); policy != nil {
+		return policy.key
+	}
+	return nil
+}
+
+// keyURL 按策略规范化缓存键中的 URL 部分 (路径 + query)
+func keyURL(u *url.URL, policy *config.CacheKeyConfig) string {
+	if policy == nil {
+		return u.String()
+	}
+
+	path := u.EscapedPath()
+	if policy.LowercasePath {
+		path = strings.ToLower(path)
+	}
+
+	query := normalizeKeyQuery(u.RawQuery, policy)
+	if query == "" {
+		return path
+	}
+	return path + "?" + query
+}
+
+// normalizeKeyQuery 按 QueryMode 过滤 query 参数; 保留原始编码, SortQuery 时按参数名稳定排序
+func normalizeKeyQuery(rawQuery string, policy *config.CacheKeyConfig) string {
+	if rawQuery == "" || policy.QueryMode == config.CacheKeyQueryIgnore {
+		return ""
+	}
+
+	parts := strings.Split(rawQuery, "&")
+	kept := make([]string, 0, len(parts))
+	names := make(map[string]string, len(parts))
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		name := part
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			name = part[:i]
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+
+		matched := matchQueryParam(name, policy.QueryParams)
+		switch policy.QueryMode {
+		case config.CacheKeyQueryInclude:
+			if !matched {
+				continue
+			}
+		case config.CacheKeyQueryExclude:
+			if matched {
+				continue
+			}
+		}
+		kept = append(kept, part)
+		names[part] = name
+	}
+
+	if policy.SortQuery {
+		sort.SliceStable(kept, func(i, j int) bool {
+			return names[kept[i]] < names[kept[j]]
+		})
+	}
+	return strings.Join(kept, "&")
+}
+
+// matchQueryParam 参数名精确匹配, 或以 "*" 结尾的前缀匹配 (如 "utm_*")
+func matchQueryParam(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(name, p[:len(p)-1]) {
+				return true
+			}
+		} else if name == p {
+			return true
+		}
+	}
+	return false
+}
+
+// keyVary 由 Host / 指定请求头 / 指定 Cookie 组成的附加维度; 未配置时为空串
+func keyVary(r *http.Request, policy *config.CacheKeyConfig) string {
+	if policy == nil {
+		return ""
+	}
+	var parts []string
+	if policy.IncludeHost {
+		parts = append(parts, "host="+strings.ToLower(r.Host))
+	}
+	for _, name := range policy.Headers {
+		parts = append(parts, "h:"+strings.ToLower(name)+"="+strings.TrimSpace(r.Header.Get(name)))
+	}
+	for _, name := range policy.Cookies {
+		value := ""
+		if c, err := r.Cookie(name); err == nil {
+			value = c.Value
+		}
+		parts = append(parts, "c:"+name+"="+value)
+	}
+	return strings.Join(parts, "|")
+}
+
+// normalizeCacheMatchURL 用于缓存清理匹配，忽略 query 和 fragment，并统一尾斜杠;
+// 命中路径配置了 LowercasePath 时与生成缓存键时一样转为小写, 保证清理能匹配到键
+func (cm *CacheManager) normalizeCacheMatchURL(rawURL string) string {
+	normalized := strings.TrimSpace(rawURL)
+	if normalized == "" {
+		return ""
+	}
+
+	if index := strings.IndexAny(normalized, "?#"); index >= 0 {
+		normalized = normalized[:index]
+	}
+
+	if normalized != "/" {
+		normalized = strings.TrimSuffix(normalized, "/")
+	}
+
+	if policy := cm.keyPolicyFor(normalized); policy != nil && policy.LowercasePath {
+		normalized = strings.ToLower(normalized)
+	}
+
+	return normalized
+}
+
+// normalizeCacheExactURL 把待清理的 URL (路径 + query) 按生成缓存键时的同一套规则规范化, 用于精确匹配
+func (cm *CacheManager) normalizeCacheExactURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return strings.TrimSuffix(rawURL, "/")
+	}
+	policy := cm.keyPolicyFor(u.Path)
+	if policy == nil {
+		return strings.TrimSuffix(rawURL, "/")
+	}
+	u.Fragment = ""
+	return strings.TrimSuffix(keyURL(u, policy), "/")
+}
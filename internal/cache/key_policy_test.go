@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"proxy-go/internal/config"
+	"testing"
+)
+
+func newPolicyTestManager(t *testing.T, policy *config.CacheKeyConfig) *CacheManager {
+	t.Helper()
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+	cm.SetKeyPolicies(map[string]config.PathConfig{
+		"/img":   {CacheKey: policy},
+		"/plain": {},
+	})
+	return cm
+}
+
+func TestGenerateCacheKeyWithPolicy(t *testing.T) {
+	cm := newPolicyTestManager(t, &config.CacheKeyConfig{
+		QueryMode:     config.CacheKeyQueryExclude,
+		QueryParams:   []string{"utm_*", "fbclid"},
+		SortQuery:     true,
+		LowercasePath: true,
+		Headers:       []string{"X-Device"},
+		IncludeHost:   true,
+	})
+
+	req := httptest.NewRequest("GET", "/img/A.PNG?w=10&utm_source=x&fbclid=1&b=2", nil)
+	req.Host = "CDN.example.com"
+	req.Header.Set("X-Device", "mobile")
+
+	key := cm.GenerateCacheKey(req, false)
+	if key.URL != "/img/a.png?b=2&w=10" {
+		t.Fatalf("key.URL = %q", key.URL)
+	}
+	if key.Vary != "host=cdn.example.com|h:x-device=mobile" {
+		t.Fatalf("key.Vary = %q", key.Vary)
+	}
+
+	// 未配置策略的路径保持完整 URL
+	plain := cm.GenerateCacheKey(httptest.NewRequest("GET", "/plain/a?utm_source=x", nil), false)
+	if plain.URL != "/plain/a?utm_source=x" || plain.Vary != "" {
+		t.Fatalf("plain key = %+v", plain)
+	}
+}
+
+func TestNormalizeKeyQueryModes(t *testing.T) {
+	tests := []struct {
+		policy config.CacheKeyConfig
+		want   string
+	}{
+		{config.CacheKeyConfig{QueryMode: config.CacheKeyQueryIgnore}, ""},
+		{config.CacheKeyConfig{QueryMode: config.CacheKeyQueryInclude, QueryParams: []string{"v"}}, "v=1"},
+		{config.CacheKeyConfig{SortQuery: true}, "a=3&v=1&z=2"},
+		{config.CacheKeyConfig{}, "v=1&z=2&a=3"},
+	}
+	for _, tt := range tests {
+		if got := normalizeKeyQuery("v=1&z=2&a=3", &tt.policy); got != tt.want {
+			t.Errorf("normalizeKeyQuery(%+v) = %q, want %q", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestClearCacheUsesKeyPolicy(t *testing.T) {
+	cm := newPolicyTestManager(t, &config.CacheKeyConfig{
+		QueryMode:     config.CacheKeyQueryExclude,
+		QueryParams:   []string{"utm_*"},
+		LowercasePath: true,
+		Headers:       []string{"X-Device"},
+	})
+
+	put := func(target, device string) {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("X-Device", device)
+		resp := &http.Response{StatusCode: 200, Header: make(http.Header), Request: req}
+		if _, err := cm.Put(cm.GenerateCacheKey(req, false), resp, []byte(target+device)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("/img/Logo.png", "mobile")
+	put("/img/Logo.png", "desktop")
+	put("/img/b.png?v=1&utm_source=x", "mobile")
+
+	// 清理所有 Header 变体, 路径大小写按策略规范化
+	if n, _ := cm.ClearCacheByURL("/img/LOGO.png", PurgeOptions{}); n != 2 {
+		t.Fatalf("ClearCacheByURL cleared %d, want 2", n)
+	}
+	// 精确匹配时按同一规则剔除 utm_*
+	if n, _ := cm.ClearCacheByURLs([]string{"/img/b.png?v=1&utm_campaign=y"}, PurgeOptions{}); n != 1 {
+		t.Fatalf("ClearCacheByURLs cleared %d, want 1", n)
+	}
+}
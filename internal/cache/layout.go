@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 缓存目录布局:
+//
+//	<cacheDir>/index.json          缓存索引
+//	<cacheDir>/tmp/temp-*          写入中的临时文件 (与 blob 同一文件系统, 提交时原子 rename)
+//	<cacheDir>/ab/cd/<sha256>      内容文件, 按哈希前两字节两级分散, 避免单个目录下百万级文件
+//	<cacheDir>/ab/cd/<sha256>.gz   内容文件的 gzip 变体 (见 encoding.go), 随内容文件删除
+//	<cacheDir>/ab/cd/<sha256>.br   内容文件的 br 变体, 同上
+//	<cacheDir>/quarantine/*        完整性校验不一致的内容文件, 保留 7 天 (见 scrub.go)
+const tempDirName = "tmp"
+
+// blobPath 返回内容哈希对应的文件路径
+func (cm *CacheManager) blobPath(hash string) string {
+	if len(hash) < 4 {
+		return filepath.Join(cm.cacheDir, hash)
+	}
+	return filepath.Join(cm.cacheDir, hash[:2], hash[2:4], hash)
+}
+
+// tempDir 临时文件目录
+func (cm *CacheManager) tempDir() string {
+	return filepath.Join(cm.cacheDir, tempDirName)
+}
+
+// ensureBlobDir 创建 blob 所在的子目录
+func ensureBlobDir(filePath string) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create cache subdirectory: %v", err)
+	}
+	return nil
+}
+
+// isBlobName 是否为 SHA-256 十六进制文件名
+func isBlobName(name string) bool {
+	return len(name) == 64 && isLowerHex(name)
+}
+
+// isFanoutDir 是否为分散目录名 (两位十六进制)
+func isFanoutDir(name string) bool {
+	return len(name) == 2 && isLowerHex(name)
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// migrateFlatLayout 把旧版平铺在缓存根目录的内容文件移入分散目录; 已迁移的目录只剩子目录与 .json, 代价为一次 ReadDir
+func (cm *CacheManager) migrateFlatLayout() error {
+	entries, err := os.ReadDir(cm.cacheDir)
+	if err != nil {
+		return fmt.Errorf("failed to read cache directory: %v", err)
+	}
+
+	migrated := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isBlobName(entry.Name()) {
+			continue
+		}
+		target := cm.blobPath(entry.Name())
+		if err := ensureBlobDir(target); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(cm.cacheDir, entry.Name()), target); err != nil {
+			log.Printf("[Cache] ERR Failed to migrate cache file %s: %v", entry.Name(), err)
+			continue
+		}
+		migrated++
+	}
+	if migrated > 0 {
+		log.Printf("[Cache] Migrated %d cache files to hierarchical layout", migrated)
+	}
+	return nil
+}
+
+// cleanStaleFiles 启动时对账: 遍历磁盘文件, 用内容哈希索引 O(1) 判断是否仍被引用, 删除未引用文件与残留临时文件
+func (cm *CacheManager) cleanStaleFiles() error {
+	entries, err := os.ReadDir(cm.cacheDir)
+	if err != nil {
+		return fmt.Errorf("failed to read cache directory: %v", err)
+	}
+
+	removed := 0
+	remove := func(path string) {
+		if err := os.Remove(path); err != nil {
+			log.Printf("[Cache] ERR Failed to remove stale file: %s", path)
+			return
+		}
+		removed++
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(cm.cacheDir, name)
+		switch {
+		case strings.HasSuffix(name, ".json"):
+			// 保留配置文件与缓存索引
+		case name == tempDirName && entry.IsDir():
+			tempFiles, _ := os.ReadDir(path)
+			for _, f := range tempFiles {
+				remove(filepath.Join(path, f.Name()))
+			}
+		case isFanoutDir(name) && entry.IsDir():
+			cm.cleanFanoutDir(path, remove)
+		case entry.Type().IsRegular():
+			// 旧版遗留的临时文件及其它未知文件
+			remove(path)
+		}
+	}
+
+	if removed > 0 {
+		log.Printf("[Cache] Removed %d stale cache files", removed)
+	}
+	return nil
+}
+
+// cleanFanoutDir 清理一个一级分散目录下未被引用的内容文件及其压缩变体
+func (cm *CacheManager) cleanFanoutDir(dir string, remove func(string)) {
+	subdirs, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, sub := range subdirs {
+		if !sub.IsDir() {
+			remove(filepath.Join(dir, sub.Name()))
+			continue
+		}
+		subPath := filepath.Join(dir, sub.Name())
+		files, err := os.ReadDir(subPath)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			path := filepath.Join(subPath, f.Name())
+			name := variantBlobName(f.Name())
+			if !cm.blobs.owns(name, filepath.Join(subPath, name)) {
+				remove(path)
+			}
+		}
+	}
+}
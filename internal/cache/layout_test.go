@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFlatLayoutMigrationAndReconcile(t *testing.T) {
+	dir := t.TempDir()
+	cm, err := NewCacheManager(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/a.css", nil)
+	key := cm.GenerateCacheKey(req, false)
+	item, err := cm.Put(key, &http.Response{StatusCode: 200, Header: make(http.Header), Request: req}, []byte("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.FilePath != filepath.Join(dir, item.Hash[:2], item.Hash[2:4], item.Hash) {
+		t.Fatalf("file path = %s", item.FilePath)
+	}
+	if err := cm.SaveIndex(); err != nil {
+		t.Fatal(err)
+	}
+	cm.Stop()
+
+	// 模拟旧版平铺布局: 内容文件放回根目录, 并留下临时文件与未被引用的文件
+	flat := filepath.Join(dir, item.Hash)
+	if err := os.Rename(item.FilePath, flat); err != nil {
+		t.Fatal(err)
+	}
+	orphan := filepath.Join(dir, "ff", "ee", "ffee"+item.Hash[4:])
+	os.MkdirAll(filepath.Dir(orphan), 0755)
+	os.WriteFile(orphan, []byte("orphan"), 0600)
+	os.WriteFile(filepath.Join(dir, "temp-123"), []byte("x"), 0600)
+	os.WriteFile(filepath.Join(dir, tempDirName, "temp-456"), []byte("x"), 0600)
+
+	restored, err := NewCacheManager(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(restored.Stop)
+
+	got, found, _ := restored.Get(key, req, false)
+	if !found || got.FilePath != item.FilePath {
+		t.Fatalf("migrated item = %+v found=%v", got, found)
+	}
+	for _, path := range []string{flat, orphan, filepath.Join(dir, "temp-123"), filepath.Join(dir, tempDirName, "temp-456")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("%s should be removed", path)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, indexFileName)); err != nil {
+		t.Fatal("index should be kept")
+	}
+}
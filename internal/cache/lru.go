@@ -0,0 +1,192 @@
+package cache
+
+import (
+	"hash/maphash"
+	"sync"
+)
+
+// lruShardCount LRU 分片数; 命中时需要调整链表顺序 (写锁), 分片后不同 key 的命中不再互相阻塞
+const lruShardCount = 32
+
+// LRU 缓存节点
+type LRUNode struct {
+	key   CacheKey
+	value *CacheItem
+	prev  *LRUNode
+	next  *LRUNode
+}
+
+// LRUCache 分片 LRU 缓存: 按 key 哈希分到各自带锁的分片, 每个分片独立维护 LRU 顺序与容量
+type LRUCache struct {
+	seed   maphash.Seed
+	shards []*lruShard
+}
+
+// lruShard 单个分片, 即原先的单锁 LRU
+type lruShard struct {
+	capacity int
+	size     int
+	head     *LRUNode
+	tail     *LRUNode
+	cache    map[CacheKey]*LRUNode
+	mu       sync.Mutex
+}
+
+// NewLRUCache 创建LRU缓存, 容量平均分配到各分片
+func NewLRUCache(capacity int) *LRUCache {
+	return newLRUCacheShards(capacity, lruShardCount)
+}
+
+// newLRUCacheShards 创建指定分片数的LRU缓存 (shards 为 1 时等价于单锁 LRU)
+func newLRUCacheShards(capacity, shards int) *LRUCache {
+	if shards < 1 {
+		shards = 1
+	}
+	perShard := (capacity + shards - 1) / shards
+	if perShard < 1 {
+		perShard = 1
+	}
+	lru := &LRUCache{
+		seed:   maphash.MakeSeed(),
+		shards: make([]*lruShard, shards),
+	}
+	for i := range lru.shards {
+		shard := &lruShard{
+			capacity: perShard,
+			cache:    make(map[CacheKey]*LRUNode),
+			head:     &LRUNode{},
+			tail:     &LRUNode{},
+		}
+		shard.head.next = shard.tail
+		shard.tail.prev = shard.head
+		lru.shards[i] = shard
+	}
+	return lru
+}
+
+// shardFor 按 key 选择分片 (不分配内存); 只哈希 URL, 同一 URL 的不同格式 / Vary 变体落在同一分片
+func (lru *LRUCache) shardFor(key CacheKey) *lruShard {
+	if len(lru.shards) == 1 {
+		return lru.shards[0]
+	}
+	return lru.shards[maphash.String(lru.seed, key.URL)%uint64(len(lru.shards))]
+}
+
+// Get 从LRU缓存中获取
+func (lru *LRUCache) Get(key CacheKey) (*CacheItem, bool) {
+	shard := lru.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if node, exists := shard.cache[key]; exists {
+		shard.moveToHead(node)
+		return node.value, true
+	}
+	return nil, false
+}
+
+// Put 向LRU缓存中添加
+func (lru *LRUCache) Put(key CacheKey, value *CacheItem) {
+	shard := lru.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if node, exists := shard.cache[key]; exists {
+		node.value = value
+		shard.moveToHead(node)
+	} else {
+		newNode := &LRUNode{key: key, value: value}
+		shard.cache[key] = newNode
+		shard.addToHead(newNode)
+		shard.size++
+
+		if shard.size > shard.capacity {
+			tail := shard.removeTail()
+			delete(shard.cache, tail.key)
+			shard.size--
+		}
+	}
+}
+
+// Delete 从LRU缓存中删除
+func (lru *LRUCache) Delete(key CacheKey) {
+	shard := lru.shardFor(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if node, exists := shard.cache[key]; exists {
+		shard.removeNode(node)
+		delete(shard.cache, key)
+		shard.size--
+	}
+}
+
+// Range 遍历所有缓存项 (逐个分片加锁, 不是全局一致快照)
+func (lru *LRUCache) Range(fn func(key CacheKey, value *CacheItem) bool) {
+	for _, shard := range lru.shards {
+		if !shard.rangeItems(fn) {
+			return
+		}
+	}
+}
+
+// Clear 清空LRU缓存
+func (lru *LRUCache) Clear() {
+	for _, shard := range lru.shards {
+		shard.mu.Lock()
+		shard.cache = make(map[CacheKey]*LRUNode)
+		shard.head.next = shard.tail
+		shard.tail.prev = shard.head
+		shard.size = 0
+		shard.mu.Unlock()
+	}
+}
+
+// Size 返回缓存大小
+func (lru *LRUCache) Size() int {
+	total := 0
+	for _, shard := range lru.shards {
+		shard.mu.Lock()
+		total += shard.size
+		shard.mu.Unlock()
+	}
+	return total
+}
+
+func (s *lruShard) rangeItems(fn func(key CacheKey, value *CacheItem) bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, node := range s.cache {
+		if !fn(key, node.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// moveToHead 将节点移到头部
+func (s *lruShard) moveToHead(node *LRUNode) {
+	s.removeNode(node)
+	s.addToHead(node)
+}
+
+// addToHead 添加到头部
+func (s *lruShard) addToHead(node *LRUNode) {
+	node.prev = s.head
+	node.next = s.head.next
+	s.head.next.prev = node
+	s.head.next = node
+}
+
+// removeNode 移除节点
+func (s *lruShard) removeNode(node *LRUNode) {
+	node.prev.next = node.next
+	node.next.prev = node.prev
+}
+
+// removeTail 移除尾部节点
+func (s *lruShard) removeTail() *LRUNode {
+	lastNode := s.tail.prev
+	s.removeNode(lastNode)
+	return lastNode
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+func TestShardedLRUEvictsPerShard(t *testing.T) {
+	lru := newLRUCacheShards(8, 4)
+	for i := 0; i < 100; i++ {
+		lru.Put(CacheKey{URL: fmt.Sprintf("/k%d", i)}, &CacheItem{})
+	}
+	if size := lru.Size(); size > 8 {
+		t.Fatalf("size = %d, want <= 8", size)
+	}
+
+	key := CacheKey{URL: "/hot"}
+	item := &CacheItem{}
+	lru.Put(key, item)
+	if got, ok := lru.Get(key); !ok || got != item {
+		t.Fatal("recently put item should be found")
+	}
+	lru.Delete(key)
+	if _, ok := lru.Get(key); ok {
+		t.Fatal("deleted item should be gone")
+	}
+	lru.Clear()
+	if lru.Size() != 0 {
+		t.Fatal("Clear should empty every shard")
+	}
+}
+
+func TestGetDropsItemWhoseFileWasRemoved(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+
+	req := httptest.NewRequest("GET", "/a.css", nil)
+	key := cm.GenerateCacheKey(req, false)
+	item, err := cm.Put(key, &http.Response{StatusCode: 200, Header: make(http.Header), Request: req}, []byte("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := cm.Get(key, req, false); !found {
+		t.Fatal("expected hit")
+	}
+
+	// 间隔内的命中不再 stat; 超过间隔后重新确认文件, 文件已删除则视为未命中
+	os.Remove(item.FilePath)
+	if _, found, _ := cm.Get(key, req, false); !found {
+		t.Fatal("hit within the verify interval should not stat")
+	}
+	item.blob.verifiedAt.Store(0)
+	if _, found, _ := cm.Get(key, req, false); found {
+		t.Fatal("missing file should be detected after the verify interval")
+	}
+	if _, ok := cm.items.Load(key); ok {
+		t.Fatal("missing file should remove the key")
+	}
+}
+
+func benchmarkLRUGetParallel(b *testing.B, shards int) {
+	const n = 4096
+	lru := newLRUCacheShards(n, shards)
+	keys := make([]CacheKey, n)
+	for i := range keys {
+		keys[i] = CacheKey{URL: fmt.Sprintf("/static/%d.js", i)}
+		lru.Put(keys[i], &CacheItem{})
+	}
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(next.Add(997))
+		for pb.Next() {
+			lru.Get(keys[i%n])
+			i++
+		}
+	})
+}
+
+// BenchmarkLRUGetParallel 对比单锁与分片 LRU 在并发命中下的吞吐, 例如:
+// go test ./internal/cache -run '^$' -bench LRUGetParallel -cpu 1,8,32
+func BenchmarkLRUGetParallel(b *testing.B) {
+	b.Run("shards=1", func(b *testing.B) { benchmarkLRUGetParallel(b, 1) })
+	b.Run(fmt.Sprintf("shards=%d", lruShardCount), func(b *testing.B) { benchmarkLRUGetParallel(b, lruShardCount) })
+}
+
+// BenchmarkCacheGetParallel 缓存命中的完整路径 (LRU + 过期检查 + 节流的文件确认)
+func BenchmarkCacheGetParallel(b *testing.B) {
+	cm, err := NewCacheManager(b.TempDir(), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(cm.Stop)
+
+	const n = 256
+	keys := make([]CacheKey, n)
+	reqs := make([]*http.Request, n)
+	for i := range keys {
+		reqs[i] = httptest.NewRequest("GET", fmt.Sprintf("/static/%d.js", i), nil)
+		keys[i] = cm.GenerateCacheKey(reqs[i], false)
+		body := []byte(fmt.Sprintf("body-%d", i))
+		if _, err := cm.Put(keys[i], &http.Response{StatusCode: 200, Header: make(http.Header), Request: reqs[i]}, body); err != nil {
+			b.Fatal(err)
+		}
+	}
+	var next atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(next.Add(97))
+		for pb.Next() {
+			j := i % n
+			if _, found, _ := cm.Get(keys[j], reqs[j], false); !found {
+				b.Fatal("expected hit")
+			}
+			i++
+		}
+	})
+}
@@ -11,7 +11,6 @@ import (
 	"path/filepath"
 	"proxy-go/internal/config"
 	"proxy-go/internal/utils"
-	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -63,146 +62,42 @@ func PutBuffer(buf []byte) {
 	// 其他大小的缓冲区让GC处理
 }
 
-// LRU 缓存节点
-type LRUNode struct {
-	key   CacheKey
-	value *CacheItem
-	prev  *LRUNode
-	next  *LRUNode
-}
-
-// LRU 缓存实现
-type LRUCache struct {
-	capacity int
-	size     int
-	head     *LRUNode
-	tail     *LRUNode
-	cache    map[CacheKey]*LRUNode
-	mu       sync.RWMutex
-}
-
-// NewLRUCache 创建LRU缓存
-func NewLRUCache(capacity int) *LRUCache {
-	lru := &LRUCache{
-		capacity: capacity,
-		cache:    make(map[CacheKey]*LRUNode),
-		head:     &LRUNode{},
-		tail:     &LRUNode{},
-	}
-	lru.head.next = lru.tail
-	lru.tail.prev = lru.head
-	return lru
-}
-
-// Get 从LRU缓存中获取
-func (lru *LRUCache) Get(key CacheKey) (*CacheItem, bool) {
-	lru.mu.Lock()
-	defer lru.mu.Unlock()
-
-	if node, exists := lru.cache[key]; exists {
-		lru.moveToHead(node)
-		return node.value, true
-	}
-	return nil, false
-}
-
-// Put 向LRU缓存中添加
-func (lru *LRUCache) Put(key CacheKey, value *CacheItem) {
-	lru.mu.Lock()
-	defer lru.mu.Unlock()
-
-	if node, exists := lru.cache[key]; exists {
-		node.value = value
-		lru.moveToHead(node)
-	} else {
-		newNode := &LRUNode{key: key, value: value}
-		lru.cache[key] = newNode
-		lru.addToHead(newNode)
-		lru.size++
-
-		if lru.size > lru.capacity {
-			tail := lru.removeTail()
-			delete(lru.cache, tail.key)
-			lru.size--
-		}
-	}
-}
-
-// Delete 从LRU缓存中删除
-func (lru *LRUCache) Delete(key CacheKey) {
-	lru.mu.Lock()
-	defer lru.mu.Unlock()
-
-	if node, exists := lru.cache[key]; exists {
-		lru.removeNode(node)
-		delete(lru.cache, key)
-		lru.size--
-	}
-}
-
-// moveToHead 将节点移到头部
-func (lru *LRUCache) moveToHead(node *LRUNode) {
-	lru.removeNode(node)
-	lru.addToHead(node)
-}
-
-// addToHead 添加到头部
-func (lru *LRUCache) addToHead(node *LRUNode) {
-	node.prev = lru.head
-	node.next = lru.head.next
-	lru.head.next.prev = node
-	lru.head.next = node
-}
-
-// removeNode 移除节点
-func (lru *LRUCache) removeNode(node *LRUNode) {
-	node.prev.next = node.next
-	node.next.prev = node.prev
-}
-
-// removeTail 移除尾部节点
-func (lru *LRUCache) removeTail() *LRUNode {
-	lastNode := lru.tail.prev
-	lru.removeNode(lastNode)
-	return lastNode
-}
-
-// Range 遍历所有缓存项
-func (lru *LRUCache) Range(fn func(key CacheKey, value *CacheItem) bool) {
-	lru.mu.RLock()
-	defer lru.mu.RUnlock()
-
-	for key, node := range lru.cache {
-		if !fn(key, node.value) {
-			break
-		}
-	}
-}
+// CacheStatusHeader 响应头, 标明本次请求的缓存处理结果
+const CacheStatusHeader = "CZL-Proxy-Cache-Status"
 
-// Size 返回缓存大小
-func (lru *LRUCache) Size() int {
-	lru.mu.RLock()
-	defer lru.mu.RUnlock()
-	return lru.size
-}
+// CacheStatusHeader 的取值
+const (
+	CacheStatusHit     = "HIT"     // 命中缓存
+	CacheStatusMiss    = "MISS"    // 未命中, 已回源
+	CacheStatusBypass  = "BYPASS"  // 按缓存规则绕过缓存
+	CacheStatusStale   = "STALE"   // 回源失败, 返回过期缓存
+	CacheStatusExpired = "EXPIRED" // 缓存已过期 (或文件已丢失), 已回源
+)
 
 // CacheKey 用于标识缓存项的唯一键
 type CacheKey struct {
 	URL           string
 	AcceptHeaders string
 	UserAgent     string
+	Vary          string // 路径级缓存键策略附加的 Host / 请求头 / Cookie 维度
+	Body          string `json:",omitempty"` // POST / QUERY 请求的方法与请求体哈希 (见 BodyKey), GET 为空
 }
 
 // String 实现 Stringer 接口，用于生成唯一的字符串表示
 func (k CacheKey) String() string {
-	return fmt.Sprintf("%s|%s|%s", k.URL, k.AcceptHeaders, k.UserAgent)
+	if k.Body != "" {
+		return fmt.Sprintf("%s|%s|%s|%s|%s", k.URL, k.AcceptHeaders, k.UserAgent, k.Vary, k.Body)
+	}
+	return fmt.Sprintf("%s|%s|%s|%s", k.URL, k.AcceptHeaders, k.UserAgent, k.Vary)
 }
 
 // Equal 比较两个 CacheKey 是否相等
 func (k CacheKey) Equal(other CacheKey) bool {
 	return k.URL == other.URL &&
 		k.AcceptHeaders == other.AcceptHeaders &&
-		k.UserAgent == other.UserAgent
+		k.UserAgent == other.UserAgent &&
+		k.Vary == other.Vary &&
+		k.Body == other.Body
 }
 
 // Hash 生成 CacheKey 的哈希值
@@ -212,50 +107,89 @@ func (k CacheKey) Hash() uint64 {
 	return h.Sum64()
 }
 
-// CacheItem 表示一个缓存项
+// CacheItem 表示一个缓存项 (每个缓存键一份元数据); 内容相同的缓存项共享同一个 blob,
+// FilePath / Size / Hash 取自 blob, 只读
 type CacheItem struct {
 	FilePath        string
 	ContentType     string
 	ContentEncoding string
 	Size            int64
-	LastAccess      time.Time
 	Hash            string
 	CreatedAt       time.Time
 	AccessCount     int64
-	Priority        int // 缓存优先级
+	Priority        int       // 缓存优先级
+	PathPrefix      string    // 所属路径前缀, 决定 TTL / 配额分区与路径级统计
+	Tags            []string  // 上游 Surrogate-Key / Cache-Tag 标签
+	LastModified    time.Time // 源站 Last-Modified, 零值表示源站未提供
+
+	blob       *blob         // 共享的内容文件, 见 blob.go
+	lastAccess atomic.Int64  // 最后访问时间 (UnixNano), 命中时并发更新, 见 LastAccess
+	gdPriority atomic.Uint64 // GreedyDual 优先级 (float64 位), 见 recordEvictionAccess
+}
+
+// LastAccess 返回最后访问时间
+func (item *CacheItem) LastAccess() time.Time {
+	return time.Unix(0, item.lastAccess.Load())
+}
+
+// setLastAccess 记录最后访问时间
+func (item *CacheItem) setLastAccess(t time.Time) {
+	item.lastAccess.Store(t.UnixNano())
 }
 
 // CacheStats 缓存统计信息
 type CacheStats struct {
-	TotalItems        int     `json:"total_items"`         // 缓存项数量
-	TotalSize         int64   `json:"total_size"`          // 总大小
-	HitCount          int64   `json:"hit_count"`           // 命中次数
-	MissCount         int64   `json:"miss_count"`          // 未命中次数
-	HitRate           float64 `json:"hit_rate"`            // 命中率
-	BytesSaved        int64   `json:"bytes_saved"`         // 节省的带宽
-	Enabled           bool    `json:"enabled"`             // 缓存开关状态
-	FormatFallbackHit int64   `json:"format_fallback_hit"` // 格式回退命中次数
-	ImageCacheHit     int64   `json:"image_cache_hit"`     // 图片缓存命中次数
-	RegularCacheHit   int64   `json:"regular_cache_hit"`   // 常规缓存命中次数
+	TotalItems        int        `json:"total_items"`         // 缓存项数量
+	TotalSize         int64      `json:"total_size"`          // 逻辑大小 (按缓存键累计, 共享文件重复计)
+	PhysicalSize      int64      `json:"physical_size"`       // 物理大小 (磁盘文件, 每个内容只计一次)
+	BlobCount         int        `json:"blob_count"`          // 磁盘文件数
+	DedupSavedBytes   int64      `json:"dedup_saved_bytes"`   // 去重节省的磁盘空间
+	HitCount          int64      `json:"hit_count"`           // 命中次数
+	MissCount         int64      `json:"miss_count"`          // 未命中次数
+	HitRate           float64    `json:"hit_rate"`            // 命中率
+	BytesSaved        int64      `json:"bytes_saved"`         // 节省的带宽
+	Enabled           bool       `json:"enabled"`             // 缓存开关状态
+	FormatFallbackHit int64      `json:"format_fallback_hit"` // 格式回退命中次数
+	ImageCacheHit     int64      `json:"image_cache_hit"`     // 图片缓存命中次数
+	RegularCacheHit   int64      `json:"regular_cache_hit"`   // 常规缓存命中次数
+	TotalTags         int        `json:"total_tags"`          // 缓存标签数量
+	EvictionPolicy    string     `json:"eviction_policy"`     // 淘汰策略
+	DiskUsage         int64      `json:"disk_usage"`          // 缓存目录实际磁盘占用 (按块计, 含临时文件)
+	DiskFree          int64      `json:"disk_free"`           // 缓存所在磁盘可用空间, -1 表示未知
+	Evictions         int64      `json:"evictions"`           // 因容量 / 磁盘空间淘汰的缓存项数
+	AdmissionRejected int64      `json:"admission_rejected"`  // 未通过 TinyLFU 准入的对象数
+	MemoryItems       int        `json:"memory_items"`        // 内存层对象数
+	MemorySize        int64      `json:"memory_size"`         // 内存层占用
+	MemoryHits        int64      `json:"memory_hits"`         // 直接从内存层响应的命中次数
+	MemoryEvictions   int64      `json:"memory_evictions"`    // 从内存层降级到磁盘的次数
+	StatusEntries     int        `json:"status_entries"`      // 状态码缓存 (404 / 301 等) 条目数
+	StaleItems        int        `json:"stale_items"`         // 已软清理、等待重新验证的缓存项数
+	GzipVariants      int        `json:"gzip_variants"`       // 已生成的 gzip 变体数
+	BrVariants        int        `json:"br_variants"`         // 已生成的 br 变体数
+	Scrub             ScrubStats `json:"scrub"`               // 完整性校验进度与结果
+
+	Paths map[string]PathCacheStats `json:"paths,omitempty"` // 路径级用量与命中率
 }
 
 // CacheManager 缓存管理器
 type CacheManager struct {
 	cacheDir string
 	items    sync.Map // 保持原有的 sync.Map 用于文件缓存
-	// hashIndex 是 hash -> *CacheItem 的二级索引，用于 O(1) 哈希去重
-	// 避免 Put/Commit 时 Range 整个 items
-	hashIndex    sync.Map
+	// blobs 内容寻址存储: hash -> 共享的 *CacheItem 与引用计数, 用于 O(1) 哈希去重,
+	// 只有不再被任何缓存键引用的文件才会被删除
+	blobs        *blobStore
 	lruCache     *LRUCache // 新增LRU缓存用于热点数据
 	maxAge       time.Duration
 	cleanupTick  time.Duration
 	maxCacheSize int64
-	enabled      atomic.Bool   // 缓存开关
-	hitCount     atomic.Int64  // 命中计数
-	missCount    atomic.Int64  // 未命中计数
-	bytesSaved   atomic.Int64  // 节省的带宽
-	cleanupTimer *time.Ticker  // 添加清理定时器
-	stopCleanup  chan struct{} // 添加停止信号通道
+	// maxObjectSize 单个对象最大可缓存大小 (字节), 0 表示不限制
+	maxObjectSize int64
+	enabled       atomic.Bool   // 缓存开关
+	hitCount      atomic.Int64  // 命中计数
+	missCount     atomic.Int64  // 未命中计数
+	bytesSaved    atomic.Int64  // 节省的带宽
+	cleanupTimer  *time.Ticker  // 添加清理定时器
+	stopCleanup   chan struct{} // 添加停止信号通道
 
 	// 新增：格式回退统计
 	formatFallbackHit atomic.Int64 // 格式回退命中次数
@@ -264,11 +198,49 @@ type CacheManager struct {
 
 	// ExtensionMatcher缓存
 	extensionMatcherCache *ExtensionMatcherCache
+
+	// pathPolicies 路径级缓存策略 (缓存键 / 独立缓存配置), 热更新时整体替换
+	pathPolicies atomic.Pointer[pathPolicySet]
+	pathCounters sync.Map // 路径前缀 -> *pathCounter
+	cleanupState cleanupState
+
+	// tags 缓存标签倒排索引; tagHeaders 读取标签的响应头
+	tags       *tagIndex
+	tagHeaders atomic.Pointer[[]string]
+	indexMu    sync.Mutex // 串行化索引持久化
+
+	// eviction 淘汰策略与磁盘水位; diskUsage 缓存目录实际占用, 定期重新测量, 期间按新文件累加
+	// isolatedUsage 独立路径分区的占用 (每次收缩时按缓存项重新统计, 期间按新文件累加), 不计入全局水位
+	eviction          atomic.Pointer[evictionConfig]
+	diskUsage         atomic.Int64
+	isolatedUsage     atomic.Int64
+	diskMeasuredAt    atomic.Int64 // 上次遍历目录测量占用的时间 (UnixNano)
+	enforceAfter      atomic.Int64 // 收缩冷却截止时间 (UnixNano), 见 enforceDiskLimits
+	evicting          atomic.Bool
+	evictions         atomic.Int64
+	admissionRejected atomic.Int64
+	gdClock           atomic.Uint64 // GreedyDual 膨胀值 L (float64 位)
+	sketch            atomic.Pointer[frequencySketch]
+
+	// memory 小对象内存层
+	memory memoryTier
+
+	// statuses 状态码缓存 (404 / 301 等非 200 响应): CacheKey -> *StatusEntry
+	statuses sync.Map
+
+	// scrub 后台完整性校验
+	scrub scrubber
+
+	// purged 已软清理、等待重新验证的缓存键: CacheKey -> 标记时间, 见 purge.go
+	purged sync.Map
+
+	// variantSem 限制同时生成压缩变体的数量
+	variantSem chan struct{}
 }
 
 // NewCacheManager 创建新的缓存管理器
 func NewCacheManager(cacheDir string, initialConfig *config.CacheConfig) (*CacheManager, error) {
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Join(cacheDir, tempDirName), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create cache directory: %v", err)
 	}
 
@@ -276,6 +248,9 @@ func NewCacheManager(cacheDir string, initialConfig *config.CacheConfig) (*Cache
 		cacheDir:    cacheDir,
 		lruCache:    NewLRUCache(10000), // 10000个热点缓存项
 		stopCleanup: make(chan struct{}),
+		tags:        newTagIndex(),
+		blobs:       newBlobStore(),
+		variantSem:  make(chan struct{}, variantConcurrent),
 
 		// 初始化ExtensionMatcher缓存
 		extensionMatcherCache: NewExtensionMatcherCache(),
@@ -296,13 +271,36 @@ func NewCacheManager(cacheDir string, initialConfig *config.CacheConfig) (*Cache
 		log.Printf("[Cache] Using default cache config (maxAge: 30min, cleanupTick: 5min, maxSize: 10GB)")
 	}
 
-	// 启动时清理过期和临时文件
+	if initialConfig != nil && initialConfig.MaxObjectSize > 0 {
+		cm.maxObjectSize = initialConfig.MaxObjectSize * 1024 * 1024
+	}
+
+	cm.configureMemoryTier(initialConfig)
+	cm.scrub.init()
+	cm.configureScrub(initialConfig)
+
+	ev, err := newEvictionConfig(initialConfig)
+	if err != nil {
+		log.Printf("[Cache] Invalid eviction config, using lru: %v", err)
+		ev, _ = newEvictionConfig(nil)
+	}
+	cm.setEviction(ev)
+
+	// 迁移旧版平铺布局, 恢复持久化的缓存索引, 再清理索引外的过期和临时文件
+	if err := cm.migrateFlatLayout(); err != nil {
+		log.Printf("[Cache] Failed to migrate cache layout: %v", err)
+	}
+	if err := cm.loadIndex(); err != nil {
+		log.Printf("[Cache] Failed to load cache index: %v", err)
+	}
 	if err := cm.cleanStaleFiles(); err != nil {
 		log.Printf("[Cache] Failed to clean stale files: %v", err)
 	}
+	cm.diskUsage.Store(cm.measureDiskUsage())
 
-	// 启动清理协程
+	// 启动清理与完整性校验协程
 	cm.startCleanup()
+	cm.startScrubber()
 
 	return cm, nil
 }
@@ -312,8 +310,11 @@ func NewCacheManager(cacheDir string, initialConfig *config.CacheConfig) (*Cache
 // cfImageOpt 为 true 时, 图片请求按"标准化图片格式 + 标准化浏览器"分桶 (用于 Cloudflare Images
 // 这类按 Accept 返回不同格式的源, 避免 webp/jpeg 互相覆盖); 为 false 时所有请求只用 URL 做 key,
 // 让同一原文件在所有设备间共享同一份缓存, 显著提升命中率。源站不做格式协商时务必保持 false。
+// 路径配置了 CacheKey 策略时, URL 部分按策略规范化 (query 过滤 / 排序 / 路径小写), 并附加 Vary 维度。
 func (cm *CacheManager) GenerateCacheKey(r *http.Request, cfImageOpt bool) CacheKey {
-	url := r.URL.String()
+	policy := cm.keyPolicyFor(r.URL.Path)
+	url := keyURL(r.URL, policy)
+	vary := keyVary(r, policy)
 
 	if cfImageOpt && utils.IsImageRequest(r.URL.Path) {
 		acceptHeaders := r.Header.Get("Accept")
@@ -324,11 +325,12 @@ func (cm *CacheManager) GenerateCacheKey(r *http.Request, cfImageOpt bool) Cache
 			URL:           url,
 			AcceptHeaders: imageFormat,
 			UserAgent:     cm.normalizeUserAgent(userAgent),
+			Vary:          vary,
 		}
 	}
 
 	// 默认: 仅按 URL 缓存, 不区分 Accept / UA, 保证最高命中率
-	return CacheKey{URL: url}
+	return CacheKey{URL: url, Vary: vary}
 }
 
 // parseImageFormatPreference 解析图片格式偏好，返回标准化的格式标识
@@ -392,11 +394,28 @@ func (cm *CacheManager) Get(key CacheKey, r *http.Request, cfImageOpt bool) (*Ca
 		return nil, false, false
 	}
 
+	var (
+		item        *CacheItem
+		found       bool
+		notModified bool
+	)
 	if cfImageOpt && utils.IsImageRequest(r.URL.Path) {
-		return cm.getImageWithFallback(key, r)
+		item, found, notModified = cm.getImageWithFallback(key, r)
+	} else {
+		item, found, notModified = cm.getRegularItem(key)
 	}
+	if found {
+		notModified = item.NotModified(r)
+	}
+	cm.recordPathAccess(key, found)
+	cm.recordEvictionAccess(key, item)
+	return item, found, notModified
+}
 
-	return cm.getRegularItem(key)
+// Contains 判断 key 是否有缓存项 (不检查过期, 不计入命中统计)
+func (cm *CacheManager) Contains(key CacheKey) bool {
+	_, ok := cm.items.Load(key)
+	return ok
 }
 
 // getImageWithFallback 获取图片缓存项，支持格式回退
@@ -428,6 +447,8 @@ func (cm *CacheManager) tryFormatFallback(originalKey CacheKey, r *http.Request)
 			URL:           originalKey.URL,
 			AcceptHeaders: format,
 			UserAgent:     originalKey.UserAgent,
+			Vary:          originalKey.Vary,
+			Body:          originalKey.Body,
 		}
 
 		if item, found, notModified := cm.getRegularItem(fallbackKey); found {
@@ -485,16 +506,22 @@ func (cm *CacheManager) isFormatCompatible(requestedFormat, cachedFormat, actual
 
 // getRegularItem 获取常规缓存项（原有逻辑）
 func (cm *CacheManager) getRegularItem(key CacheKey) (*CacheItem, bool, bool) {
+	// 已软清理的键按未命中处理, 由回源重新验证
+	if cm.isPurged(key) {
+		cm.missCount.Add(1)
+		return nil, false, false
+	}
+
 	// 检查LRU缓存
 	if item, found := cm.lruCache.Get(key); found {
-		// 检查LRU缓存项是否过期
-		if time.Since(item.LastAccess) > cm.maxAge {
-			cm.lruCache.Delete(key)
+		// 检查LRU缓存项是否过期, 文件是否仍存在
+		if time.Since(item.LastAccess()) > cm.maxAgeFor(item) || !item.fileAlive() {
+			cm.removeKey(key)
 			cm.missCount.Add(1)
 			return nil, false, false
 		}
 		// 更新访问时间
-		item.LastAccess = time.Now()
+		item.setLastAccess(time.Now())
 		atomic.AddInt64(&item.AccessCount, 1)
 		cm.hitCount.Add(1)
 		cm.regularCacheHit.Add(1)
@@ -510,25 +537,15 @@ func (cm *CacheManager) getRegularItem(key CacheKey) (*CacheItem, bool, bool) {
 
 	item := value.(*CacheItem)
 
-	// 验证文件是否存在
-	if _, err := os.Stat(item.FilePath); err != nil {
-		cm.items.Delete(key)
-		cm.hashIndex.CompareAndDelete(item.Hash, item)
-		cm.missCount.Add(1)
-		return nil, false, false
-	}
-
-	// 检查是否过期（使用LastAccess而不是CreatedAt）
-	if time.Since(item.LastAccess) > cm.maxAge {
-		cm.items.Delete(key)
-		cm.hashIndex.CompareAndDelete(item.Hash, item)
-		os.Remove(item.FilePath)
+	// 检查是否过期（使用LastAccess而不是CreatedAt）, TTL 按所属路径分区; 验证文件是否存在
+	if time.Since(item.LastAccess()) > cm.maxAgeFor(item) || !item.fileAlive() {
+		cm.removeKey(key)
 		cm.missCount.Add(1)
 		return nil, false, false
 	}
 
 	// 更新访问信息（重置过期时间）
-	item.LastAccess = time.Now()
+	item.setLastAccess(time.Now())
 	atomic.AddInt64(&item.AccessCount, 1)
 	cm.hitCount.Add(1)
 	cm.regularCacheHit.Add(1)
@@ -540,6 +557,38 @@ func (cm *CacheManager) getRegularItem(key CacheKey) (*CacheItem, bool, bool) {
 	return item, true, false
 }
 
+// fileVerifyInterval 命中时确认缓存文件存在的最小间隔; 间隔内的命中不再 stat,
+// 文件在间隔内被外部删除时由处理器打开文件失败后调用 InvalidateCacheItem 兜底
+const fileVerifyInterval = 30 * time.Second
+
+// fileAlive 按 fileVerifyInterval 节流检查缓存文件是否存在
+func (item *CacheItem) fileAlive() bool {
+	b := item.blob
+	now := time.Now().UnixNano()
+	if now-b.verifiedAt.Load() < int64(fileVerifyInterval) {
+		return true
+	}
+	if _, err := os.Stat(b.filePath); err != nil {
+		return false
+	}
+	b.verifiedAt.Store(now)
+	return true
+}
+
+// fillItem 按响应填充缓存项的元数据
+func (cm *CacheManager) fillItem(item *CacheItem, key CacheKey, resp *http.Response) *CacheItem {
+	now := time.Now()
+	item.ContentType = resp.Header.Get("Content-Type")
+	item.ContentEncoding = resp.Header.Get("Content-Encoding")
+	item.setLastAccess(now)
+	item.CreatedAt = now
+	item.AccessCount = 1
+	item.PathPrefix = cm.pathPrefixOf(key)
+	item.Tags = cm.responseTags(resp)
+	item.LastModified = responseLastModified(resp)
+	return item
+}
+
 // Put 添加缓存项
 func (cm *CacheManager) Put(key CacheKey, resp *http.Response, body []byte) (*CacheItem, error) {
 	// 只检查基本的响应状态
@@ -551,34 +600,33 @@ func (cm *CacheManager) Put(key CacheKey, resp *http.Response, body []byte) (*Ca
 	contentHash := sha256.Sum256(body)
 	hashStr := hex.EncodeToString(contentHash[:])
 
-	// 检查是否存在相同哈希的缓存项（O(1) 哈希索引查找）
-	if existing := cm.lookupByHash(hashStr); existing != nil {
-		cm.items.Store(key, existing)
-		log.Printf("[Cache] HIT %s %s (%s) from %s", resp.Request.Method, key.URL, formatBytes(existing.Size), utils.GetRequestSource(resp.Request))
-		return existing, nil
+	// 检查是否存在相同哈希的内容（O(1) 哈希索引查找）, 命中则共享同一文件, 元数据按本次响应单独保存
+	if existing := cm.blobs.acquireExisting(hashStr); existing != nil {
+		item := cm.fillItem(newItem(existing), key, resp)
+		cm.storeKey(key, item)
+		cm.tags.set(key, item.Tags)
+		log.Printf("[Cache] HIT %s %s (%s) from %s", resp.Request.Method, key.URL, formatBytes(item.Size), utils.GetRequestSource(resp.Request))
+		return item, nil
+	}
+
+	if !cm.admit(key, int64(len(body))) {
+		return nil, ErrNotAdmitted
 	}
 
-	// 生成文件名并存储
-	fileName := hashStr
-	filePath := filepath.Join(cm.cacheDir, fileName)
+	// 按内容哈希分散存储
+	filePath := cm.blobPath(hashStr)
+	if err := ensureBlobDir(filePath); err != nil {
+		return nil, err
+	}
 
 	if err := os.WriteFile(filePath, body, 0600); err != nil {
 		return nil, fmt.Errorf("failed to write cache file: %v", err)
 	}
 
-	item := &CacheItem{
-		FilePath:        filePath,
-		ContentType:     resp.Header.Get("Content-Type"),
-		ContentEncoding: resp.Header.Get("Content-Encoding"),
-		Size:            int64(len(body)),
-		LastAccess:      time.Now(),
-		Hash:            hashStr,
-		CreatedAt:       time.Now(),
-		AccessCount:     1,
-	}
-
-	cm.items.Store(key, item)
-	cm.hashIndex.Store(hashStr, item)
+	item := cm.fillItem(newItem(cm.blobs.acquire(hashStr, filePath, int64(len(body)))), key, resp)
+	cm.storeKey(key, item)
+	cm.tags.set(key, item.Tags)
+	cm.noteNewBlob(item.Size, cm.isolatedPolicy(item.PathPrefix) != nil)
 	method := "GET"
 	if resp.Request != nil {
 		method = resp.Request.Method
@@ -588,59 +636,73 @@ func (cm *CacheManager) Put(key CacheKey, resp *http.Response, body []byte) (*Ca
 }
 
 // cleanup 定期清理过期的缓存项
+//
+// 缓存项按所属路径分区: 配置了独立缓存 (PathConfig.CacheConfig) 的路径各自一个分区, 其余路径共用全局分区。
+// 每个分区按自己的 TTL 删除过期项, 超出自身容量上限时按最后访问时间淘汰分区内最旧的项,
+// 因此一个路径写满配额不会挤掉其他路径的缓存。
 func (cm *CacheManager) cleanup() {
-	var totalSize int64
+	pools := cm.duePools(time.Now())
 	var keysToDelete []CacheKey
 
-	// 收集需要删除的键和计算总大小
+	// 收集过期的键, 并按分区统计大小
 	cm.items.Range(func(k, v interface{}) bool {
 		key := k.(CacheKey)
 		item := v.(*CacheItem)
-		totalSize += item.Size
 
-		if time.Since(item.LastAccess) > cm.maxAge {
+		poolName := ""
+		if cm.isolatedPolicy(item.PathPrefix) != nil {
+			poolName = item.PathPrefix
+		}
+		pool, due := pools[poolName]
+		if !due {
+			return true
+		}
+
+		if time.Since(item.LastAccess()) > pool.maxAge {
 			keysToDelete = append(keysToDelete, key)
+			return true
 		}
+		pool.total += item.Size
+		pool.entries = append(pool.entries, cleanupEntry{key: key, item: item})
 		return true
 	})
 
-	// 如果总大小超过限制，按最后访问时间排序删除
-	if totalSize > cm.maxCacheSize {
-		var items []*CacheItem
-		cm.items.Range(func(k, v interface{}) bool {
-			items = append(items, v.(*CacheItem))
-			return true
-		})
-
-		// 按最后访问时间排序
-		sort.Slice(items, func(i, j int) bool {
-			return items[i].LastAccess.Before(items[j].LastAccess)
-		})
+	// 删除过期的缓存项
+	for _, key := range keysToDelete {
+		if removed, _ := cm.removeKey(key); removed {
+			log.Printf("[Cache] DEL %s (expired)", key.URL)
+		}
+	}
 
-		// 删除最旧的项直到总大小小于限制
-		for _, item := range items {
-			if totalSize <= cm.maxCacheSize {
+	// 独立路径总大小超过配额时，按淘汰策略删除; 全局分区按实际磁盘占用在下面处理
+	for _, pool := range pools {
+		if pool.maxSize <= 0 || pool.total <= pool.maxSize {
+			continue
+		}
+		cm.sortForEviction(pool.entries, pool.policy)
+		for _, entry := range pool.entries {
+			if pool.total <= pool.maxSize {
 				break
 			}
-			cm.items.Range(func(k, v interface{}) bool {
-				if v.(*CacheItem) == item {
-					keysToDelete = append(keysToDelete, k.(CacheKey))
-					totalSize -= item.Size
-					return false
-				}
-				return true
-			})
+			cm.evictEntry(entry, pool.policy)
+			pool.total -= entry.item.Size
 		}
 	}
 
-	// 删除过期和超出大小限制的缓存项
-	for _, key := range keysToDelete {
-		if item, ok := cm.items.Load(key); ok {
-			cacheItem := item.(*CacheItem)
-			os.Remove(cacheItem.FilePath)
-			cm.items.Delete(key)
-			cm.hashIndex.CompareAndDelete(cacheItem.Hash, cacheItem)
-			log.Printf("[Cache] DEL %s (expired)", key.URL)
+	if _, due := pools[""]; due {
+		cm.enforceDiskLimits()
+	}
+
+	// 随全局分区的清理周期清理过期的状态码缓存、整理标签索引并持久化缓存索引
+	if _, due := pools[""]; due {
+		cm.expireStatuses(time.Now())
+		cm.prunePurged()
+		cm.tags.prune(func(key CacheKey) bool {
+			_, ok := cm.items.Load(key)
+			return ok
+		})
+		if err := cm.SaveIndex(); err != nil {
+			log.Printf("[Cache] ERR Failed to save cache index: %v", err)
 		}
 	}
 }
@@ -685,9 +747,25 @@ func (cm *CacheManager) GetStats() CacheStats {
 		hitRate = float64(hitCount) / float64(totalRequests) * 100
 	}
 
+	statusEntries := 0
+	cm.statuses.Range(func(_, _ interface{}) bool {
+		statusEntries++
+		return true
+	})
+
+	blobCount, physicalSize := cm.blobs.stats()
+	memoryItems, memorySize := cm.memory.stats()
+	diskFree := int64(-1)
+	if free, ok := diskFreeSpace(cm.cacheDir); ok {
+		diskFree = free
+	}
+
 	return CacheStats{
 		TotalItems:        totalItems,
 		TotalSize:         totalSize,
+		PhysicalSize:      physicalSize,
+		BlobCount:         blobCount,
+		DedupSavedBytes:   max(totalSize-physicalSize, 0),
 		HitCount:          hitCount,
 		MissCount:         missCount,
 		HitRate:           hitRate,
@@ -696,6 +774,22 @@ func (cm *CacheManager) GetStats() CacheStats {
 		FormatFallbackHit: cm.formatFallbackHit.Load(),
 		ImageCacheHit:     cm.imageCacheHit.Load(),
 		RegularCacheHit:   cm.regularCacheHit.Load(),
+		TotalTags:         cm.tags.count(),
+		EvictionPolicy:    cm.evictionConfig().policy,
+		DiskUsage:         cm.diskUsage.Load(),
+		DiskFree:          diskFree,
+		Evictions:         cm.evictions.Load(),
+		AdmissionRejected: cm.admissionRejected.Load(),
+		MemoryItems:       memoryItems,
+		MemorySize:        memorySize,
+		MemoryHits:        cm.memory.hits.Load(),
+		MemoryEvictions:   cm.memory.evictions.Load(),
+		StatusEntries:     statusEntries,
+		StaleItems:        cm.purgedCount(),
+		GzipVariants:      cm.blobs.variants(variantGzip),
+		BrVariants:        cm.blobs.variants(variantBr),
+		Scrub:             cm.scrub.snapshot(),
+		Paths:             cm.getPathStats(),
 	}
 }
 
@@ -718,11 +812,12 @@ func (cm *CacheManager) ClearCache() error {
 		cm.items.Delete(key)
 	}
 
-	// 清空哈希索引
-	cm.hashIndex.Range(func(k, _ interface{}) bool {
-		cm.hashIndex.Delete(k)
-		return true
-	})
+	// 清空内容存储登记与 LRU, 文件在下面统一删除
+	cm.blobs.reset()
+	cm.lruCache.Clear()
+	cm.memory.reset()
+	cm.statuses.Clear()
+	cm.purged.Clear()
 
 	// 清理缓存目录中的所有文件
 	entries, err := os.ReadDir(cm.cacheDir)
@@ -731,11 +826,11 @@ func (cm *CacheManager) ClearCache() error {
 	}
 
 	for _, entry := range entries {
-		if entry.Name() == "config.json" {
-			continue // 保留配置文件
+		if entry.Name() == "config.json" || entry.Name() == tempDirName {
+			continue // 保留配置文件与正在写入的临时文件
 		}
 		filePath := filepath.Join(cm.cacheDir, entry.Name())
-		if err := os.Remove(filePath); err != nil {
+		if err := os.RemoveAll(filePath); err != nil {
 			log.Printf("[Cache] ERR Failed to remove file: %s", entry.Name())
 		}
 	}
@@ -747,338 +842,132 @@ func (cm *CacheManager) ClearCache() error {
 	cm.formatFallbackHit.Store(0)
 	cm.imageCacheHit.Store(0)
 	cm.regularCacheHit.Store(0)
+	cm.resetPathCounters()
+	cm.tags.reset()
+	cm.evictions.Store(0)
+	cm.admissionRejected.Store(0)
+	cm.memory.hits.Store(0)
+	cm.memory.evictions.Store(0)
+	cm.diskUsage.Store(cm.measureDiskUsage())
 
 	return nil
 }
 
-// ClearCacheByPrefix 清除指定路径前缀的缓存
-func (cm *CacheManager) ClearCacheByPrefix(pathPrefix string) (int, error) {
-	// 规范化路径前缀（确保没有尾部斜杠）
+// ClearCacheByPrefix 清除指定路径前缀的缓存; opts.Soft 为 true 时只标记过期 (软清理)
+func (cm *CacheManager) ClearCacheByPrefix(pathPrefix string, opts PurgeOptions) (int, error) {
+	// 规范化路径前缀（确保没有尾部斜杠）, 与缓存键一致地处理 LowercasePath
 	pathPrefix = strings.TrimSuffix(pathPrefix, "/")
-
-	// 清除内存中匹配的缓存项，并收集需要删除的文件
-	var keysToDelete []CacheKey
-	var hashesToDelete []struct {
-		hash string
-		item *CacheItem
+	if policy := cm.keyPolicyFor(pathPrefix); policy != nil && policy.LowercasePath {
+		pathPrefix = strings.ToLower(pathPrefix)
 	}
-	filesToDelete := make(map[string]bool)
 
-	cm.items.Range(func(key, value interface{}) bool {
-		cacheKey := key.(CacheKey)
-		// 检查 URL 是否以指定路径前缀开头
-		if strings.HasPrefix(cacheKey.URL, pathPrefix) {
-			keysToDelete = append(keysToDelete, cacheKey)
+	// 按路径段匹配 (与导出 / 路径策略一致): /img 不匹配 /images
+	cleared, deletedFiles := cm.purgeWhere(func(key CacheKey) bool {
+		return underPathPrefix(key.URL, pathPrefix)
+	}, opts)
 
-			// 获取缓存项，从中获取文件路径
-			if item, ok := value.(*CacheItem); ok && item.FilePath != "" {
-				// 从完整路径中提取文件名
-				filename := filepath.Base(item.FilePath)
-				filesToDelete[filename] = true
-				if item.Hash != "" {
-					hashesToDelete = append(hashesToDelete, struct {
-						hash string
-						item *CacheItem
-					}{item.Hash, item})
-				}
-			}
-		}
-		return true
-	})
-
-	// 从内存中删除缓存项
-	for _, key := range keysToDelete {
-		cm.items.Delete(key)
-	}
-	// 同步删除哈希索引（仅当索引指向的还是同一个 item 时）
-	for _, h := range hashesToDelete {
-		cm.hashIndex.CompareAndDelete(h.hash, h.item)
-	}
-
-	// 清理缓存目录中匹配的文件
-	deletedFiles := 0
-	entries, err := os.ReadDir(cm.cacheDir)
-	if err != nil {
-		log.Printf("[Cache] WARN Failed to read cache directory: %v", err)
-		// 即使读取目录失败，内存清理仍然成功
-		return len(keysToDelete), nil
-	}
-
-	// 删除匹配的缓存文件
-	for _, entry := range entries {
-		if entry.Name() == "config.json" || strings.HasSuffix(entry.Name(), ".json") {
-			continue // 保留配置文件和其他 JSON 文件
-		}
-
-		if filesToDelete[entry.Name()] {
-			filePath := filepath.Join(cm.cacheDir, entry.Name())
-			if err := os.Remove(filePath); err != nil {
-				log.Printf("[Cache] WARN Failed to remove file: %s", entry.Name())
-			} else {
-				deletedFiles++
-			}
-		}
-	}
-
-	log.Printf("[Cache] Cleared %d cache items (%d files) for path prefix: %s", len(keysToDelete), deletedFiles, pathPrefix)
-	return len(keysToDelete), nil
+	log.Printf("[Cache] %s %d cache items (%d files) for path prefix: %s", opts.Verb(), cleared, deletedFiles, pathPrefix)
+	return cleared, nil
 }
 
-// ClearCacheByURLs 清除指定 URL 列表的缓存
-func (cm *CacheManager) ClearCacheByURLs(urls []string) (int, error) {
+// ClearCacheByURLs 清除指定 URL 列表的缓存; opts.Soft 为 true 时只标记过期 (软清理)
+func (cm *CacheManager) ClearCacheByURLs(urls []string, opts PurgeOptions) (int, error) {
 	if len(urls) == 0 {
 		return 0, nil
 	}
 
-	// 规范化 URL 列表（去除尾部斜杠）
+	// 规范化 URL 列表（去除尾部斜杠）, 命中缓存键策略的 URL 按同一规则规范化
 	urlSet := make(map[string]bool)
 	for _, url := range urls {
-		normalizedURL := strings.TrimSuffix(url, "/")
-		urlSet[normalizedURL] = true
-	}
-
-	// 清除内存中匹配的缓存项，并收集需要删除的文件
-	var keysToDelete []CacheKey
-	var hashesToDelete []struct {
-		hash string
-		item *CacheItem
-	}
-	filesToDelete := make(map[string]bool)
-
-	cm.items.Range(func(key, value interface{}) bool {
-		cacheKey := key.(CacheKey)
-		// 检查 URL 是否在指定列表中（精确匹配）
-		normalizedCacheURL := strings.TrimSuffix(cacheKey.URL, "/")
-		if urlSet[normalizedCacheURL] {
-			keysToDelete = append(keysToDelete, cacheKey)
-
-			// 获取缓存项，从中获取文件路径
-			if item, ok := value.(*CacheItem); ok && item.FilePath != "" {
-				// 从完整路径中提取文件名
-				filename := filepath.Base(item.FilePath)
-				filesToDelete[filename] = true
-				if item.Hash != "" {
-					hashesToDelete = append(hashesToDelete, struct {
-						hash string
-						item *CacheItem
-					}{item.Hash, item})
-				}
-			}
-		}
-		return true
-	})
-
-	// 从内存中删除缓存项
-	for _, key := range keysToDelete {
-		cm.items.Delete(key)
-	}
-	for _, h := range hashesToDelete {
-		cm.hashIndex.CompareAndDelete(h.hash, h.item)
-	}
-
-	// 清理缓存目录中匹配的文件
-	deletedFiles := 0
-	entries, err := os.ReadDir(cm.cacheDir)
-	if err != nil {
-		log.Printf("[Cache] WARN Failed to read cache directory: %v", err)
-		// 即使读取目录失败，内存清理仍然成功
-		return len(keysToDelete), nil
+		urlSet[cm.normalizeCacheExactURL(url)] = true
 	}
 
-	// 删除匹配的缓存文件
-	for _, entry := range entries {
-		if entry.Name() == "config.json" || strings.HasSuffix(entry.Name(), ".json") {
-			continue // 保留配置文件和其他 JSON 文件
-		}
+	// 检查 URL 是否在指定列表中（精确匹配）
+	cleared, deletedFiles := cm.purgeWhere(func(key CacheKey) bool {
+		return urlSet[strings.TrimSuffix(key.URL, "/")]
+	}, opts)
 
-		if filesToDelete[entry.Name()] {
-			filePath := filepath.Join(cm.cacheDir, entry.Name())
-			if err := os.Remove(filePath); err != nil {
-				log.Printf("[Cache] WARN Failed to remove file: %s", entry.Name())
-			} else {
-				deletedFiles++
-			}
-		}
-	}
-
-	log.Printf("[Cache] Cleared %d cache items (%d files) for %d specific URLs", len(keysToDelete), deletedFiles, len(urls))
-	return len(keysToDelete), nil
+	log.Printf("[Cache] %s %d cache items (%d files) for %d specific URLs", opts.Verb(), cleared, deletedFiles, len(urls))
+	return cleared, nil
 }
 
-// ClearCacheByURL 清除单个 URL 的缓存，按路径语义忽略 query 和 fragment。
-func (cm *CacheManager) ClearCacheByURL(rawURL string) (int, error) {
-	targetURL := normalizeCacheMatchURL(rawURL)
+// ClearCacheByURL 清除单个 URL 的缓存，按路径语义忽略 query 和 fragment; opts.Soft 为 true 时只标记过期 (软清理)
+func (cm *CacheManager) ClearCacheByURL(rawURL string, opts PurgeOptions) (int, error) {
+	targetURL := cm.normalizeCacheMatchURL(rawURL)
 	if targetURL == "" {
 		return 0, nil
 	}
 
-	var keysToDelete []CacheKey
-	filesToDelete := make(map[string]bool)
+	cleared, deletedFiles := cm.purgeWhere(func(key CacheKey) bool {
+		return cm.normalizeCacheMatchURL(key.URL) == targetURL
+	}, opts)
 
-	var hashesToDelete []struct {
-		hash string
-		item *CacheItem
-	}
-	cm.items.Range(func(key, value interface{}) bool {
-		cacheKey := key.(CacheKey)
-		if normalizeCacheMatchURL(cacheKey.URL) == targetURL {
-			keysToDelete = append(keysToDelete, cacheKey)
+	log.Printf("[Cache] %s %d cache items (%d files) for single URL: %s", opts.Verb(), cleared, deletedFiles, targetURL)
+	return cleared, nil
+}
 
-			if item, ok := value.(*CacheItem); ok && item.FilePath != "" {
-				filename := filepath.Base(item.FilePath)
-				filesToDelete[filename] = true
-				if item.Hash != "" {
-					hashesToDelete = append(hashesToDelete, struct {
-						hash string
-						item *CacheItem
-					}{item.Hash, item})
-				}
-			}
+// removeKeysWhere 删除所有满足条件的缓存键; 文件仅在不再被其他键引用时删除
+// 返回删除的缓存键数量与删除的文件数量
+func (cm *CacheManager) removeKeysWhere(match func(CacheKey) bool) (int, int) {
+	var keysToDelete []CacheKey
+	cm.items.Range(func(key, _ interface{}) bool {
+		if cacheKey := key.(CacheKey); match(cacheKey) {
+			keysToDelete = append(keysToDelete, cacheKey)
 		}
 		return true
 	})
 
-	for _, key := range keysToDelete {
-		cm.items.Delete(key)
-	}
-	for _, h := range hashesToDelete {
-		cm.hashIndex.CompareAndDelete(h.hash, h.item)
-	}
-
+	cleared := cm.removeStatusesWhere(func(key CacheKey, _ *StatusEntry) bool {
+		return match(key)
+	})
 	deletedFiles := 0
-	entries, err := os.ReadDir(cm.cacheDir)
-	if err != nil {
-		log.Printf("[Cache] WARN Failed to read cache directory: %v", err)
-		return len(keysToDelete), nil
-	}
-
-	for _, entry := range entries {
-		if entry.Name() == "config.json" || strings.HasSuffix(entry.Name(), ".json") {
-			continue
-		}
-
-		if filesToDelete[entry.Name()] {
-			filePath := filepath.Join(cm.cacheDir, entry.Name())
-			if err := os.Remove(filePath); err != nil {
-				log.Printf("[Cache] WARN Failed to remove file: %s", entry.Name())
-			} else {
-				deletedFiles++
-			}
-		}
-	}
-
-	log.Printf("[Cache] Cleared %d cache items (%d files) for single URL: %s", len(keysToDelete), deletedFiles, targetURL)
-	return len(keysToDelete), nil
-}
-
-// normalizeCacheMatchURL 用于缓存清理匹配，忽略 query 和 fragment，并统一尾斜杠。
-func normalizeCacheMatchURL(rawURL string) string {
-	normalized := strings.TrimSpace(rawURL)
-	if normalized == "" {
-		return ""
-	}
-
-	if index := strings.IndexAny(normalized, "?#"); index >= 0 {
-		normalized = normalized[:index]
-	}
-
-	if normalized != "/" {
-		normalized = strings.TrimSuffix(normalized, "/")
-	}
-
-	return normalized
-}
-
-// cleanStaleFiles 清理过期和临时文件
-func (cm *CacheManager) cleanStaleFiles() error {
-	entries, err := os.ReadDir(cm.cacheDir)
-	if err != nil {
-		return fmt.Errorf("failed to read cache directory: %v", err)
-	}
-
-	for _, entry := range entries {
-		if entry.Name() == "config.json" {
-			continue // 保留配置文件
-		}
-
-		filePath := filepath.Join(cm.cacheDir, entry.Name())
-
-		// 清理临时文件
-		if strings.HasPrefix(entry.Name(), "temp-") {
-			if err := os.Remove(filePath); err != nil {
-				log.Printf("[Cache] ERR Failed to remove temp file: %s", entry.Name())
-			}
-			continue
+	for _, key := range keysToDelete {
+		removed, fileDeleted := cm.removeKey(key)
+		if removed {
+			cleared++
 		}
-
-		// 检查文件是否仍在缓存记录中
-		fileFound := false
-		cm.items.Range(func(_, value interface{}) bool {
-			item := value.(*CacheItem)
-			if item.FilePath == filePath {
-				fileFound = true
-				return false
-			}
-			return true
-		})
-
-		// 如果文件不在缓存记录中，删除它
-		if !fileFound {
-			if err := os.Remove(filePath); err != nil {
-				log.Printf("[Cache] ERR Failed to remove stale file: %s", entry.Name())
-			}
+		if fileDeleted {
+			deletedFiles++
 		}
 	}
-
-	return nil
+	cm.tags.remove(keysToDelete)
+	return cleared, deletedFiles
 }
 
-// CreateTemp 创建临时缓存文件
-func (cm *CacheManager) CreateTemp(key CacheKey, resp *http.Response) (*os.File, error) {
+// Commit 提交缓存文件; hashStr 为写入临时文件时增量计算的 SHA-256 (见 CacheWriter), 与 Put 的哈希一致
+func (cm *CacheManager) Commit(key CacheKey, tempPath string, resp *http.Response, size int64, hashStr string) error {
 	if !cm.enabled.Load() {
-		return nil, fmt.Errorf("cache is disabled")
+		os.Remove(tempPath)
+		return fmt.Errorf("cache is disabled")
 	}
-
-	// 创建临时文件
-	tempFile, err := os.CreateTemp(cm.cacheDir, "temp-*")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %v", err)
+	if hashStr == "" {
+		os.Remove(tempPath)
+		return fmt.Errorf("missing content hash")
 	}
 
-	return tempFile, nil
-}
-
-// Commit 提交缓存文件
-func (cm *CacheManager) Commit(key CacheKey, tempPath string, resp *http.Response, size int64) error {
-	if !cm.enabled.Load() {
+	// 检查是否存在相同哈希的内容（O(1) 哈希索引查找）
+	if existing := cm.blobs.acquireExisting(hashStr); existing != nil {
+		// 删除临时文件，共享现有文件
 		os.Remove(tempPath)
-		return fmt.Errorf("cache is disabled")
+		item := cm.fillItem(newItem(existing), key, resp)
+		cm.storeKey(key, item)
+		cm.tags.set(key, item.Tags)
+		log.Printf("[Cache] HIT %s %s (%s) from %s", resp.Request.Method, key.URL, formatBytes(item.Size), utils.GetRequestSource(resp.Request))
+		return nil
 	}
 
-	// 读取临时文件内容以计算哈希
-	tempData, err := os.ReadFile(tempPath)
-	if err != nil {
+	if !cm.admit(key, size) {
 		os.Remove(tempPath)
-		return fmt.Errorf("failed to read temp file: %v", err)
+		return ErrNotAdmitted
 	}
 
-	// 计算内容哈希，与Put方法保持一致
-	contentHash := sha256.Sum256(tempData)
-	hashStr := hex.EncodeToString(contentHash[:])
-
-	// 检查是否存在相同哈希的缓存项（O(1) 哈希索引查找）
-	if existing := cm.lookupByHash(hashStr); existing != nil {
-		// 删除临时文件，使用现有缓存
+	// 生成最终的缓存文件路径（使用内容哈希）
+	filePath := cm.blobPath(hashStr)
+	if err := ensureBlobDir(filePath); err != nil {
 		os.Remove(tempPath)
-		cm.items.Store(key, existing)
-		log.Printf("[Cache] HIT %s %s (%s) from %s", resp.Request.Method, key.URL, formatBytes(existing.Size), utils.GetRequestSource(resp.Request))
-		return nil
+		return err
 	}
 
-	// 生成最终的缓存文件名（使用内容哈希）
-	fileName := hashStr
-	filePath := filepath.Join(cm.cacheDir, fileName)
-
 	// 重命名临时文件
 	if err := os.Rename(tempPath, filePath); err != nil {
 		os.Remove(tempPath)
@@ -1086,46 +975,34 @@ func (cm *CacheManager) Commit(key CacheKey, tempPath string, resp *http.Respons
 	}
 
 	// 创建缓存项
-	item := &CacheItem{
-		FilePath:        filePath,
-		ContentType:     resp.Header.Get("Content-Type"),
-		ContentEncoding: resp.Header.Get("Content-Encoding"),
-		Size:            size,
-		LastAccess:      time.Now(),
-		Hash:            hashStr,
-		CreatedAt:       time.Now(),
-		AccessCount:     1,
-	}
-
-	cm.items.Store(key, item)
-	cm.hashIndex.Store(hashStr, item)
+	item := cm.fillItem(newItem(cm.blobs.acquire(hashStr, filePath, size)), key, resp)
+	cm.storeKey(key, item)
+	cm.tags.set(key, item.Tags)
+	cm.noteNewBlob(size, cm.isolatedPolicy(item.PathPrefix) != nil)
 	cm.bytesSaved.Add(size)
 	log.Printf("[Cache] NEW %s %s (%s)", resp.Request.Method, key.URL, formatBytes(size))
 	return nil
 }
 
-// lookupByHash 通过 hash 索引快速查找现有缓存项；如果索引存在但底层文件已丢失，
-// 会顺带把陈旧的索引清掉再返回 nil（保持索引一致性）
-func (cm *CacheManager) lookupByHash(hashStr string) *CacheItem {
-	value, ok := cm.hashIndex.Load(hashStr)
-	if !ok {
-		return nil
-	}
-	item := value.(*CacheItem)
-	if _, err := os.Stat(item.FilePath); err != nil {
-		// 文件已被外部清理，移除索引
-		cm.hashIndex.CompareAndDelete(hashStr, item)
-		return nil
-	}
-	return item
-}
-
 // GetConfig 获取缓存配置
 func (cm *CacheManager) GetConfig() config.CacheConfig {
+	ev := cm.evictionConfig()
 	return config.CacheConfig{
-		MaxAge:       int64(cm.maxAge.Minutes()),
-		CleanupTick:  int64(cm.cleanupTick.Minutes()),
-		MaxCacheSize: cm.maxCacheSize / (1024 * 1024 * 1024), // 转换为GB
+		MaxAge:         int64(cm.maxAge.Minutes()),
+		CleanupTick:    int64(cm.cleanupTick.Minutes()),
+		MaxCacheSize:   cm.maxCacheSize / (1024 * 1024 * 1024), // 转换为GB
+		MaxObjectSize:  cm.maxObjectSize / (1024 * 1024),       // 转换为MB
+		EvictionPolicy: ev.policy,
+		HighWatermark:  ev.high,
+		LowWatermark:   ev.low,
+		MinFreeSpace:   ev.minFree / (1024 * 1024), // 转换为MB
+
+		MemoryTierSize:    cm.memory.capacity.Load() / (1024 * 1024), // 转换为MB
+		MemoryObjectSize:  cm.memory.maxObject.Load() / 1024,         // 转换为KB
+		MemoryPromoteHits: cm.memory.promoteHits.Load(),
+
+		ScrubRate:     cm.scrub.rate.Load() / (1024 * 1024), // 转换为MB/s
+		ScrubInterval: int64(time.Duration(cm.scrub.interval.Load()) / time.Hour),
 	}
 }
 
@@ -1134,6 +1011,23 @@ func (cm *CacheManager) UpdateConfig(cacheConfig *config.CacheConfig) error {
 	if cacheConfig.MaxAge <= 0 || cacheConfig.CleanupTick <= 0 || cacheConfig.MaxCacheSize <= 0 {
 		return fmt.Errorf("invalid config values: all values must be positive")
 	}
+	if cacheConfig.MaxObjectSize < 0 {
+		return fmt.Errorf("invalid config values: max_object_size must not be negative")
+	}
+	if cacheConfig.MemoryTierSize < 0 || cacheConfig.MemoryObjectSize < 0 || cacheConfig.MemoryPromoteHits < 0 {
+		return fmt.Errorf("invalid config values: memory tier settings must not be negative")
+	}
+	if cacheConfig.ScrubRate < 0 || cacheConfig.ScrubInterval < 0 {
+		return fmt.Errorf("invalid config values: scrub settings must not be negative")
+	}
+	ev, err := newEvictionConfig(cacheConfig)
+	if err != nil {
+		return fmt.Errorf("invalid config values: %v", err)
+	}
+	cm.setEviction(ev)
+	cm.configureMemoryTier(cacheConfig)
+	cm.configureScrub(cacheConfig)
+	cm.maxObjectSize = cacheConfig.MaxObjectSize * 1024 * 1024
 
 	cm.maxAge = time.Duration(cacheConfig.MaxAge) * time.Minute
 	cm.maxCacheSize = cacheConfig.MaxCacheSize * 1024 * 1024 * 1024 // 转换为字节
@@ -1153,7 +1047,7 @@ func (cm *CacheManager) UpdateConfig(cacheConfig *config.CacheConfig) error {
 
 // startCleanup 启动清理协程
 func (cm *CacheManager) startCleanup() {
-	cm.cleanupTimer = time.NewTicker(cm.cleanupTick)
+	cm.cleanupTimer = time.NewTicker(cm.cleanupInterval())
 	go func() {
 		for {
 			select {
@@ -1211,6 +1105,7 @@ func (cm *CacheManager) Stop() {
 		cm.cleanupTimer.Stop()
 	}
 	close(cm.stopCleanup)
+	close(cm.scrub.stop)
 
 	// 停止ExtensionMatcher缓存
 	if cm.extensionMatcherCache != nil {
@@ -1218,23 +1113,9 @@ func (cm *CacheManager) Stop() {
 	}
 }
 
-// InvalidateCacheItem 使指定缓存项失效（删除内存记录和文件）
+// InvalidateCacheItem 使指定缓存项失效（删除内存记录, 文件不再被引用时一并删除）
 func (cm *CacheManager) InvalidateCacheItem(key CacheKey) {
-	// 从sync.Map中获取缓存项
-	if value, ok := cm.items.Load(key); ok {
-		item := value.(*CacheItem)
-		// 删除文件
-		if err := os.Remove(item.FilePath); err != nil {
-			log.Printf("[Cache] Failed to remove cache file %s: %v", item.FilePath, err)
-		}
-		// 删除内存记录及哈希索引
-		cm.items.Delete(key)
-		cm.hashIndex.CompareAndDelete(item.Hash, item)
+	if removed, _ := cm.removeKey(key); removed {
 		log.Printf("[Cache] Invalidated cache item for key: %s", key.URL)
 	}
-
-	// 同时从LRU缓存中删除
-	if cm.lruCache != nil {
-		cm.lruCache.Delete(key)
-	}
 }
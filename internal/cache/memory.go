@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"os"
+	"proxy-go/internal/config"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultMemoryObjectSize  = 64 * 1024 // 默认可进入内存层的最大对象 (字节)
+	defaultMemoryPromoteHits = 2         // 默认命中几次后提升到内存层
+)
+
+// memoryTier 小对象内存层: 命中足够多次的小文件把内容读入内存, 之后的命中直接从内存响应。
+//
+// 内容挂在共享的 blob 上 (内容相同的缓存键只占一份), 命中时只做一次原子读, 不加锁;
+// 容量不足时按 CLOCK 算法降级 (丢弃内存副本, 继续从磁盘文件响应)。
+type memoryTier struct {
+	mu      sync.Mutex
+	entries []*blob // CLOCK 环
+	hand    int
+	used    int64
+
+	capacity    atomic.Int64 // 总字节上限, 0 表示关闭
+	maxObject   atomic.Int64
+	promoteHits atomic.Int64
+
+	hits      atomic.Int64
+	evictions atomic.Int64
+}
+
+// configure 更新内存层限制; 容量缩小时立即降级多出的对象
+func (mt *memoryTier) configure(capacity, maxObject, promoteHits int64) {
+	if maxObject <= 0 {
+		maxObject = defaultMemoryObjectSize
+	}
+	if promoteHits <= 0 {
+		promoteHits = defaultMemoryPromoteHits
+	}
+	mt.maxObject.Store(maxObject)
+	mt.promoteHits.Store(promoteHits)
+	mt.capacity.Store(capacity)
+
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	mt.evictLocked(0, capacity)
+}
+
+// get 返回已在内存层中的内容
+func (mt *memoryTier) get(b *blob) ([]byte, bool) {
+	body := b.memBody.Load()
+	if body == nil {
+		return nil, false
+	}
+	b.memRef.Store(true)
+	mt.hits.Add(1)
+	return *body, true
+}
+
+// eligible 是否满足提升条件 (小对象且命中次数达到阈值)
+func (mt *memoryTier) eligible(item *CacheItem) bool {
+	capacity := mt.capacity.Load()
+	return capacity > 0 && item.Size <= mt.maxObject.Load() && item.Size <= capacity &&
+		atomic.LoadInt64(&item.AccessCount) > mt.promoteHits.Load()
+}
+
+// promote 把内容放入内存层, 必要时先降级其它对象
+func (mt *memoryTier) promote(b *blob, body []byte) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	if b.memBody.Load() != nil {
+		return
+	}
+	size := int64(len(body))
+	if !mt.evictLocked(size, mt.capacity.Load()) {
+		return
+	}
+	b.memRef.Store(false)
+	b.memBody.Store(&body)
+	mt.entries = append(mt.entries, b)
+	mt.used += size
+}
+
+// evictLocked CLOCK 降级直到能放下 size 字节; 最近被访问过的对象先清除访问标记获得第二次机会
+func (mt *memoryTier) evictLocked(size, capacity int64) bool {
+	if size > capacity {
+		return false
+	}
+	for mt.used+size > capacity && len(mt.entries) > 0 {
+		if mt.hand >= len(mt.entries) {
+			mt.hand = 0
+		}
+		victim := mt.entries[mt.hand]
+		if victim.memRef.CompareAndSwap(true, false) {
+			mt.hand++
+			continue
+		}
+		mt.removeAtLocked(mt.hand)
+		mt.evictions.Add(1)
+	}
+	return true
+}
+
+func (mt *memoryTier) removeAtLocked(i int) {
+	b := mt.entries[i]
+	if body := b.memBody.Swap(nil); body != nil {
+		mt.used -= int64(len(*body))
+	}
+	mt.entries = append(mt.entries[:i], mt.entries[i+1:]...)
+}
+
+// remove 丢弃 b 的内存副本 (磁盘文件被删除时调用)
+func (mt *memoryTier) remove(b *blob) {
+	if b.memBody.Load() == nil {
+		return
+	}
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	for i, e := range mt.entries {
+		if e == b {
+			mt.removeAtLocked(i)
+			if mt.hand > i {
+				mt.hand--
+			}
+			return
+		}
+	}
+}
+
+// reset 清空内存层
+func (mt *memoryTier) reset() {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	for _, b := range mt.entries {
+		b.memBody.Store(nil)
+	}
+	mt.entries = nil
+	mt.hand = 0
+	mt.used = 0
+}
+
+// stats 返回内存层对象数与占用
+func (mt *memoryTier) stats() (count int, used int64) {
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	return len(mt.entries), mt.used
+}
+
+// configureMemoryTier 按缓存配置设置内存层; 配置为空或 memory_tier_size 为 0 时关闭
+func (cm *CacheManager) configureMemoryTier(cfg *config.CacheConfig) {
+	if cfg == nil || cfg.MemoryTierSize <= 0 {
+		cm.memory.configure(0, 0, 0)
+		return
+	}
+	cm.memory.configure(cfg.MemoryTierSize*1024*1024, cfg.MemoryObjectSize*1024, cfg.MemoryPromoteHits)
+}
+
+// MemoryBody 返回缓存项在内存层中的内容; 未在内存层但满足提升条件时读入磁盘文件并提升。
+// 返回 false 时调用方应从 item.FilePath 读取。
+func (cm *CacheManager) MemoryBody(item *CacheItem) ([]byte, bool) {
+	if cm == nil || item == nil {
+		return nil, false
+	}
+	if body, ok := cm.memory.get(item.blob); ok {
+		return body, true
+	}
+	if !cm.memory.eligible(item) {
+		return nil, false
+	}
+	body, err := os.ReadFile(item.FilePath)
+	if err != nil || int64(len(body)) != item.Size {
+		return nil, false
+	}
+	cm.memory.promote(item.blob, body)
+	return body, true
+}
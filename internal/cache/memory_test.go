@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"proxy-go/internal/config"
+	"testing"
+)
+
+func TestMemoryTierPromotionAndDemotion(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), &config.CacheConfig{MaxAge: 30, CleanupTick: 5, MaxCacheSize: 1, MemoryTierSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+	// 容量 250 字节, 只放得下两个 100 字节对象
+	cm.memory.configure(250, 100, 1)
+
+	put := func(target string, size int) (CacheKey, *CacheItem) {
+		req := httptest.NewRequest("GET", target, nil)
+		key := cm.GenerateCacheKey(req, false)
+		body := bytes.Repeat([]byte(target[len(target)-1:]), size)
+		item, err := cm.Put(key, &http.Response{StatusCode: 200, Header: make(http.Header), Request: req}, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key, item
+	}
+	hit := func(key CacheKey) *CacheItem {
+		item, found, _ := cm.Get(key, httptest.NewRequest("GET", key.URL, nil), false)
+		if !found {
+			t.Fatalf("%s should hit", key.URL)
+		}
+		return item
+	}
+
+	keyA, a := put("/icon/a", 100)
+	if _, ok := cm.MemoryBody(a); ok {
+		t.Fatal("object should not be promoted before repeated access")
+	}
+	hit(keyA)
+	if body, ok := cm.MemoryBody(a); !ok || len(body) != 100 {
+		t.Fatal("object should be promoted after repeated access")
+	}
+
+	// 内存层命中不再读磁盘
+	os.Remove(a.FilePath)
+	if _, ok := cm.MemoryBody(a); !ok {
+		t.Fatal("promoted object should be served from memory")
+	}
+
+	_, big := put("/big/b", 200)
+	hit(CacheKey{URL: "/big/b"})
+	if _, ok := cm.MemoryBody(big); ok {
+		t.Fatal("objects above memory_object_size should stay on disk")
+	}
+
+	var items []*CacheItem
+	for i := 0; i < 2; i++ {
+		key, item := put(fmt.Sprintf("/icon/%d", i), 100)
+		hit(key)
+		cm.MemoryBody(item)
+		items = append(items, item)
+	}
+	stats := cm.GetStats()
+	if stats.MemoryItems != 2 || stats.MemorySize != 200 || stats.MemoryEvictions != 1 || stats.MemoryHits != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	// CLOCK: a 刚从内存层响应过, 获得第二次机会; 之后未再访问的 /icon/0 被降级
+	if a.blob.memBody.Load() == nil || items[0].blob.memBody.Load() != nil {
+		t.Fatal("object without recent memory hits should be demoted first")
+	}
+
+	// 文件被清理时内存副本一并丢弃
+	if _, err := cm.ClearCacheByURL("/icon/a", PurgeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if a.blob.memBody.Load() != nil || cm.GetStats().MemoryItems != 1 {
+		t.Fatal("purged object should leave the memory tier")
+	}
+}
@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"proxy-go/internal/config"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pathPolicy 单个路径前缀的缓存策略
+//
+// key 为缓存键策略; cache 非 nil 表示该路径配置了独立缓存 (PathConfig.CacheConfig):
+// 独立的 TTL / 容量配额 / 清理间隔, 为 0 的字段沿用全局值, 淘汰只在本路径的缓存项之间进行。
+type pathPolicy struct {
+	prefix    string
+	key       *config.CacheKeyConfig
+	cache     *config.CacheConfig
+	statusTTL map[int]time.Duration // 状态码缓存 (PathConfig.StatusCache)
+}
+
+// pathPolicySet 路径前缀 -> 缓存策略; 前缀按长度降序, 最长匹配优先 (与代理路由一致)
+type pathPolicySet struct {
+	prefixes []string
+	policies map[string]*pathPolicy
+}
+
+// pathCounter 路径级命中统计
+type pathCounter struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// PathCacheStats 路径级缓存统计
+type PathCacheStats struct {
+	TotalItems   int     `json:"total_items"`    // 缓存项数量
+	TotalSize    int64   `json:"total_size"`     // 已用大小
+	HitCount     int64   `json:"hit_count"`      // 命中次数
+	MissCount    int64   `json:"miss_count"`     // 未命中次数
+	HitRate      float64 `json:"hit_rate"`       // 命中率
+	Isolated     bool    `json:"isolated"`       // 是否使用独立缓存配置
+	MaxAge       int64   `json:"max_age"`        // 生效的缓存时间（分钟）
+	MaxCacheSize int64   `json:"max_cache_size"` // 生效的容量上限（字节）, 非独立路径为全局共享上限
+}
+
+// SetKeyPolicies 由路径映射重建路径级缓存策略表 (缓存键策略, 以及独立缓存配置 / 状态码缓存); 配置热更新时整体替换
+// 注意: 缓存键策略变更后旧键不再命中, 旧条目按正常过期 / 淘汰流程回收
+func (cm *CacheManager) SetKeyPolicies(pathMap map[string]config.PathConfig) {
+	set := &pathPolicySet{policies: make(map[string]*pathPolicy, len(pathMap))}
+	for prefix, pc := range pathMap {
+		policy := &pathPolicy{prefix: prefix}
+		if pc.CacheKey != nil {
+			key := *pc.CacheKey
+			policy.key = &key
+		}
+		if pc.CacheConfig != nil {
+			cacheConfig := *pc.CacheConfig
+			policy.cache = &cacheConfig
+		}
+		// 无效项已在保存配置时拒绝, 这里只取有效部分
+		policy.statusTTL, _ = pc.StatusCacheTTLs()
+		set.prefixes = append(set.prefixes, prefix)
+		set.policies[prefix] = policy
+	}
+	sort.Slice(set.prefixes, func(i, j int) bool {
+		return len(set.prefixes[i]) > len(set.prefixes[j])
+	})
+	cm.pathPolicies.Store(set)
+
+	// 路径清理间隔可能比全局更短, 按最小值重设定时器
+	if cm.cleanupTimer != nil {
+		cm.cleanupTimer.Reset(cm.cleanupInterval())
+	}
+}
+
+// pathPolicyFor 返回 path 命中的路径策略; 未命中任何路径返回 nil
+// 前缀按路径段边界匹配 (/img 匹配 /img 与 /img/a.png, 不匹配 /imgs/a.png);
+// 路径配置了 LowercasePath 时, 缓存键中的路径已转为小写, 前缀按忽略大小写比较
+func (cm *CacheManager) pathPolicyFor(path string) *pathPolicy {
+	set := cm.pathPolicies.Load()
+	if set == nil {
+		return nil
+	}
+	for _, prefix := range set.prefixes {
+		if len(path) < len(prefix) {
+			continue
+		}
+		policy := set.policies[prefix]
+		head := path[:len(prefix)]
+		if head != prefix && !(policy.key != nil && policy.key.LowercasePath && strings.EqualFold(head, prefix)) {
+			continue
+		}
+		if rest := path[len(prefix):]; rest == "" || rest[0] == '/' || strings.HasSuffix(prefix, "/") {
+			return policy
+		}
+	}
+	return nil
+}
+
+// pathPrefixOf 返回缓存键所属的路径前缀, 未命中返回空串
+func (cm *CacheManager) pathPrefixOf(key CacheKey) string {
+	if policy := cm.pathPolicyFor(pathOf(key)); policy != nil {
+		return policy.prefix
+	}
+	return ""
+}
+
+// pathOf 返回缓存键 URL 中的路径部分 (去掉 query)
+func pathOf(key CacheKey) string {
+	if i := strings.IndexByte(key.URL, '?'); i >= 0 {
+		return key.URL[:i]
+	}
+	return key.URL
+}
+
+// isolatedPolicy 返回 prefix 对应的独立缓存策略; 路径未配置 CacheConfig 或已被移除时返回 nil (归入全局)
+func (cm *CacheManager) isolatedPolicy(prefix string) *pathPolicy {
+	if prefix == "" {
+		return nil
+	}
+	set := cm.pathPolicies.Load()
+	if set == nil {
+		return nil
+	}
+	if policy := set.policies[prefix]; policy != nil && policy.cache != nil {
+		return policy
+	}
+	return nil
+}
+
+// poolLimits 返回缓存项所在分区 (独立路径或全局) 的 TTL / 容量上限 / 清理间隔, 为 0 的字段沿用全局值
+func (cm *CacheManager) poolLimits(policy *pathPolicy) (maxAge time.Duration, maxSize int64, tick time.Duration) {
+	maxAge, maxSize, tick = cm.maxAge, cm.maxCacheSize, cm.cleanupTick
+	if policy == nil {
+		return
+	}
+	if policy.cache.MaxAge > 0 {
+		maxAge = time.Duration(policy.cache.MaxAge) * time.Minute
+	}
+	if policy.cache.MaxCacheSize > 0 {
+		maxSize = policy.cache.MaxCacheSize * 1024 * 1024 * 1024
+	}
+	if policy.cache.CleanupTick > 0 {
+		tick = time.Duration(policy.cache.CleanupTick) * time.Minute
+	}
+	return
+}
+
+// maxAgeFor 返回缓存项生效的 TTL
+func (cm *CacheManager) maxAgeFor(item *CacheItem) time.Duration {
+	maxAge, _, _ := cm.poolLimits(cm.isolatedPolicy(item.PathPrefix))
+	return maxAge
+}
+
+// cleanupInterval 清理定时器间隔: 全局与各独立路径清理间隔中的最小值
+func (cm *CacheManager) cleanupInterval() time.Duration {
+	interval := cm.cleanupTick
+	if set := cm.pathPolicies.Load(); set != nil {
+		for _, policy := range set.policies {
+			if policy.cache != nil && policy.cache.CleanupTick > 0 {
+				if tick := time.Duration(policy.cache.CleanupTick) * time.Minute; tick < interval {
+					interval = tick
+				}
+			}
+		}
+	}
+	return interval
+}
+
+// recordPathAccess 记录路径级命中 / 未命中
+func (cm *CacheManager) recordPathAccess(key CacheKey, hit bool) {
+	prefix := cm.pathPrefixOf(key)
+	if prefix == "" {
+		return
+	}
+	// 先 Load, 避免每次命中都为 LoadOrStore 分配新计数器
+	value, ok := cm.pathCounters.Load(prefix)
+	if !ok {
+		value, _ = cm.pathCounters.LoadOrStore(prefix, &pathCounter{})
+	}
+	counter := value.(*pathCounter)
+	if hit {
+		counter.hits.Add(1)
+	} else {
+		counter.misses.Add(1)
+	}
+}
+
+// resetPathCounters 清空路径级命中统计
+func (cm *CacheManager) resetPathCounters() {
+	cm.pathCounters.Range(func(k, _ interface{}) bool {
+		cm.pathCounters.Delete(k)
+		return true
+	})
+}
+
+// getPathStats 按路径前缀汇总用量与命中率; 包含所有已配置路径以及仍有缓存项的旧路径
+func (cm *CacheManager) getPathStats() map[string]PathCacheStats {
+	stats := make(map[string]PathCacheStats)
+	if set := cm.pathPolicies.Load(); set != nil {
+		for prefix := range set.policies {
+			stats[prefix] = PathCacheStats{}
+		}
+	}
+
+	cm.items.Range(func(_, value interface{}) bool {
+		item := value.(*CacheItem)
+		if item.PathPrefix == "" {
+			return true
+		}
+		s := stats[item.PathPrefix]
+		s.TotalItems++
+		s.TotalSize += item.Size
+		stats[item.PathPrefix] = s
+		return true
+	})
+
+	cm.pathCounters.Range(func(k, v interface{}) bool {
+		prefix := k.(string)
+		counter := v.(*pathCounter)
+		s := stats[prefix]
+		s.HitCount = counter.hits.Load()
+		s.MissCount = counter.misses.Load()
+		stats[prefix] = s
+		return true
+	})
+
+	for prefix, s := range stats {
+		policy := cm.isolatedPolicy(prefix)
+		maxAge, maxSize, _ := cm.poolLimits(policy)
+		s.Isolated = policy != nil
+		s.MaxAge = int64(maxAge.Minutes())
+		s.MaxCacheSize = maxSize
+		if total := s.HitCount + s.MissCount; total > 0 {
+			s.HitRate = float64(s.HitCount) / float64(total) * 100
+		}
+		stats[prefix] = s
+	}
+	return stats
+}
+
+// cleanupPool 一个清理分区: 全局 (key 为空串) 或某个独立路径
+type cleanupPool struct {
+	maxAge  time.Duration
+	maxSize int64 // 逻辑大小配额, 0 表示不按逻辑大小淘汰 (全局分区按实际磁盘占用淘汰)
+	policy  string
+	total   int64
+	entries []cleanupEntry
+}
+
+type cleanupEntry struct {
+	key  CacheKey
+	item *CacheItem
+}
+
+// cleanupState 各分区上次清理时间, 用于按分区各自的清理间隔执行
+type cleanupState struct {
+	mu      sync.Mutex
+	lastRun map[string]time.Time
+}
+
+// duePools 返回本轮需要清理的分区, 并记录清理时间
+// 定时器按最小间隔触发, 各分区仅在距上次清理达到自身间隔时参与 (预留 1 秒误差)
+func (cm *CacheManager) duePools(now time.Time) map[string]*cleanupPool {
+	pools := make(map[string]*cleanupPool)
+
+	cm.cleanupState.mu.Lock()
+	defer cm.cleanupState.mu.Unlock()
+	if cm.cleanupState.lastRun == nil {
+		cm.cleanupState.lastRun = make(map[string]time.Time)
+	}
+
+	consider := func(name string, policy *pathPolicy) {
+		maxAge, maxSize, tick := cm.poolLimits(policy)
+		if last, ok := cm.cleanupState.lastRun[name]; ok && now.Sub(last) < tick-time.Second {
+			return
+		}
+		cm.cleanupState.lastRun[name] = now
+		if policy == nil {
+			maxSize = 0
+		}
+		pools[name] = &cleanupPool{maxAge: maxAge, maxSize: maxSize, policy: cm.poolEvictionPolicy(policy)}
+	}
+
+	consider("", nil)
+	if set := cm.pathPolicies.Load(); set != nil {
+		for prefix, policy := range set.policies {
+			if policy.cache != nil {
+				consider(prefix, policy)
+			}
+		}
+	}
+	return pools
+}
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"proxy-go/internal/config"
+	"testing"
+	"time"
+)
+
+func TestPathPoliciesIsolateQuotaAndTTL(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+	cm.SetKeyPolicies(map[string]config.PathConfig{
+		"/video": {CacheConfig: &config.CacheConfig{MaxCacheSize: 1}},
+		"/img":   {CacheConfig: &config.CacheConfig{MaxAge: 1}},
+		"/plain": {},
+	})
+
+	put := func(target string, size int64, age time.Duration) (CacheKey, *CacheItem) {
+		req := httptest.NewRequest("GET", target, nil)
+		key := cm.GenerateCacheKey(req, false)
+		item, err := cm.Put(key, &http.Response{StatusCode: 200, Header: make(http.Header), Request: req}, []byte(target))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 用逻辑大小模拟大文件, 避免测试真正写入 GB 级数据
+		item.Size = size
+		item.setLastAccess(time.Now().Add(-age))
+		return key, item
+	}
+
+	const gb = 1024 * 1024 * 1024
+	oldVideo, _ := put("/video/a.mp4", gb*6/10, 3*time.Minute)
+	newVideo, _ := put("/video/b.mp4", gb*6/10, 0)
+	imgKey, img := put("/img/logo.png", 10, 0)
+	plainKey, _ := put("/plain/a.css", 10, 2*time.Minute)
+
+	// /video 超出 1GB 配额, 只淘汰本路径最旧的项; /img 与 /plain 不受影响
+	cm.cleanup()
+	if _, ok := cm.items.Load(oldVideo); ok {
+		t.Fatal("oldest /video item should be evicted")
+	}
+	for _, key := range []CacheKey{newVideo, imgKey, plainKey} {
+		if _, ok := cm.items.Load(key); !ok {
+			t.Fatalf("%s should be kept", key.URL)
+		}
+	}
+
+	// /img 的 TTL 为 1 分钟, /plain 沿用全局 30 分钟
+	img.setLastAccess(time.Now().Add(-2 * time.Minute))
+	if _, found, _ := cm.Get(imgKey, httptest.NewRequest("GET", "/img/logo.png", nil), false); found {
+		t.Fatal("/img item should expire with the path TTL")
+	}
+	if _, found, _ := cm.Get(plainKey, httptest.NewRequest("GET", "/plain/a.css", nil), false); !found {
+		t.Fatal("/plain item should use the global TTL")
+	}
+
+	paths := cm.GetStats().Paths
+	if s := paths["/video"]; !s.Isolated || s.TotalItems != 1 || s.MaxCacheSize != gb {
+		t.Fatalf("/video stats = %+v", s)
+	}
+	if s := paths["/img"]; s.MissCount != 1 || s.MaxAge != 1 {
+		t.Fatalf("/img stats = %+v", s)
+	}
+	if s := paths["/plain"]; s.Isolated || s.HitCount != 1 || s.HitRate != 100 || s.MaxAge != 30 {
+		t.Fatalf("/plain stats = %+v", s)
+	}
+}
+
+func TestPathPolicyMatchesSegmentBoundary(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+	cm.SetKeyPolicies(map[string]config.PathConfig{
+		"/":    {},
+		"/img": {CacheKey: &config.CacheKeyConfig{LowercasePath: true}},
+	})
+
+	tests := map[string]string{
+		"/img":        "/img",
+		"/img/a.png":  "/img",
+		"/IMG/a.png":  "/img",
+		"/imgs/a.png": "/",
+		"/image":      "/",
+	}
+	for path, want := range tests {
+		policy := cm.pathPolicyFor(path)
+		if policy == nil || policy.prefix != want {
+			t.Errorf("pathPolicyFor(%q) = %v, want %q", path, policy, want)
+		}
+	}
+}
+
+func TestLoadIndexKeepsItemsWithinPathTTL(t *testing.T) {
+	dir := t.TempDir()
+	pathMap := map[string]config.PathConfig{
+		"/img":   {CacheConfig: &config.CacheConfig{MaxAge: 120}},
+		"/plain": {},
+	}
+	cm, err := NewCacheManager(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.SetKeyPolicies(pathMap)
+	put := func(target string) (CacheKey, *http.Request) {
+		req := httptest.NewRequest("GET", target, nil)
+		key := cm.GenerateCacheKey(req, false)
+		item, err := cm.Put(key, &http.Response{StatusCode: 200, Header: make(http.Header), Request: req}, []byte(target))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 超过全局 TTL (默认 30 分钟), 未超过 /img 的独立 TTL
+		item.setLastAccess(time.Now().Add(-time.Hour))
+		return key, req
+	}
+	imgKey, imgReq := put("/img/logo.png")
+	plainKey, plainReq := put("/plain/a.css")
+	if err := cm.SaveIndex(); err != nil {
+		t.Fatal(err)
+	}
+	cm.Stop()
+
+	restored, err := NewCacheManager(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(restored.Stop)
+	restored.SetKeyPolicies(pathMap)
+
+	if _, found, _ := restored.Get(imgKey, imgReq, false); !found {
+		t.Fatal("item within its path TTL should survive a restart")
+	}
+	if _, found, _ := restored.Get(plainKey, plainReq, false); found {
+		t.Fatal("item past the global TTL should expire")
+	}
+}
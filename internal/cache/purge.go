@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"time"
+)
+
+// 软清理 (soft purge): 只把缓存键标记为过期, 不删除内容文件。
+//
+// 被标记的键查找时按未命中处理, 下一次请求回源重新验证, 拿到新响应后覆盖旧内容并清除标记;
+// 回源失败 (无响应或 5xx) 时仍可返回旧内容 (stale-if-error, 见 GetStale)。
+// 标记随缓存索引持久化; 被标记的键不再刷新访问时间, 超过 TTL 后由定期清理正常删除。
+
+// PurgeOptions 缓存清理选项, 零值为立即删除
+type PurgeOptions struct {
+	Soft bool // 软清理: 只标记过期, 回源失败时仍可返回旧内容
+}
+
+// purgeWhere 按条件清理缓存键; opts.Soft 为 true 时只标记过期。返回清理的缓存键数量与删除的文件数量
+//
+// 状态码缓存没有内容可以作为旧内容返回, 软清理时也直接删除。
+func (cm *CacheManager) purgeWhere(match func(CacheKey) bool, opts PurgeOptions) (int, int) {
+	if !opts.Soft {
+		return cm.removeKeysWhere(match)
+	}
+	marked := cm.removeStatusesWhere(func(key CacheKey, _ *StatusEntry) bool {
+		return match(key)
+	})
+	now := time.Now()
+	cm.items.Range(func(k, _ interface{}) bool {
+		if key := k.(CacheKey); match(key) {
+			cm.purged.Store(key, now)
+			marked++
+		}
+		return true
+	})
+	return marked, 0
+}
+
+// purgeKeys 清理指定的缓存键, 语义同 purgeWhere
+func (cm *CacheManager) purgeKeys(keys []CacheKey, opts PurgeOptions) (int, int) {
+	if !opts.Soft {
+		cleared, deletedFiles := 0, 0
+		for _, key := range keys {
+			removed, fileDeleted := cm.removeKey(key)
+			if removed {
+				cleared++
+			}
+			if fileDeleted {
+				deletedFiles++
+			}
+		}
+		cm.tags.remove(keys)
+		return cleared, deletedFiles
+	}
+	now := time.Now()
+	marked := 0
+	for _, key := range keys {
+		if _, ok := cm.items.Load(key); ok {
+			cm.purged.Store(key, now)
+			marked++
+		}
+	}
+	return marked, 0
+}
+
+// isPurged 判断缓存键是否已被软清理
+func (cm *CacheManager) isPurged(key CacheKey) bool {
+	_, ok := cm.purged.Load(key)
+	return ok
+}
+
+// purgedCount 返回被软清理、等待重新验证的缓存键数量
+func (cm *CacheManager) purgedCount() int {
+	count := 0
+	cm.purged.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// prunePurged 清除已不存在的缓存键上的软清理标记 (标记与删除并发时可能残留)
+func (cm *CacheManager) prunePurged() {
+	cm.purged.Range(func(k, _ interface{}) bool {
+		if _, ok := cm.items.Load(k); !ok {
+			cm.purged.Delete(k)
+		}
+		return true
+	})
+}
+
+// Verb 清理日志与结果消息用的动作名
+func (opts PurgeOptions) Verb() string {
+	if opts.Soft {
+		return "Soft-purged"
+	}
+	return "Cleared"
+}
+
+// GetStale 返回已被软清理但内容仍在的缓存项, 用于回源失败时返回旧内容; 不计入命中统计, 不刷新访问时间
+func (cm *CacheManager) GetStale(key CacheKey) (*CacheItem, bool) {
+	if !cm.enabled.Load() || !cm.isPurged(key) {
+		return nil, false
+	}
+	value, ok := cm.items.Load(key)
+	if !ok {
+		return nil, false
+	}
+	item := value.(*CacheItem)
+	if !item.fileAlive() {
+		return nil, false
+	}
+	return item, true
+}
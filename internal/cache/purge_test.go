@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSoftPurge(t *testing.T) {
+	dir := t.TempDir()
+	cm, err := NewCacheManager(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+
+	put := func(path, body string) CacheKey {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		key := cm.GenerateCacheKey(req, false)
+		if _, err := cm.Put(key, &http.Response{StatusCode: 200, Header: http.Header{}, Request: req}, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	a := put("/docs/a.html", "old a")
+	b := put("/docs/b.html", "old b")
+	other := put("/img/c.png", "c")
+
+	if n, _ := cm.ClearCacheByPrefix("/docs", PurgeOptions{Soft: true}); n != 2 {
+		t.Fatalf("soft-purged %d, want 2", n)
+	}
+	req := httptest.NewRequest("GET", "/docs/a.html", nil)
+	if _, found, _ := cm.Get(a, req, false); found {
+		t.Fatal("soft-purged key should miss")
+	}
+	if !cm.Contains(a) {
+		t.Fatal("soft purge must keep the entry")
+	}
+	if _, found, _ := cm.Get(other, httptest.NewRequest("GET", "/img/c.png", nil), false); !found {
+		t.Fatal("unrelated key affected")
+	}
+	if item, ok := cm.GetStale(a); !ok || item.Size != int64(len("old a")) {
+		t.Fatalf("GetStale = %+v, %v", item, ok)
+	}
+	if _, ok := cm.GetStale(other); ok {
+		t.Fatal("fresh entry returned as stale")
+	}
+	if got := cm.GetStats().StaleItems; got != 2 {
+		t.Fatalf("stale_items = %d", got)
+	}
+
+	// 软清理标记随索引持久化
+	if err := cm.SaveIndex(); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := NewCacheManager(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(restored.Stop)
+	if _, ok := restored.GetStale(b); !ok {
+		t.Fatal("stale mark lost after restart")
+	}
+
+	// 重新验证: 回源写入新内容后清除标记
+	put("/docs/a.html", "new a")
+	if _, found, _ := cm.Get(a, req, false); !found {
+		t.Fatal("revalidated key should hit")
+	}
+	if _, ok := cm.GetStale(a); ok {
+		t.Fatal("revalidated key still stale")
+	}
+
+	// 硬清理 (默认) 仍然直接删除
+	if n, _ := cm.ClearCacheByURL("/docs/b.html", PurgeOptions{}); n != 1 || cm.Contains(b) {
+		t.Fatalf("hard purge cleared %d, contains=%v", n, cm.Contains(b))
+	}
+	if got := cm.GetStats().StaleItems; got != 0 {
+		t.Fatalf("stale_items after revalidate and hard purge = %d", got)
+	}
+}
+
+func TestClearCacheByPrefixMatchesPathSegments(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+
+	put := func(path string) CacheKey {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		key := cm.GenerateCacheKey(req, false)
+		if _, err := cm.Put(key, &http.Response{StatusCode: 200, Header: http.Header{}, Request: req}, []byte(path)); err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	put("/img")
+	put("/img/a.png")
+	put("/img?v=1")
+	images := put("/images/b.png")
+
+	if n, _ := cm.ClearCacheByPrefix("/img/", PurgeOptions{}); n != 3 {
+		t.Fatalf("cleared %d, want 3", n)
+	}
+	if !cm.Contains(images) {
+		t.Fatal("/images should not be purged by /img")
+	}
+}
@@ -0,0 +1,338 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"proxy-go/internal/config"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	quarantineDirName       = "quarantine"
+	quarantineRetention     = 7 * 24 * time.Hour
+	defaultScrubInterval    = 24 * time.Hour
+	defaultManualScrubRate  = 32 * 1024 * 1024 // 后台校验关闭时, 手动校验的默认读取速率 (字节/秒)
+	scrubStartDelay         = 10 * time.Minute // 启动后等待一段时间再开始第一轮, 避开启动时的回源高峰
+	scrubChunkSize          = 256 * 1024
+	scrubDisabledRecheckGap = time.Minute
+)
+
+// ErrScrubRunning 已有一轮完整性校验在进行
+var ErrScrubRunning = errors.New("cache scrub already running")
+
+// ScrubStats 完整性校验的进度与结果
+type ScrubStats struct {
+	Enabled      bool      `json:"enabled"`          // 后台定期校验是否开启
+	Rate         int64     `json:"rate"`             // 读取速率 (字节/秒)
+	Running      bool      `json:"running"`          // 是否正在校验
+	Prefix       string    `json:"prefix,omitempty"` // 当前 (或上一轮) 校验的 URL 前缀, 空表示全部文件
+	Checked      int       `json:"checked"`          // 当前 (或上一轮) 已校验文件数
+	Total        int       `json:"total"`            // 当前 (或上一轮) 待校验文件数
+	CheckedBytes int64     `json:"checked_bytes"`    // 当前 (或上一轮) 已读取字节数
+	StartedAt    time.Time `json:"started_at,omitempty"`
+	FinishedAt   time.Time `json:"finished_at,omitempty"`
+	Rounds       int64     `json:"rounds"`       // 已完成的轮数
+	Corrupted    int64     `json:"corrupted"`    // 累计发现的内容不一致文件 (已隔离)
+	Missing      int64     `json:"missing"`      // 累计发现的丢失文件
+	RemovedKeys  int64     `json:"removed_keys"` // 累计因此删除的缓存键
+}
+
+// scrubber 后台完整性校验: 按限定速率重新计算内容文件的 SHA-256, 与 CacheItem.Hash 不一致的文件移入隔离目录,
+// 并删除引用它的缓存键 (下次请求重新回源)。同一时间只运行一轮 (后台或手动)。
+type scrubber struct {
+	rate     atomic.Int64 // 后台校验读取速率 (字节/秒), 0 表示关闭
+	interval atomic.Int64 // 两轮之间的间隔 (纳秒)
+	stop     chan struct{}
+	run      sync.Mutex
+
+	mu    sync.Mutex
+	stats ScrubStats
+}
+
+func (s *scrubber) init() {
+	s.stop = make(chan struct{})
+	s.interval.Store(int64(defaultScrubInterval))
+}
+
+// configureScrub 按缓存配置设置后台校验; scrub_rate 为 0 时关闭 (手动校验不受影响)
+func (cm *CacheManager) configureScrub(cfg *config.CacheConfig) {
+	var rate int64
+	interval := defaultScrubInterval
+	if cfg != nil {
+		rate = cfg.ScrubRate * 1024 * 1024
+		if cfg.ScrubInterval > 0 {
+			interval = time.Duration(cfg.ScrubInterval) * time.Hour
+		}
+	}
+	cm.scrub.rate.Store(rate)
+	cm.scrub.interval.Store(int64(interval))
+}
+
+// startScrubber 启动后台校验协程; 后台校验关闭时每分钟检查一次配置
+func (cm *CacheManager) startScrubber() {
+	go func() {
+		timer := time.NewTimer(scrubStartDelay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-cm.scrub.stop:
+				return
+			case <-timer.C:
+			}
+			next := scrubDisabledRecheckGap
+			if rate := cm.scrub.rate.Load(); rate > 0 {
+				if err := cm.scrubAll(rate); err != nil && !errors.Is(err, ErrScrubRunning) {
+					log.Printf("[Cache] Scrub stopped: %v", err)
+				}
+				next = time.Duration(cm.scrub.interval.Load())
+			}
+			timer.Reset(next)
+		}
+	}()
+}
+
+// VerifyPrefix 在后台校验 URL 以 prefix 开头的缓存项引用的内容文件, 进度见 GetStats().Scrub;
+// 已有一轮校验在进行时返回 ErrScrubRunning
+func (cm *CacheManager) VerifyPrefix(prefix string) (int, error) {
+	if !cm.scrub.run.TryLock() {
+		return 0, ErrScrubRunning
+	}
+	seen := make(map[*blob]bool)
+	var blobs []*blob
+	cm.items.Range(func(k, v interface{}) bool {
+		b := v.(*CacheItem).blob
+		if strings.HasPrefix(k.(CacheKey).URL, prefix) && !seen[b] {
+			seen[b] = true
+			blobs = append(blobs, b)
+		}
+		return true
+	})
+	rate := cm.scrub.rate.Load()
+	if rate <= 0 {
+		rate = defaultManualScrubRate
+	}
+	cm.scrub.begin(prefix, len(blobs))
+	go func() {
+		defer cm.scrub.run.Unlock()
+		if err := cm.scrubBlobs(blobs, rate); err != nil {
+			log.Printf("[Cache] Scrub stopped: %v", err)
+		}
+	}()
+	return len(blobs), nil
+}
+
+// scrubAll 同步校验全部内容文件
+func (cm *CacheManager) scrubAll(rate int64) error {
+	if !cm.scrub.run.TryLock() {
+		return ErrScrubRunning
+	}
+	defer cm.scrub.run.Unlock()
+	blobs := cm.blobs.snapshot()
+	cm.scrub.begin("", len(blobs))
+	return cm.scrubBlobs(blobs, rate)
+}
+
+// scrubBlobs 逐个校验内容文件, 调用方持有 scrub.run
+func (cm *CacheManager) scrubBlobs(blobs []*blob, rate int64) error {
+	cm.cleanQuarantine(time.Now())
+	var (
+		corrupted, missing int
+		err                error
+	)
+	throttle := newScrubThrottle(rate, cm.scrub.stop)
+	for _, b := range blobs {
+		var result scrubResult
+		result, err = cm.verifyBlob(b, throttle)
+		if err != nil {
+			break
+		}
+		switch result {
+		case scrubCorrupt:
+			corrupted++
+			cm.quarantineBlob(b)
+			cm.scrub.record(b.size, 1, 0, cm.dropBlob(b))
+		case scrubMissing:
+			missing++
+			cm.scrub.record(0, 0, 1, cm.dropBlob(b))
+		default:
+			cm.scrub.record(b.size, 0, 0, 0)
+		}
+	}
+	cm.scrub.finish(err == nil)
+	log.Printf("[Cache] Scrub finished: %d files checked, %d corrupted, %d missing", len(blobs), corrupted, missing)
+	return err
+}
+
+type scrubResult int
+
+const (
+	scrubOK scrubResult = iota
+	scrubCorrupt
+	scrubMissing
+	scrubSkipped // 校验期间已被删除或替换
+)
+
+// verifyBlob 按限速读取内容文件并比对 SHA-256; 只有停止信号会返回 error, 其余读取错误记为跳过
+func (cm *CacheManager) verifyBlob(b *blob, throttle *scrubThrottle) (scrubResult, error) {
+	if !cm.blobs.owns(b.hash, b.filePath) {
+		return scrubSkipped, nil
+	}
+	file, err := os.Open(b.filePath)
+	if err != nil {
+		if os.IsNotExist(err) && cm.blobs.owns(b.hash, b.filePath) {
+			return scrubMissing, nil
+		}
+		return scrubSkipped, nil
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	buf := GetBuffer(scrubChunkSize)
+	defer PutBuffer(buf)
+	for {
+		n, readErr := file.Read(buf[:scrubChunkSize])
+		hasher.Write(buf[:n])
+		if err := throttle.wait(n); err != nil {
+			return scrubSkipped, err
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			log.Printf("[Cache] Scrub read %s failed: %v", b.filePath, readErr)
+			return scrubSkipped, nil
+		}
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) == b.hash {
+		return scrubOK, nil
+	}
+	// 读取期间 blob 被删除或重建时不处理
+	if !cm.blobs.owns(b.hash, b.filePath) {
+		return scrubSkipped, nil
+	}
+	return scrubCorrupt, nil
+}
+
+// quarantineBlob 把不一致的内容文件移入隔离目录, 保留一段时间供排查
+func (cm *CacheManager) quarantineBlob(b *blob) {
+	dir := filepath.Join(cm.cacheDir, quarantineDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("[Cache] ERR Failed to create quarantine directory: %v", err)
+		return
+	}
+	dst := filepath.Join(dir, fmt.Sprintf("%s.%d", b.hash, time.Now().Unix()))
+	if err := os.Rename(b.filePath, dst); err != nil {
+		log.Printf("[Cache] ERR Failed to quarantine %s: %v", b.filePath, err)
+		return
+	}
+	// 保留期从隔离时开始计算
+	now := time.Now()
+	os.Chtimes(dst, now, now)
+	log.Printf("[Cache] Scrub: %s (%s) hash mismatch, moved to %s", b.hash, formatBytes(b.size), dst)
+}
+
+// dropBlob 删除所有引用 b 的缓存键, 返回删除数量
+func (cm *CacheManager) dropBlob(b *blob) int {
+	removed, _ := cm.removeKeysWhere(func(key CacheKey) bool {
+		v, ok := cm.items.Load(key)
+		return ok && v.(*CacheItem).blob == b
+	})
+	return removed
+}
+
+// cleanQuarantine 删除超过保留期的隔离文件
+func (cm *CacheManager) cleanQuarantine(now time.Time) {
+	dir := filepath.Join(cm.cacheDir, quarantineDirName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && now.Sub(info.ModTime()) > quarantineRetention {
+			os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
+
+func (s *scrubber) begin(prefix string, total int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Running = true
+	s.stats.Prefix = prefix
+	s.stats.Checked = 0
+	s.stats.Total = total
+	s.stats.CheckedBytes = 0
+	s.stats.StartedAt = time.Now()
+	s.stats.FinishedAt = time.Time{}
+}
+
+func (s *scrubber) record(bytes int64, corrupted, missing int64, removedKeys int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Checked++
+	s.stats.CheckedBytes += bytes
+	s.stats.Corrupted += corrupted
+	s.stats.Missing += missing
+	s.stats.RemovedKeys += int64(removedKeys)
+}
+
+func (s *scrubber) finish(completed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Running = false
+	s.stats.FinishedAt = time.Now()
+	if completed {
+		s.stats.Rounds++
+	}
+}
+
+func (s *scrubber) snapshot() ScrubStats {
+	s.mu.Lock()
+	stats := s.stats
+	s.mu.Unlock()
+	stats.Rate = s.rate.Load()
+	stats.Enabled = stats.Rate > 0
+	return stats
+}
+
+// scrubThrottle 按字节/秒限速, 睡眠期间响应停止信号
+type scrubThrottle struct {
+	rate  int64
+	start time.Time
+	read  int64
+	stop  <-chan struct{}
+}
+
+func newScrubThrottle(rate int64, stop <-chan struct{}) *scrubThrottle {
+	return &scrubThrottle{rate: rate, start: time.Now(), stop: stop}
+}
+
+func (t *scrubThrottle) wait(n int) error {
+	t.read += int64(n)
+	due := time.Duration(float64(t.read) / float64(t.rate) * float64(time.Second))
+	if sleep := due - time.Since(t.start); sleep > 0 {
+		timer := time.NewTimer(sleep)
+		defer timer.Stop()
+		select {
+		case <-t.stop:
+			return errors.New("cache manager stopped")
+		case <-timer.C:
+		}
+		return nil
+	}
+	select {
+	case <-t.stop:
+		return errors.New("cache manager stopped")
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func putScrubItem(t *testing.T, cm *CacheManager, path, body string) (CacheKey, *CacheItem) {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	key := cm.GenerateCacheKey(req, false)
+	item, err := cm.Put(key, &http.Response{StatusCode: 200, Header: http.Header{}, Request: req}, []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, item
+}
+
+func TestScrubQuarantinesCorruptBlobs(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+
+	good, _ := putScrubItem(t, cm, "/a/good.js", "intact content")
+	bad, badItem := putScrubItem(t, cm, "/a/bad.js", "original content")
+	shared, _ := putScrubItem(t, cm, "/b/bad-copy.js", "original content")
+	gone, goneItem := putScrubItem(t, cm, "/b/gone.js", "will be deleted")
+
+	// 模拟磁盘错误: 截断一个文件, 删除另一个文件
+	if err := os.WriteFile(badItem.FilePath, []byte("origin"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(goneItem.FilePath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cm.scrubAll(1 << 40); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []CacheKey{bad, shared, gone} {
+		if cm.Contains(key) {
+			t.Errorf("%s should be removed", key.URL)
+		}
+	}
+	if !cm.Contains(good) {
+		t.Error("intact item removed")
+	}
+	stats := cm.GetStats().Scrub
+	if stats.Running || stats.Checked != 3 || stats.Total != 3 || stats.Rounds != 1 ||
+		stats.Corrupted != 1 || stats.Missing != 1 || stats.RemovedKeys != 3 {
+		t.Fatalf("scrub stats = %+v", stats)
+	}
+	quarantined, _ := filepath.Glob(filepath.Join(cm.cacheDir, quarantineDirName, badItem.Hash+".*"))
+	if len(quarantined) != 1 {
+		t.Fatalf("quarantined files = %v", quarantined)
+	}
+}
+
+func TestVerifyPrefix(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+
+	_, aItem := putScrubItem(t, cm, "/a/one.js", "one")
+	bKey, bItem := putScrubItem(t, cm, "/b/two.js", "two")
+	os.WriteFile(aItem.FilePath, []byte("bad"), 0600)
+	os.WriteFile(bItem.FilePath, []byte("bad"), 0600)
+
+	files, err := cm.VerifyPrefix("/a/")
+	if err != nil || files != 1 {
+		t.Fatalf("VerifyPrefix = %d, %v", files, err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for cm.GetStats().Scrub.Running {
+		if time.Now().After(deadline) {
+			t.Fatal("verification did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := cm.GetStats().Scrub
+	if stats.Prefix != "/a/" || stats.Corrupted != 1 {
+		t.Fatalf("scrub stats = %+v", stats)
+	}
+	// 前缀外的缓存项不校验
+	if !cm.Contains(bKey) {
+		t.Fatal("item outside prefix was verified")
+	}
+}
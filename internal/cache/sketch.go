@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	sketchDepth   = 4
+	sketchWidth   = 1 << 16 // 每行计数器数量
+	sketchMaxFreq = 15
+)
+
+// frequencySketch TinyLFU 使用的 count-min 频率草图: 4 行计数器取最小值作为估计频率,
+// 计数饱和于 15; 累计 10 倍宽度次访问后所有计数减半, 让历史热度逐渐淡出
+type frequencySketch struct {
+	seed  maphash.Seed
+	rows  [sketchDepth][]atomic.Uint32
+	adds  atomic.Int64
+	limit int64
+	ageMu sync.Mutex
+}
+
+func newFrequencySketch() *frequencySketch {
+	s := &frequencySketch{seed: maphash.MakeSeed(), limit: 10 * sketchWidth}
+	for i := range s.rows {
+		s.rows[i] = make([]atomic.Uint32, sketchWidth)
+	}
+	return s
+}
+
+// indexes 双重哈希得到 key 在各行的位置
+func (s *frequencySketch) indexes(key CacheKey) [sketchDepth]uint64 {
+	var h maphash.Hash
+	h.SetSeed(s.seed)
+	h.WriteString(key.URL)
+	h.WriteByte(0)
+	h.WriteString(key.AcceptHeaders)
+	h.WriteByte(0)
+	h.WriteString(key.UserAgent)
+	h.WriteByte(0)
+	h.WriteString(key.Vary)
+	h.WriteByte(0)
+	h.WriteString(key.Body)
+	sum := h.Sum64()
+
+	h1, h2 := sum, sum>>32|sum<<32|1
+	var idx [sketchDepth]uint64
+	for i := range idx {
+		idx[i] = (h1 + uint64(i)*h2) & (sketchWidth - 1)
+	}
+	return idx
+}
+
+// increment 记录一次访问
+func (s *frequencySketch) increment(key CacheKey) {
+	for i, idx := range s.indexes(key) {
+		counter := &s.rows[i][idx]
+		for {
+			v := counter.Load()
+			if v >= sketchMaxFreq || counter.CompareAndSwap(v, v+1) {
+				break
+			}
+		}
+	}
+	if s.adds.Add(1) >= s.limit {
+		s.age()
+	}
+}
+
+// estimate 返回 key 的估计访问频率
+func (s *frequencySketch) estimate(key CacheKey) uint32 {
+	freq := uint32(sketchMaxFreq)
+	for i, idx := range s.indexes(key) {
+		freq = min(freq, s.rows[i][idx].Load())
+	}
+	return freq
+}
+
+// age 所有计数减半; 与并发 increment 交错时允许少量误差
+func (s *frequencySketch) age() {
+	if !s.ageMu.TryLock() {
+		return
+	}
+	defer s.ageMu.Unlock()
+	if s.adds.Load() < s.limit {
+		return
+	}
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j].Store(s.rows[i][j].Load() / 2)
+		}
+	}
+	s.adds.Store(0)
+}
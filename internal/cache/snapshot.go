@@ -0,0 +1,375 @@
+package cache
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 快照为 tar 流 (导出时按 SnapshotFormat 以 gzip 或 zstd 压缩): 先是 snapshotIndexName 记录缓存键与元数据, 随后是 blobs/<sha256> 内容文件,
+// 同一内容只写一次。
+const (
+	snapshotVersion    = 1
+	snapshotIndexName  = "index.json"
+	snapshotBlobPrefix = "blobs/"
+	maxSnapshotIndex   = 256 * 1024 * 1024 // 快照索引大小上限
+)
+
+// ErrInvalidSnapshot 快照格式不正确
+var ErrInvalidSnapshot = errors.New("invalid cache snapshot")
+
+// SnapshotFormat 快照压缩格式; 导入时按魔数自动识别, 不需要指定
+type SnapshotFormat string
+
+const (
+	SnapshotGzip SnapshotFormat = "gzip" // tar.gz, 默认
+	SnapshotZstd SnapshotFormat = "zstd" // tar.zst, 压缩与解压更快, 适合大快照
+)
+
+// ParseSnapshotFormat 解析导出格式, 空串为 gzip
+func ParseSnapshotFormat(s string) (SnapshotFormat, error) {
+	switch SnapshotFormat(strings.ToLower(strings.TrimSpace(s))) {
+	case "", SnapshotGzip:
+		return SnapshotGzip, nil
+	case SnapshotZstd:
+		return SnapshotZstd, nil
+	}
+	return "", fmt.Errorf("unsupported snapshot format: %s", s)
+}
+
+// newWriter 返回写入压缩流的 Writer, Close 时写完压缩流尾部 (不关闭 w)
+func (f SnapshotFormat) newWriter(w io.Writer) (io.WriteCloser, error) {
+	if f == SnapshotZstd {
+		return zstd.NewWriter(w)
+	}
+	return gzip.NewWriter(w), nil
+}
+
+// SnapshotFilter 导出过滤条件, 零值表示导出全部
+type SnapshotFilter struct {
+	PathPrefix string        // 只导出该路径前缀下的缓存键 (按路径段匹配, /img 不包含 /images)
+	MaxAge     time.Duration // 只导出最近 MaxAge 内写入的缓存项
+}
+
+// SnapshotExportResult 导出统计
+type SnapshotExportResult struct {
+	Entries int   `json:"entries"` // 缓存键数
+	Blobs   int   `json:"blobs"`   // 内容文件数
+	Bytes   int64 `json:"bytes"`   // 内容文件总大小
+}
+
+// SnapshotImportResult 导入统计
+type SnapshotImportResult struct {
+	Entries        int   `json:"entries"`         // 登记的缓存键数
+	SkippedEntries int   `json:"skipped_entries"` // 本地已有 / 已过期 / 缺少内容而跳过的缓存键
+	Blobs          int   `json:"blobs"`           // 新写入的内容文件
+	ExistingBlobs  int   `json:"existing_blobs"`  // 本地已有而跳过的内容文件
+	InvalidBlobs   int   `json:"invalid_blobs"`   // 哈希校验失败或不在索引中的内容文件
+	Bytes          int64 `json:"bytes"`           // 新写入的字节数
+}
+
+type snapshotIndex struct {
+	Version   int          `json:"version"`
+	CreatedAt time.Time    `json:"created_at"`
+	Entries   []indexEntry `json:"entries"`
+}
+
+// Export 把符合 filter 的缓存项写成 format 压缩的 tar 快照
+func (cm *CacheManager) Export(w io.Writer, filter SnapshotFilter, format SnapshotFormat) (SnapshotExportResult, error) {
+	var result SnapshotExportResult
+	now := time.Now()
+	filter.PathPrefix = strings.TrimSuffix(filter.PathPrefix, "/")
+
+	index := snapshotIndex{Version: snapshotVersion, CreatedAt: now}
+	blobs := make(map[string]*CacheItem)
+	var order []string
+	cm.items.Range(func(k, v interface{}) bool {
+		key := k.(CacheKey)
+		item := v.(*CacheItem)
+		if filter.PathPrefix != "" && !underPathPrefix(key.URL, filter.PathPrefix) {
+			return true
+		}
+		if filter.MaxAge > 0 && now.Sub(item.CreatedAt) > filter.MaxAge {
+			return true
+		}
+		if now.Sub(item.LastAccess()) > cm.maxAgeFor(item) {
+			return true
+		}
+		index.Entries = append(index.Entries, cm.indexEntryOf(key, item))
+		if _, ok := blobs[item.Hash]; !ok {
+			blobs[item.Hash] = item
+			order = append(order, item.Hash)
+		}
+		return true
+	})
+
+	zw, err := format.newWriter(w)
+	if err != nil {
+		return result, err
+	}
+	tw := tar.NewWriter(zw)
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return result, err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: snapshotIndexName, Mode: 0644, Size: int64(len(data)), ModTime: now}); err != nil {
+		return result, err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return result, err
+	}
+	result.Entries = len(index.Entries)
+
+	for _, hash := range order {
+		n, err := writeSnapshotBlob(tw, blobs[hash])
+		if err != nil {
+			return result, err
+		}
+		if n < 0 {
+			continue
+		}
+		result.Blobs++
+		result.Bytes += n
+	}
+
+	if err := tw.Close(); err != nil {
+		return result, err
+	}
+	if err := zw.Close(); err != nil {
+		return result, err
+	}
+	log.Printf("[Cache] Exported %d cache items (%d files, %s)", result.Entries, result.Blobs, formatBytes(result.Bytes))
+	return result, nil
+}
+
+// underPathPrefix 判断缓存键 URL 是否在 prefix 路径下 (prefix 本身或其子路径, 可带 query)
+func underPathPrefix(keyURL, prefix string) bool {
+	rest, ok := strings.CutPrefix(keyURL, prefix)
+	return ok && (rest == "" || rest[0] == '/' || rest[0] == '?')
+}
+
+// writeSnapshotBlob 写入一个内容文件; 文件已被删除时跳过并返回 -1 (导入端会丢弃缺少内容的缓存键)
+func writeSnapshotBlob(tw *tar.Writer, item *CacheItem) (int64, error) {
+	file, err := os.Open(item.FilePath)
+	if err != nil {
+		return -1, nil
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return -1, nil
+	}
+
+	header := &tar.Header{
+		Name:    snapshotBlobPrefix + item.Hash,
+		Mode:    0644,
+		Size:    info.Size(),
+		ModTime: item.CreatedAt,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return 0, err
+	}
+	if _, err := io.Copy(tw, io.LimitReader(file, info.Size())); err != nil {
+		return 0, fmt.Errorf("failed to export %s: %v", item.Hash, err)
+	}
+	return info.Size(), nil
+}
+
+// Import 导入快照 (tar / tar.gz / tar.zst, 按魔数识别): 内容按 SHA-256 校验后写入, 本地已有的内容与缓存键跳过
+func (cm *CacheManager) Import(r io.Reader) (SnapshotImportResult, error) {
+	var result SnapshotImportResult
+	if !cm.enabled.Load() {
+		return result, fmt.Errorf("cache is disabled")
+	}
+
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return result, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		defer gz.Close()
+		src = gz
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return result, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+		}
+		defer zr.Close()
+		src = zr
+	}
+	tr := tar.NewReader(src)
+
+	var index *snapshotIndex
+	expected := make(map[string]bool) // 索引引用的内容
+	imported := make(map[string]int64)
+	fail := func(err error) (SnapshotImportResult, error) {
+		cm.removeUnowned(imported)
+		return result, err
+	}
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(fmt.Errorf("%w: %v", ErrInvalidSnapshot, err))
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if header.Name == snapshotIndexName {
+			if index != nil || header.Size > maxSnapshotIndex {
+				return fail(fmt.Errorf("%w: unexpected index", ErrInvalidSnapshot))
+			}
+			index = &snapshotIndex{}
+			if err := json.NewDecoder(io.LimitReader(tr, header.Size)).Decode(index); err != nil {
+				return fail(fmt.Errorf("%w: %v", ErrInvalidSnapshot, err))
+			}
+			if index.Version != snapshotVersion {
+				return fail(fmt.Errorf("%w: unsupported version %d", ErrInvalidSnapshot, index.Version))
+			}
+			for _, e := range index.Entries {
+				expected[e.Hash] = true
+			}
+			continue
+		}
+
+		hash, ok := strings.CutPrefix(header.Name, snapshotBlobPrefix)
+		if !ok {
+			continue
+		}
+		if index == nil {
+			return fail(fmt.Errorf("%w: index must come first", ErrInvalidSnapshot))
+		}
+		if !isBlobName(hash) || !expected[hash] {
+			result.InvalidBlobs++
+			continue
+		}
+		if _, done := imported[hash]; done || cm.blobs.refs(hash) > 0 {
+			result.ExistingBlobs++
+			continue
+		}
+
+		size, err := cm.importBlob(hash, tr)
+		if err != nil {
+			if errors.Is(err, errBlobHashMismatch) {
+				log.Printf("[Cache] WARN Snapshot blob %s failed hash verification", hash)
+				result.InvalidBlobs++
+				continue
+			}
+			return fail(err)
+		}
+		imported[hash] = size
+		result.Blobs++
+		result.Bytes += size
+	}
+	if index == nil {
+		return result, fmt.Errorf("%w: missing index", ErrInvalidSnapshot)
+	}
+
+	now := time.Now()
+	for _, e := range index.Entries {
+		if _, exists := cm.items.Load(e.Key); exists || !isBlobName(e.Hash) {
+			result.SkippedEntries++
+			continue
+		}
+		e.PathPrefix = cm.pathPrefixOf(e.Key)
+		if size, ok := imported[e.Hash]; ok {
+			e.Size = size
+		}
+		if now.Sub(e.LastAccess) > cm.maxAgeFor(&CacheItem{PathPrefix: e.PathPrefix}) {
+			result.SkippedEntries++
+			continue
+		}
+		if !cm.restoreEntry(e) {
+			result.SkippedEntries++
+			continue
+		}
+		result.Entries++
+	}
+
+	// 所有缓存键都被跳过的内容不会被引用, 直接删除
+	cm.removeUnowned(imported)
+	for _, size := range imported {
+		cm.noteNewBlob(size, false)
+	}
+
+	if err := cm.SaveIndex(); err != nil {
+		log.Printf("[Cache] ERR Failed to save cache index: %v", err)
+	}
+	log.Printf("[Cache] Imported %d cache items (%d new files, %s; %d existing, %d invalid, %d skipped)",
+		result.Entries, result.Blobs, formatBytes(result.Bytes), result.ExistingBlobs, result.InvalidBlobs, result.SkippedEntries)
+	return result, nil
+}
+
+var errBlobHashMismatch = errors.New("blob hash mismatch")
+
+// 压缩流魔数
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// importBlob 把内容写入临时文件并校验 SHA-256, 通过后移动到 blob 路径
+func (cm *CacheManager) importBlob(hash string, r io.Reader) (int64, error) {
+	tmp, err := os.CreateTemp(cm.tempDir(), "temp-import-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp file: %v", err)
+	}
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), r)
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, fmt.Errorf("failed to import %s: %v", hash, err)
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != hash {
+		os.Remove(tmp.Name())
+		return 0, errBlobHashMismatch
+	}
+
+	filePath := cm.blobPath(hash)
+	if err := ensureBlobDir(filePath); err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		os.Remove(tmp.Name())
+		return 0, fmt.Errorf("failed to rename temp file: %v", err)
+	}
+	return size, nil
+}
+
+// removeUnowned 删除导入后未被任何缓存键引用的内容文件
+func (cm *CacheManager) removeUnowned(imported map[string]int64) {
+	for hash := range imported {
+		if cm.blobs.refs(hash) == 0 {
+			os.Remove(cm.blobPath(hash))
+			delete(imported, hash)
+		}
+	}
+}
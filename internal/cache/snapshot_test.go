@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func putSnapshotItem(t *testing.T, cm *CacheManager, path, body string) CacheKey {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	key := cm.GenerateCacheKey(req, false)
+	header := make(http.Header)
+	header.Set("Content-Type", "text/plain")
+	header.Set("Surrogate-Key", "snap")
+	if _, err := cm.Put(key, &http.Response{StatusCode: 200, Header: header, Request: req}, []byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestSnapshotExportImport(t *testing.T) {
+	src, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(src.Stop)
+	a := putSnapshotItem(t, src, "/static/a.css", "shared")
+	b := putSnapshotItem(t, src, "/static/b.css", "shared")
+	putSnapshotItem(t, src, "/static/c.css", "only-c")
+	putSnapshotItem(t, src, "/api/data.json", "api")
+	putSnapshotItem(t, src, "/statics/d.css", "not-under-prefix")
+
+	var buf bytes.Buffer
+	exported, err := src.Export(&buf, SnapshotFilter{PathPrefix: "/static/"}, SnapshotGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported.Entries != 3 || exported.Blobs != 2 {
+		t.Fatalf("export = %+v", exported)
+	}
+
+	dst, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(dst.Stop)
+	// 目标节点已有 c.css 的内容, 导入时应跳过该文件
+	putSnapshotItem(t, dst, "/other/c.css", "only-c")
+
+	imported, err := dst.Import(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Entries != 3 || imported.Blobs != 1 || imported.ExistingBlobs != 1 || imported.InvalidBlobs != 0 {
+		t.Fatalf("import = %+v", imported)
+	}
+
+	req := httptest.NewRequest("GET", "/static/a.css", nil)
+	item, found, _ := dst.Get(a, req, false)
+	if !found || item.ContentType != "text/plain" || item.FilePath != dst.blobPath(item.Hash) {
+		t.Fatalf("imported item = %+v found=%v", item, found)
+	}
+	if data, _ := os.ReadFile(item.FilePath); string(data) != "shared" {
+		t.Fatalf("imported body = %q", data)
+	}
+	if got := dst.blobs.refs(item.Hash); got != 2 {
+		t.Fatalf("refs = %d, want 2 (a.css and b.css share content)", got)
+	}
+	if keys := dst.tags.keysFor([]string{"snap"}); len(keys) != 4 {
+		t.Fatalf("tagged keys = %d", len(keys))
+	}
+	if _, ok := dst.items.Load(b); !ok {
+		t.Fatal("b.css not imported")
+	}
+
+	// 再次导入: 缓存键都已存在
+	again, err := dst.Import(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Entries != 0 || again.SkippedEntries != 3 || again.Blobs != 0 {
+		t.Fatalf("second import = %+v", again)
+	}
+}
+
+func TestSnapshotImportRejectsTamperedBlob(t *testing.T) {
+	body := []byte("genuine")
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	index, _ := json.Marshal(snapshotIndex{
+		Version: snapshotVersion,
+		Entries: []indexEntry{{
+			Key:        CacheKey{URL: "/x.js"},
+			Hash:       hash,
+			Size:       int64(len(body)),
+			CreatedAt:  time.Now(),
+			LastAccess: time.Now(),
+		}},
+	})
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: snapshotIndexName, Mode: 0644, Size: int64(len(index))})
+	tw.Write(index)
+	tampered := []byte("tampered")
+	tw.WriteHeader(&tar.Header{Name: snapshotBlobPrefix + hash, Mode: 0644, Size: int64(len(tampered))})
+	tw.Write(tampered)
+	tw.Close()
+
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+	result, err := cm.Import(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.InvalidBlobs != 1 || result.Entries != 0 || result.SkippedEntries != 1 {
+		t.Fatalf("import = %+v", result)
+	}
+	if _, err := os.Stat(cm.blobPath(hash)); !os.IsNotExist(err) {
+		t.Fatalf("tampered blob written: %v", err)
+	}
+
+	if _, err := cm.Import(bytes.NewReader([]byte("not a snapshot"))); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestSnapshotZstdRoundTrip(t *testing.T) {
+	src, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(src.Stop)
+	key := putSnapshotItem(t, src, "/static/a.css", "zstd body")
+
+	format, err := ParseSnapshotFormat("ZSTD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := src.Export(&buf, SnapshotFilter{}, format); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), zstdMagic) {
+		t.Fatalf("export does not start with the zstd magic: % x", buf.Bytes()[:4])
+	}
+
+	dst, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(dst.Stop)
+	imported, err := dst.Import(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Entries != 1 || imported.Blobs != 1 {
+		t.Fatalf("import = %+v", imported)
+	}
+	if _, ok := dst.items.Load(key); !ok {
+		t.Fatal("a.css not imported")
+	}
+
+	if _, err := ParseSnapshotFormat("xz"); err == nil {
+		t.Fatal("unknown format should be rejected")
+	}
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// statusHeaders 状态码缓存保存的响应头, 其余响应头 (含响应体) 不保存
+var statusHeaders = []string{"Location", "Content-Type", "Cache-Control"}
+
+// StatusEntry 缓存的非 200 响应 (404 / 410 / 301 / 302 / 308), 只保存状态码与少量响应头
+//
+// 与内容缓存共用缓存键, 但单独存放 (没有磁盘文件); 同一个键写入内容缓存时状态码缓存被删除, 反之亦然。
+type StatusEntry struct {
+	StatusCode int
+	Header     http.Header
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+// statusTTLFor 返回 key 所在路径为 status 配置的缓存时间, 0 表示不缓存
+func (cm *CacheManager) statusTTLFor(key CacheKey, status int) time.Duration {
+	policy := cm.pathPolicyFor(pathOf(key))
+	if policy == nil {
+		return 0
+	}
+	return policy.statusTTL[status]
+}
+
+// PutStatus 按路径的 StatusCache 配置缓存非 200 响应; 状态码未配置时返回 false
+func (cm *CacheManager) PutStatus(key CacheKey, resp *http.Response) bool {
+	if !cm.enabled.Load() {
+		return false
+	}
+	ttl := cm.statusTTLFor(key, resp.StatusCode)
+	if ttl <= 0 {
+		return false
+	}
+
+	header := make(http.Header)
+	for _, name := range statusHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+	now := time.Now()
+	cm.removeKey(key)
+	cm.statuses.Store(key, &StatusEntry{
+		StatusCode: resp.StatusCode,
+		Header:     header,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(ttl),
+	})
+	log.Printf("[Cache] NEW %s %s (status %d, ttl %s)", resp.Request.Method, key.URL, resp.StatusCode, ttl)
+	return true
+}
+
+// GetStatus 查找未过期的状态码缓存; 命中计入缓存命中
+func (cm *CacheManager) GetStatus(key CacheKey) (*StatusEntry, bool) {
+	if !cm.enabled.Load() {
+		return nil, false
+	}
+	value, ok := cm.statuses.Load(key)
+	if !ok {
+		return nil, false
+	}
+	entry := value.(*StatusEntry)
+	if time.Now().After(entry.ExpiresAt) {
+		cm.statuses.CompareAndDelete(key, entry)
+		return nil, false
+	}
+	cm.hitCount.Add(1)
+	cm.recordPathAccess(key, true)
+	return entry, true
+}
+
+// removeStatusesWhere 删除满足条件的状态码缓存, 返回删除数量
+func (cm *CacheManager) removeStatusesWhere(match func(CacheKey, *StatusEntry) bool) int {
+	removed := 0
+	cm.statuses.Range(func(k, v interface{}) bool {
+		if match(k.(CacheKey), v.(*StatusEntry)) {
+			if _, ok := cm.statuses.LoadAndDelete(k); ok {
+				removed++
+			}
+		}
+		return true
+	})
+	return removed
+}
+
+// expireStatuses 清理已过期的状态码缓存
+func (cm *CacheManager) expireStatuses(now time.Time) {
+	cm.removeStatusesWhere(func(_ CacheKey, entry *StatusEntry) bool {
+		return now.After(entry.ExpiresAt)
+	})
+}
+
+// statusIndexEntries 生成状态码缓存的索引条目
+func (cm *CacheManager) statusIndexEntries() []indexEntry {
+	var entries []indexEntry
+	cm.statuses.Range(func(k, v interface{}) bool {
+		entry := v.(*StatusEntry)
+		entries = append(entries, indexEntry{
+			Key:        k.(CacheKey),
+			Status:     entry.StatusCode,
+			Header:     entry.Header,
+			CreatedAt:  entry.CreatedAt,
+			LastAccess: entry.CreatedAt,
+			ExpiresAt:  entry.ExpiresAt,
+		})
+		return true
+	})
+	return entries
+}
+
+// restoreStatus 从索引恢复未过期的状态码缓存
+func (cm *CacheManager) restoreStatus(e indexEntry) bool {
+	if !time.Now().Before(e.ExpiresAt) {
+		return false
+	}
+	cm.statuses.Store(e.Key, &StatusEntry{
+		StatusCode: e.Status,
+		Header:     e.Header,
+		CreatedAt:  e.CreatedAt,
+		ExpiresAt:  e.ExpiresAt,
+	})
+	return true
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"proxy-go/internal/config"
+	"testing"
+	"time"
+)
+
+func statusResponse(req *http.Request, status int, location string) *http.Response {
+	header := make(http.Header)
+	if location != "" {
+		header.Set("Location", location)
+	}
+	header.Set("Set-Cookie", "session=secret")
+	return &http.Response{StatusCode: status, Header: header, Request: req}
+}
+
+func TestStatusCacheLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	cm, err := NewCacheManager(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm.SetKeyPolicies(map[string]config.PathConfig{
+		"/static": {StatusCache: map[string]int{"404": 60, "301": 3600}},
+		"/api":    {},
+	})
+
+	missing := httptest.NewRequest("GET", "/static/missing.png", nil)
+	missingKey := cm.GenerateCacheKey(missing, false)
+	if !cm.PutStatus(missingKey, statusResponse(missing, http.StatusNotFound, "")) {
+		t.Fatal("404 should be cached")
+	}
+	moved := httptest.NewRequest("GET", "/static/old.css", nil)
+	movedKey := cm.GenerateCacheKey(moved, false)
+	if !cm.PutStatus(movedKey, statusResponse(moved, http.StatusMovedPermanently, "/static/new.css")) {
+		t.Fatal("301 should be cached")
+	}
+	gone := httptest.NewRequest("GET", "/static/gone.css", nil)
+	if cm.PutStatus(cm.GenerateCacheKey(gone, false), statusResponse(gone, http.StatusGone, "")) {
+		t.Fatal("410 is not configured for /static")
+	}
+	api := httptest.NewRequest("GET", "/api/missing", nil)
+	if cm.PutStatus(cm.GenerateCacheKey(api, false), statusResponse(api, http.StatusNotFound, "")) {
+		t.Fatal("/api has no StatusCache")
+	}
+
+	entry, hit := cm.GetStatus(movedKey)
+	if !hit || entry.StatusCode != http.StatusMovedPermanently || entry.Header.Get("Location") != "/static/new.css" {
+		t.Fatalf("entry = %+v hit=%v", entry, hit)
+	}
+	if entry.Header.Get("Set-Cookie") != "" {
+		t.Fatal("only whitelisted headers should be stored")
+	}
+	if ttl := entry.ExpiresAt.Sub(entry.CreatedAt); ttl != time.Hour {
+		t.Fatalf("ttl = %s", ttl)
+	}
+
+	// 持久化后重启恢复
+	if err := cm.SaveIndex(); err != nil {
+		t.Fatal(err)
+	}
+	cm.Stop()
+	cm, err = NewCacheManager(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+	cm.SetKeyPolicies(map[string]config.PathConfig{
+		"/static": {StatusCache: map[string]int{"404": 60, "301": 3600}},
+	})
+	if _, hit := cm.GetStatus(missingKey); !hit {
+		t.Fatal("404 entry not restored from index")
+	}
+	if stats := cm.GetStats(); stats.StatusEntries != 2 {
+		t.Fatalf("status entries = %d", stats.StatusEntries)
+	}
+
+	// 内容缓存写入后状态码缓存失效
+	if _, err := cm.Put(missingKey, &http.Response{StatusCode: 200, Header: make(http.Header), Request: missing}, []byte("png")); err != nil {
+		t.Fatal(err)
+	}
+	if _, hit := cm.GetStatus(missingKey); hit {
+		t.Fatal("status entry should be replaced by content")
+	}
+
+	// 按 URL / 前缀清理同样覆盖状态码缓存
+	if n, _ := cm.ClearCacheByURL("/static/old.css", PurgeOptions{}); n != 1 {
+		t.Fatalf("cleared = %d", n)
+	}
+	if _, hit := cm.GetStatus(movedKey); hit {
+		t.Fatal("301 entry should be purged")
+	}
+	cm.PutStatus(movedKey, statusResponse(moved, http.StatusMovedPermanently, "/static/new.css"))
+	if n, _ := cm.ClearCacheByPrefix("/static", PurgeOptions{}); n != 2 {
+		t.Fatalf("cleared by prefix = %d", n)
+	}
+}
+
+func TestStatusCacheExpires(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+	cm.SetKeyPolicies(map[string]config.PathConfig{"/": {StatusCache: map[string]int{"404": 1}}})
+
+	req := httptest.NewRequest("GET", "/a", nil)
+	key := cm.GenerateCacheKey(req, false)
+	cm.PutStatus(key, statusResponse(req, http.StatusNotFound, ""))
+	value, _ := cm.statuses.Load(key)
+	value.(*StatusEntry).ExpiresAt = time.Now().Add(-time.Second)
+
+	if _, hit := cm.GetStatus(key); hit {
+		t.Fatal("expired entry should miss")
+	}
+	if _, ok := cm.statuses.Load(key); ok {
+		t.Fatal("expired entry should be dropped")
+	}
+}
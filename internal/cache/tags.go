@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultTagHeaders 默认读取的上游缓存标签头 (Fastly Surrogate-Key / Cloudflare Cache-Tag)
+var DefaultTagHeaders = []string{"Surrogate-Key", "Cache-Tag"}
+
+const (
+	maxTagsPerItem = 128 // 单个响应最多记录的标签数
+	maxTagLength   = 256 // 单个标签最大长度, 超出的标签忽略
+)
+
+// tagIndex 标签 -> 缓存键 的倒排索引, 按键记录标签以便重写 / 清理时同步更新
+type tagIndex struct {
+	mu      sync.RWMutex
+	keys    map[string]map[CacheKey]struct{}
+	keyTags map[CacheKey][]string
+}
+
+func newTagIndex() *tagIndex {
+	return &tagIndex{
+		keys:    make(map[string]map[CacheKey]struct{}),
+		keyTags: make(map[CacheKey][]string),
+	}
+}
+
+// set 覆盖 key 的标签; tags 为空时移除 key 的所有标签
+func (ti *tagIndex) set(key CacheKey, tags []string) {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	ti.removeLocked(key)
+	if len(tags) == 0 {
+		return
+	}
+	ti.keyTags[key] = tags
+	for _, tag := range tags {
+		set := ti.keys[tag]
+		if set == nil {
+			set = make(map[CacheKey]struct{})
+			ti.keys[tag] = set
+		}
+		set[key] = struct{}{}
+	}
+}
+
+func (ti *tagIndex) removeLocked(key CacheKey) {
+	for _, tag := range ti.keyTags[key] {
+		if set := ti.keys[tag]; set != nil {
+			delete(set, key)
+			if len(set) == 0 {
+				delete(ti.keys, tag)
+			}
+		}
+	}
+	delete(ti.keyTags, key)
+}
+
+// remove 移除一组 key 的标签记录
+func (ti *tagIndex) remove(keys []CacheKey) {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	for _, key := range keys {
+		ti.removeLocked(key)
+	}
+}
+
+// tagsOf 返回 key 的标签
+func (ti *tagIndex) tagsOf(key CacheKey) []string {
+	ti.mu.RLock()
+	defer ti.mu.RUnlock()
+	return ti.keyTags[key]
+}
+
+// keysFor 返回带有任一标签的缓存键 (去重)
+func (ti *tagIndex) keysFor(tags []string) []CacheKey {
+	ti.mu.RLock()
+	defer ti.mu.RUnlock()
+	seen := make(map[CacheKey]struct{})
+	var keys []CacheKey
+	for _, tag := range tags {
+		for key := range ti.keys[tag] {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// prune 丢弃已不在缓存中的 key (过期 / 淘汰 / 按 URL 清理后残留的记录)
+func (ti *tagIndex) prune(alive func(CacheKey) bool) {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	for key := range ti.keyTags {
+		if !alive(key) {
+			ti.removeLocked(key)
+		}
+	}
+}
+
+// reset 清空索引
+func (ti *tagIndex) reset() {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	ti.keys = make(map[string]map[CacheKey]struct{})
+	ti.keyTags = make(map[CacheKey][]string)
+}
+
+// count 返回标签数量
+func (ti *tagIndex) count() int {
+	ti.mu.RLock()
+	defer ti.mu.RUnlock()
+	return len(ti.keys)
+}
+
+// SetTagHeaders 设置读取缓存标签的响应头, 为空时使用 DefaultTagHeaders
+func (cm *CacheManager) SetTagHeaders(headers []string) {
+	var cleaned []string
+	for _, h := range headers {
+		if h = strings.TrimSpace(h); h != "" {
+			cleaned = append(cleaned, h)
+		}
+	}
+	if len(cleaned) == 0 {
+		cleaned = DefaultTagHeaders
+	}
+	cm.tagHeaders.Store(&cleaned)
+}
+
+// responseTags 从上游响应头解析缓存标签; 同时兼容空格分隔 (Surrogate-Key) 与逗号分隔 (Cache-Tag)
+func (cm *CacheManager) responseTags(resp *http.Response) []string {
+	if resp == nil {
+		return nil
+	}
+	headers := DefaultTagHeaders
+	if p := cm.tagHeaders.Load(); p != nil {
+		headers = *p
+	}
+
+	var tags []string
+	seen := make(map[string]struct{})
+	for _, name := range headers {
+		for _, value := range resp.Header.Values(name) {
+			for _, tag := range ParseTags(value) {
+				if _, ok := seen[tag]; ok {
+					continue
+				}
+				if len(tags) >= maxTagsPerItem {
+					return tags
+				}
+				seen[tag] = struct{}{}
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// ParseTags 解析标签列表, 空格与逗号均视为分隔符; 过长的标签被忽略
+func ParseTags(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	tags := fields[:0]
+	for _, f := range fields {
+		if len(f) <= maxTagLength {
+			tags = append(tags, f)
+		}
+	}
+	return tags
+}
+
+// ClearCacheByTags 清除带有任一指定标签的缓存项, 返回清除的缓存键数量; opts.Soft 为 true 时只标记过期 (软清理)
+func (cm *CacheManager) ClearCacheByTags(tags []string, opts PurgeOptions) (int, error) {
+	cleared, deletedFiles := cm.purgeKeys(cm.tags.keysFor(tags), opts)
+
+	log.Printf("[Cache] %s %d cache items (%d files) for %d tags", opts.Verb(), cleared, deletedFiles, len(tags))
+	return cleared, nil
+}
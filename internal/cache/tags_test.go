@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func putTagged(t *testing.T, cm *CacheManager, target, header, tags string) CacheKey {
+	t.Helper()
+	req := httptest.NewRequest("GET", target, nil)
+	resp := &http.Response{StatusCode: 200, Header: make(http.Header), Request: req}
+	if tags != "" {
+		resp.Header.Set(header, tags)
+	}
+	key := cm.GenerateCacheKey(req, false)
+	if _, err := cm.Put(key, resp, []byte(target)); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestClearCacheByTags(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+
+	article := putTagged(t, cm, "/img/42.jpg", "Surrogate-Key", "article-42 author-7")
+	thumb := putTagged(t, cm, "/img/42-thumb.jpg", "Cache-Tag", "article-42,thumbs")
+	other := putTagged(t, cm, "/api/43.json", "Surrogate-Key", "article-43 author-7")
+	putTagged(t, cm, "/plain.css", "", "")
+
+	if n, _ := cm.ClearCacheByTags([]string{"article-42"}, PurgeOptions{}); n != 2 {
+		t.Fatalf("cleared %d, want 2", n)
+	}
+	for _, key := range []CacheKey{article, thumb} {
+		if _, ok := cm.items.Load(key); ok {
+			t.Fatalf("%s should be purged", key.URL)
+		}
+	}
+	if _, ok := cm.items.Load(other); !ok {
+		t.Fatal("untagged article should be kept")
+	}
+
+	// 重新写入时标签被覆盖, 旧标签不再命中
+	putTagged(t, cm, "/api/43.json", "Surrogate-Key", "article-43")
+	if n, _ := cm.ClearCacheByTags([]string{"author-7"}, PurgeOptions{}); n != 0 {
+		t.Fatalf("stale tag cleared %d, want 0", n)
+	}
+}
+
+func TestTagHeadersConfigurable(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+	cm.SetTagHeaders([]string{"X-Cms-Tags"})
+
+	putTagged(t, cm, "/a", "Surrogate-Key", "ignored")
+	putTagged(t, cm, "/b", "X-Cms-Tags", "post-1")
+	if n, _ := cm.ClearCacheByTags([]string{"ignored"}, PurgeOptions{}); n != 0 {
+		t.Fatalf("default header should be ignored, cleared %d", n)
+	}
+	if n, _ := cm.ClearCacheByTags([]string{"post-1"}, PurgeOptions{}); n != 1 {
+		t.Fatalf("cleared %d, want 1", n)
+	}
+}
+
+func TestCacheIndexPersistsTags(t *testing.T) {
+	dir := t.TempDir()
+	cm, err := NewCacheManager(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := putTagged(t, cm, "/img/1.png", "Surrogate-Key", "article-1")
+	putTagged(t, cm, "/img/1-copy.png", "Surrogate-Key", "article-1")
+	if err := cm.SaveIndex(); err != nil {
+		t.Fatal(err)
+	}
+	cm.Stop()
+
+	restored, err := NewCacheManager(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(restored.Stop)
+
+	item, found, _ := restored.Get(key, httptest.NewRequest("GET", "/img/1.png", nil), false)
+	if !found || item.Size != int64(len("/img/1.png")) {
+		t.Fatalf("restored item = %+v found=%v", item, found)
+	}
+	if n, _ := restored.ClearCacheByTags([]string{"article-1"}, PurgeOptions{}); n != 2 {
+		t.Fatalf("cleared %d after restart, want 2", n)
+	}
+}
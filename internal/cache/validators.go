@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag 返回缓存内容的强校验值, 由内容哈希生成: 内容不变时重新缓存、重启或在其他节点导入后都保持不变
+//
+// 各编码表示的 ETag 由同一哈希派生 (见 variantETag), 因此不透传源站的 ETag。
+func (item *CacheItem) ETag() string {
+	return `"` + item.Hash + `"`
+}
+
+// ModTime 返回响应的 Last-Modified: 优先使用源站的 Last-Modified, 源站未提供时使用写入缓存的时间
+func (item *CacheItem) ModTime() time.Time {
+	if !item.LastModified.IsZero() {
+		return item.LastModified
+	}
+	return item.CreatedAt
+}
+
+// SetValidators 设置 ETag / Last-Modified 响应头
+func (item *CacheItem) SetValidators(h http.Header) {
+	h.Set("ETag", item.ETag())
+	h.Set("Last-Modified", item.ModTime().UTC().Format(http.TimeFormat))
+}
+
+// NotModified 按 If-None-Match / If-Modified-Since 判断客户端的副本是否仍然有效 (只处理 GET / HEAD)
+// 同时带有两者时只看 If-None-Match (RFC 9110 13.2.2); 同一内容的各编码表示 (见 OpenContent) 都视为有效
+func (item *CacheItem) NotModified(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagListMatch(inm, item.ETag(), variantETag(item, "gzip"), variantETag(item, "br"), variantETag(item, "identity"))
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	return !item.ModTime().Truncate(time.Second).After(t)
+}
+
+// etagListMatch 用弱比较判断 If-None-Match 列表是否包含 etags 之一
+func etagListMatch(header string, etags ...string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		for _, etag := range etags {
+			if strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// responseLastModified 解析源站响应的 Last-Modified, 缺失或格式错误时返回零值
+func responseLastModified(resp *http.Response) time.Time {
+	t, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
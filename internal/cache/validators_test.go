@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetEvaluatesConditionalHeaders(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+
+	lastModified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	req := httptest.NewRequest("GET", "/app.js", nil)
+	key := cm.GenerateCacheKey(req, false)
+	header := http.Header{"Last-Modified": {lastModified.Format(http.TimeFormat)}}
+	item, err := cm.Put(key, &http.Response{StatusCode: 200, Header: header, Request: req}, []byte("console.log(1)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !item.ModTime().Equal(lastModified) {
+		t.Fatalf("ModTime = %v, want upstream Last-Modified", item.ModTime())
+	}
+	etag := item.ETag()
+
+	tests := []struct {
+		name        string
+		header      http.Header
+		notModified bool
+	}{
+		{"unconditional", http.Header{}, false},
+		{"etag match", http.Header{"If-None-Match": {etag}}, true},
+		{"weak etag in list", http.Header{"If-None-Match": {`"other", W/` + etag}}, true},
+		{"wildcard", http.Header{"If-None-Match": {"*"}}, true},
+		{"etag mismatch ignores date", http.Header{
+			"If-None-Match":     {`"other"`},
+			"If-Modified-Since": {lastModified.Format(http.TimeFormat)},
+		}, false},
+		{"not modified since", http.Header{"If-Modified-Since": {lastModified.Add(time.Hour).Format(http.TimeFormat)}}, true},
+		{"modified since", http.Header{"If-Modified-Since": {lastModified.Add(-time.Hour).Format(http.TimeFormat)}}, false},
+		{"bad date", http.Header{"If-Modified-Since": {"yesterday"}}, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/app.js", nil)
+		r.Header = tt.header
+		_, found, notModified := cm.Get(key, r, false)
+		if !found || notModified != tt.notModified {
+			t.Errorf("%s: found=%v notModified=%v, want %v", tt.name, found, notModified, tt.notModified)
+		}
+	}
+
+	// 相同内容重新写入后 ETag 不变
+	again, err := cm.Put(key, &http.Response{StatusCode: 200, Header: http.Header{}, Request: req}, []byte("console.log(1)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ETag() != etag {
+		t.Fatalf("ETag changed after re-cache: %s -> %s", etag, again.ETag())
+	}
+}
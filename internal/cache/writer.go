@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"log"
+	"net/http"
+	"os"
+)
+
+// ErrObjectTooLarge 响应体超过最大可缓存对象大小
+var ErrObjectTooLarge = errors.New("object exceeds max cacheable size")
+
+// CacheWriter 边转发边写入缓存临时文件, 同时增量计算 SHA-256, Commit 时无需再读回文件
+//
+// 作为 io.TeeReader 的写端使用: Write 永远返回成功, 磁盘写入失败或超过大小上限时只放弃缓存
+// (关闭并删除临时文件), 不影响正在转发给客户端的响应。
+type CacheWriter struct {
+	file    *os.File
+	hasher  hash.Hash
+	size    int64
+	limit   int64 // 0 表示不限制
+	url     string
+	aborted bool
+}
+
+// NewCacheWriter 为 key 创建缓存写入器; Content-Length 已知且超过上限时直接返回 ErrObjectTooLarge
+func (cm *CacheManager) NewCacheWriter(key CacheKey, resp *http.Response) (*CacheWriter, error) {
+	if !cm.enabled.Load() {
+		return nil, fmt.Errorf("cache is disabled")
+	}
+
+	limit := cm.maxObjectSizeFor(key)
+	if limit > 0 && resp.ContentLength > limit {
+		return nil, ErrObjectTooLarge
+	}
+
+	// 创建临时文件
+	tempFile, err := os.CreateTemp(cm.tempDir(), "temp-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %v", err)
+	}
+
+	return &CacheWriter{
+		file:   tempFile,
+		hasher: sha256.New(),
+		limit:  limit,
+		url:    key.URL,
+	}, nil
+}
+
+// Write 实现 io.Writer
+func (w *CacheWriter) Write(p []byte) (int, error) {
+	if w.aborted {
+		return len(p), nil
+	}
+	if w.limit > 0 && w.size+int64(len(p)) > w.limit {
+		log.Printf("[Cache] SKIP %s: 超过最大缓存对象大小 (%s)", w.url, formatBytes(w.limit))
+		w.Abort()
+		return len(p), nil
+	}
+	if _, err := w.file.Write(p); err != nil {
+		log.Printf("[Cache] ERR Failed to write temp file for %s: %v", w.url, err)
+		w.Abort()
+		return len(p), nil
+	}
+	w.hasher.Write(p)
+	w.size += int64(len(p))
+	return len(p), nil
+}
+
+// Abort 放弃缓存, 关闭并删除临时文件; 可重复调用
+func (w *CacheWriter) Abort() {
+	if w.aborted {
+		return
+	}
+	w.aborted = true
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// Finish 同步并关闭临时文件, 返回临时文件路径、大小与内容摘要; ok 为 false 表示已放弃缓存
+func (w *CacheWriter) Finish() (tempPath string, size int64, digest string, ok bool) {
+	if w.aborted {
+		return "", 0, "", false
+	}
+	if err := w.file.Sync(); err != nil {
+		w.Abort()
+		return "", 0, "", false
+	}
+	if err := w.file.Close(); err != nil {
+		w.aborted = true
+		os.Remove(w.file.Name())
+		return "", 0, "", false
+	}
+	return w.file.Name(), w.size, hex.EncodeToString(w.hasher.Sum(nil)), true
+}
+
+// maxObjectSizeFor 返回 key 生效的最大可缓存对象大小 (字节), 0 表示不限制; 独立路径配置优先
+func (cm *CacheManager) maxObjectSizeFor(key CacheKey) int64 {
+	if policy := cm.isolatedPolicy(cm.pathPrefixOf(key)); policy != nil && policy.cache.MaxObjectSize > 0 {
+		return policy.cache.MaxObjectSize * 1024 * 1024
+	}
+	return cm.maxObjectSize
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"proxy-go/internal/config"
+	"strings"
+	"testing"
+)
+
+func TestCacheWriterStreamsHashIntoCommit(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+
+	req := httptest.NewRequest("GET", "/big.bin", nil)
+	resp := &http.Response{StatusCode: 200, Header: make(http.Header), Request: req, ContentLength: -1}
+	key := cm.GenerateCacheKey(req, false)
+
+	body := strings.Repeat("0123456789", 10000)
+	cw, err := cm.NewCacheWriter(key, resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var client strings.Builder
+	if _, err := io.Copy(&client, io.TeeReader(strings.NewReader(body), cw)); err != nil {
+		t.Fatal(err)
+	}
+	tempPath, size, digest, ok := cw.Finish()
+	if !ok || size != int64(len(body)) || client.String() != body {
+		t.Fatalf("Finish() = %q %d %v", tempPath, size, ok)
+	}
+	sum := sha256.Sum256([]byte(body))
+	if digest != hex.EncodeToString(sum[:]) {
+		t.Fatalf("digest = %s", digest)
+	}
+
+	if err := cm.Commit(key, tempPath, resp, size, digest); err != nil {
+		t.Fatal(err)
+	}
+	item, found, _ := cm.Get(key, req, false)
+	if !found || item.FilePath != cm.blobPath(digest) {
+		t.Fatalf("committed item = %+v found=%v", item, found)
+	}
+}
+
+func TestCacheWriterMaxObjectSize(t *testing.T) {
+	cm, err := NewCacheManager(t.TempDir(), &config.CacheConfig{MaxAge: 30, CleanupTick: 5, MaxCacheSize: 1, MaxObjectSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+
+	req := httptest.NewRequest("GET", "/huge.bin", nil)
+	key := cm.GenerateCacheKey(req, false)
+
+	// Content-Length 已知且超限: 不创建临时文件
+	if _, err := cm.NewCacheWriter(key, &http.Response{Request: req, ContentLength: 2 << 20}); !errors.Is(err, ErrObjectTooLarge) {
+		t.Fatalf("err = %v, want ErrObjectTooLarge", err)
+	}
+
+	// 长度未知: 流式写入超限后放弃缓存, 客户端仍收到完整响应
+	cw, err := cm.NewCacheWriter(key, &http.Response{Request: req, ContentLength: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := strings.Repeat("x", 1<<20+1)
+	n, err := io.Copy(io.Discard, io.TeeReader(strings.NewReader(body), cw))
+	if err != nil || n != int64(len(body)) {
+		t.Fatalf("copy = %d, %v", n, err)
+	}
+	if _, _, _, ok := cw.Finish(); ok {
+		t.Fatal("oversized object should not be committed")
+	}
+	if _, err := os.Stat(cw.file.Name()); !os.IsNotExist(err) {
+		t.Fatalf("temp file should be removed, stat err = %v", err)
+	}
+}
@@ -122,6 +122,22 @@ func (cm *ConfigManager) applyEnvOverrides(config *Config) {
 			log.Printf("[ConfigManager] 使用环境变量 SECURITY_BAN_DURATION: %d", val)
 		}
 	}
+
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		var cidrs []string
+		for _, cidr := range strings.Split(proxies, ",") {
+			if cidr = strings.TrimSpace(cidr); cidr != "" {
+				cidrs = append(cidrs, cidr)
+			}
+		}
+		config.Security.TrustedProxies = cidrs
+		log.Printf("[ConfigManager] 使用环境变量 TRUSTED_PROXIES: %v", cidrs)
+	}
+
+	if enabled := os.Getenv("PROXY_PROTOCOL"); enabled != "" {
+		config.Security.ProxyProtocol = enabled == "true" || enabled == "1"
+		log.Printf("[ConfigManager] 使用环境变量 PROXY_PROTOCOL: %v", config.Security.ProxyProtocol)
+	}
 }
 
 // convertMapToConfig 将 map[string]any 转换为 Config 结构
@@ -177,7 +193,7 @@ func (cm *ConfigManager) loadConfigFromFile() (*Config, error) {
 // normalizeConfig 规范化配置，确保向后兼容
 // 要点:
 //  1. PathConfig.Enabled 旧配置默认 true
-//  2. SecurityConfig.RefererBan.Hosts / PathConfig.RefererBan.Hosts 把 nil 归一为空切片,
+//  2. SecurityConfig.RefererBan.Hosts / PathConfig.RefererBan.Hosts / SecurityConfig.TrustedProxies 把 nil 归一为空切片,
 //     避免 D1 / JSON round-trip 把空数组变 null 后, 前端访问 .length / .map 时崩溃
 func (cm *ConfigManager) normalizeConfig(config *Config) {
 	// 遍历所有路径配置，确保 Enabled 字段有默认值
@@ -206,6 +222,9 @@ func (cm *ConfigManager) normalizeConfig(config *Config) {
 	if config.Security.RefererBan.Hosts == nil {
 		config.Security.RefererBan.Hosts = []string{}
 	}
+	if config.Security.TrustedProxies == nil {
+		config.Security.TrustedProxies = []string{}
+	}
 }
 
 // normalizeTargets 归一单路径的多源配置
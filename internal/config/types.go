@@ -1,7 +1,11 @@
 package config
 
 import (
+	"fmt"
+	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
@@ -12,6 +16,22 @@ type Config struct {
 	MirrorCache CacheConfig           `json:"MirrorCache"` // 镜像缓存配置
 	FaviconURL  string                `json:"FaviconURL"`  // Favicon URL (可选)，支持环境变量 FAVICON_URL 覆盖
 	CDN         CDNConfig             `json:"CDN"`         // 外部 CDN 缓存清理配置 (Cloudflare / EdgeOne 等)
+	// ErrorPages 全局自定义错误响应, 路径级 PathConfig.ErrorPages 优先
+	ErrorPages map[string]ErrorPage `json:"ErrorPages,omitempty"`
+	// CacheTagHeaders 记录缓存标签的上游响应头, 为空时读取 Surrogate-Key 与 Cache-Tag
+	CacheTagHeaders []string `json:"CacheTagHeaders,omitempty"`
+	// PeerCache 多节点对等缓存, 未启用时各节点独立回源
+	PeerCache PeerCacheConfig `json:"PeerCache"`
+}
+
+// PeerCacheConfig 多节点对等缓存配置
+// 各节点组成一致性哈希环, 缓存未命中时先向负责该 URL 的节点获取, 失败或超时再回源。
+// 本节点地址与节点间鉴权令牌按节点配置, 分别读取环境变量 PEER_SELF_URL / PEER_CACHE_TOKEN
+type PeerCacheConfig struct {
+	Enabled   bool     `json:"Enabled"`
+	Peers     []string `json:"Peers,omitempty"`     // 静态节点地址列表 (如 http://10.0.0.1:3336), 可包含本节点
+	Discovery bool     `json:"Discovery,omitempty"` // 通过 D1 节点注册表发现节点, 与 Peers 合并
+	TimeoutMs int      `json:"TimeoutMs,omitempty"` // 向对等节点请求的超时 (毫秒), 默认 2000
 }
 
 // CDNConfig 外部 CDN 缓存清理配置
@@ -53,8 +73,192 @@ type PathConfig struct {
 	// 避免不同设备拿到对方格式。源站不做格式协商时, 务必保持 false,
 	// 此时所有请求共享同一份原文件缓存, 命中率显著更高。
 	CFImageOpt bool `json:"CFImageOpt"`
+	// ForwardHeaders 回源转发头策略 (X-Forwarded-* / Forwarded / Via / X-Request-ID);
+	// 为 nil 时保持默认: 设置 X-Real-IP、追加 X-Forwarded-For、透传 X-Request-ID
+	ForwardHeaders *ForwardHeadersConfig `json:"ForwardHeaders,omitempty"`
+	// ErrorPages 路径级自定义错误响应, 未命中的状态码回落到全局 Config.ErrorPages
+	ErrorPages map[string]ErrorPage `json:"ErrorPages,omitempty"`
+	// OriginAuth 回源鉴权 (私有 S3 兼容桶 / 需要令牌的源站); key 为目标 URL (与 DefaultTargets /
+	// ExtensionMap 的 Target 一致), "*" 作用于该路径的其余目标。取值统一走 OriginAuthFor()
+	OriginAuth map[string]OriginAuthConfig `json:"OriginAuth,omitempty"`
+	// FileServer 本地目录目标 (file:///srv/assets) 的目录访问策略; 为 nil 时目录一律 404, 只提供文件
+	FileServer *FileServerConfig `json:"FileServer,omitempty"`
+	// CacheKey 路径级缓存键策略; 为 nil 时以完整 URL (含 query) 作为缓存键
+	CacheKey *CacheKeyConfig `json:"CacheKey,omitempty"`
+	// StatusCache 按状态码缓存非 200 响应 (只保存状态码与 Location 等少量响应头, 不保存响应体);
+	// key 为状态码 ("404" / "410" / "301" / "302" / "308"), value 为缓存秒数。为空时只缓存 200。取值统一走 StatusCacheTTLs()
+	StatusCache map[string]int `json:"StatusCache,omitempty"`
+	// CacheRules 缓存绕过规则, 任一规则命中即生效, 见 CacheRule
+	CacheRules []CacheRule `json:"CacheRules,omitempty"`
+	// BodyCache 按请求体缓存 POST / QUERY 请求 (只读的搜索 / GraphQL 接口); 为 nil 时只缓存 GET, 见 BodyCacheConfig
+	BodyCache *BodyCacheConfig `json:"BodyCache,omitempty"`
+}
+
+// CacheRule 路径级缓存绕过规则: 已填写的条件全部满足时命中, 未填写的条件不参与匹配
+//
+// Action: "bypass" (默认) 不查缓存也不写入缓存; "no-store" 照常使用已有缓存, 但本次响应不写入缓存。
+// 请求条件: Methods / Header (+HeaderValue) / Cookie (+CookieValue) / Query (+QueryValue);
+// 响应条件: ResponseHeader (+ResponseHeaderValue), 只在回源后决定是否写入缓存时判断, 带响应条件的规则不影响缓存查找。
+// *Value 为空时只要求存在; 请求头 / 响应头的值按包含匹配 (忽略大小写), Cookie / Query 的值按精确匹配。
+type CacheRule struct {
+	Action              string   `json:"Action,omitempty"`
+	Methods             []string `json:"Methods,omitempty"`
+	Header              string   `json:"Header,omitempty"`
+	HeaderValue         string   `json:"HeaderValue,omitempty"`
+	Cookie              string   `json:"Cookie,omitempty"`
+	CookieValue         string   `json:"CookieValue,omitempty"`
+	Query               string   `json:"Query,omitempty"`
+	QueryValue          string   `json:"QueryValue,omitempty"`
+	ResponseHeader      string   `json:"ResponseHeader,omitempty"`
+	ResponseHeaderValue string   `json:"ResponseHeaderValue,omitempty"`
+}
+
+const (
+	CacheRuleBypass  = "bypass"
+	CacheRuleNoStore = "no-store"
+)
+
+// CacheKeyConfig 路径级缓存键策略
+// QueryMode 取值: "" / "all" (保留全部参数) / "ignore" (忽略 query) / "include" (只保留 QueryParams) / "exclude" (剔除 QueryParams);
+// QueryParams 支持以 "*" 结尾的前缀匹配 (如 "utm_*")。SortQuery 按参数名排序, 消除参数顺序差异。
+// Headers / Cookies / IncludeHost 把对应请求值加入缓存键, 用于源站按这些维度返回不同内容的场景。
+// 按 URL 清理缓存时使用同一套规范化规则, 并清除该 URL 的所有 Host / Header / Cookie 变体。
+type CacheKeyConfig struct {
+	QueryMode     string   `json:"QueryMode,omitempty"`
+	QueryParams   []string `json:"QueryParams,omitempty"`
+	SortQuery     bool     `json:"SortQuery,omitempty"`
+	LowercasePath bool     `json:"LowercasePath,omitempty"`
+	Headers       []string `json:"Headers,omitempty"`
+	Cookies       []string `json:"Cookies,omitempty"`
+	IncludeHost   bool     `json:"IncludeHost,omitempty"`
+}
+
+const (
+	CacheKeyQueryAll     = "all"
+	CacheKeyQueryIgnore  = "ignore"
+	CacheKeyQueryInclude = "include"
+	CacheKeyQueryExclude = "exclude"
+)
+
+// BodyCacheConfig 按请求体缓存 POST / QUERY 请求: 请求方法与请求体的 SHA-256 加入缓存键, 同一 URL 的不同请求体分别缓存。
+// Methods 为参与缓存的方法 (默认 POST 与 QUERY); MaxBodySize 为参与计算缓存键的最大请求体 (字节, 默认 64KB),
+// 超过时按普通请求回源且不缓存。CanonicalJSON 对 JSON 请求体排序键、去除空白后再计算哈希;
+// IgnoreFields 为计算哈希时忽略的 JSON 字段 (如 "requestId", 嵌套字段写作 "extensions.traceId"), 设置后同样规范化 JSON。
+// 请求体缓冲在内存中, 多源回落时可重放给下一个源。
+type BodyCacheConfig struct {
+	Methods       []string `json:"Methods,omitempty"`
+	MaxBodySize   int64    `json:"MaxBodySize,omitempty"`
+	CanonicalJSON bool     `json:"CanonicalJSON,omitempty"`
+	IgnoreFields  []string `json:"IgnoreFields,omitempty"`
+}
+
+// MethodQuery HTTP QUERY 方法 (带请求体的安全查询), net/http 未定义该常量
+const MethodQuery = "QUERY"
+
+// DefaultBodyCacheMaxSize BodyCacheConfig.MaxBodySize 的默认值
+const DefaultBodyCacheMaxSize = 64 * 1024
+
+// AllowsMethod 判断 method 的请求是否按请求体缓存
+func (c *BodyCacheConfig) AllowsMethod(method string) bool {
+	if len(c.Methods) == 0 {
+		return method == http.MethodPost || method == MethodQuery
+	}
+	for _, m := range c.Methods {
+		if strings.EqualFold(strings.TrimSpace(m), method) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxBody 返回参与计算缓存键的最大请求体字节数
+func (c *BodyCacheConfig) MaxBody() int64 {
+	if c.MaxBodySize > 0 {
+		return c.MaxBodySize
+	}
+	return DefaultBodyCacheMaxSize
+}
+
+// FileServerConfig 本地目录目标的目录访问策略
+// Index 为目录默认文件 (如 ["index.html"]), 按序查找; 均不存在时按 Listing 输出目录列表:
+// "html" / "json", 空字符串表示不列目录。隐藏文件 (以 "." 开头) 不可访问也不出现在列表中。
+type FileServerConfig struct {
+	Index   []string `json:"Index,omitempty"`
+	Listing string   `json:"Listing,omitempty"`
 }
 
+const (
+	FileListingHTML = "html"
+	FileListingJSON = "json"
+)
+
+// OriginAuthConfig 单个回源目标的鉴权方式
+// Type 取值:
+//   - "sigv4": AWS Signature V4 (S3 / MinIO / R2 / OSS 兼容接口); 密钥可内联或通过 *Env 指定环境变量, 环境变量优先;
+//     Presign 为 true 时改用 query 签名 (X-Amz-Signature), 适合会剥离 Authorization 头的中间层
+//   - "bearer": Authorization: Bearer <TokenEnv 指向的环境变量>
+//   - "basic": Authorization: Basic <UsernameEnv:PasswordEnv>
+//
+// 内联密钥在 /admin/api/config/get 中脱敏输出, 原样保存回来时保留旧值。
+type OriginAuthConfig struct {
+	Type string `json:"Type"`
+
+	Region             string `json:"Region,omitempty"`
+	Service            string `json:"Service,omitempty"` // 默认 s3
+	AccessKeyID        string `json:"AccessKeyID,omitempty"`
+	SecretAccessKey    string `json:"SecretAccessKey,omitempty"`
+	SessionToken       string `json:"SessionToken,omitempty"`
+	AccessKeyIDEnv     string `json:"AccessKeyIDEnv,omitempty"`
+	SecretAccessKeyEnv string `json:"SecretAccessKeyEnv,omitempty"`
+	SessionTokenEnv    string `json:"SessionTokenEnv,omitempty"`
+	Presign            bool   `json:"Presign,omitempty"`
+	PresignExpires     int    `json:"PresignExpires,omitempty"` // 秒, 默认 300
+
+	TokenEnv    string `json:"TokenEnv,omitempty"`
+	UsernameEnv string `json:"UsernameEnv,omitempty"`
+	PasswordEnv string `json:"PasswordEnv,omitempty"`
+}
+
+const (
+	OriginAuthSigV4  = "sigv4"
+	OriginAuthBearer = "bearer"
+	OriginAuthBasic  = "basic"
+)
+
+// RedactedSecret 脱敏占位符; 保存配置时遇到该值表示"沿用旧值"
+const RedactedSecret = "******"
+
+// ErrorPage 单个状态码 / 状态类的自定义错误响应
+// map 的 key 为具体状态码 ("404") 或状态类 ("4xx" / "5xx"), 具体状态码优先。
+// Body / File 二选一 (File 优先), 按 Go 模板渲染, 可用变量见 errorpage.TemplateData;
+// ContentType 为空时按 File 扩展名推断, 默认 text/html (HTML 模板自动转义)。
+// JSONBody 为客户端 Accept 偏好 JSON 时使用的模板, 为空则输出内置结构化 JSON。
+type ErrorPage struct {
+	ContentType string `json:"ContentType,omitempty"`
+	Body        string `json:"Body,omitempty"`
+	File        string `json:"File,omitempty"`
+	JSONBody    string `json:"JSONBody,omitempty"`
+}
+
+// ForwardHeadersConfig 路径级回源转发头策略
+// XForwardedFor 取值 "append" (默认, 在已有链后追加客户端 IP) / "replace" (只保留客户端 IP) / "off" (不写入);
+// Forwarded (RFC 7239) 的链式语义跟随 XForwardedFor: replace 时丢弃请求中已有的 Forwarded 值。
+// Strip 最后执行, 列出的头部 (含客户端带来的同名头和上面生成的头) 一律不发给源站, 用于隐私敏感的源站。
+type ForwardHeadersConfig struct {
+	XForwardedFor   string   `json:"XForwardedFor"`
+	XForwardedProto bool     `json:"XForwardedProto"`
+	XForwardedHost  bool     `json:"XForwardedHost"`
+	Forwarded       bool     `json:"Forwarded"`
+	Via             bool     `json:"Via"`
+	Strip           []string `json:"Strip"`
+}
+
+const (
+	ForwardForAppend  = "append"
+	ForwardForReplace = "replace"
+	ForwardForOff     = "off"
+)
+
 // ExtensionRule 表示一个扩展名映射规则（内部使用）
 type ExtensionRule struct {
 	Extensions    []string // 支持的扩展名列表
@@ -78,6 +282,13 @@ type CompressorConfig struct {
 type SecurityConfig struct {
 	IPBan      IPBanConfig      `json:"IPBan"`      // IP封禁配置
 	RefererBan RefererBanConfig `json:"RefererBan"` // 引用来源 (Referer host) 黑名单, 全局生效
+	// TrustedProxies 可信代理 CIDR 列表 (单 IP 亦可), 只有直连对端命中时才采信
+	// CF-Connecting-IP / X-Real-IP / X-Forwarded-For 等转发头; 为空时默认信任回环与私网地址。
+	// 支持环境变量 TRUSTED_PROXIES (逗号分隔) 覆盖
+	TrustedProxies []string `json:"TrustedProxies"`
+	// ProxyProtocol 监听端口启用 PROXY protocol v1/v2 解析 (部署在 L4 负载均衡之后时使用),
+	// 仅接受来自 TrustedProxies 对端的协议头。监听器在启动时创建, 修改后需重启生效
+	ProxyProtocol bool `json:"ProxyProtocol"`
 }
 
 // RefererBanConfig 引用来源 host 黑白名单
@@ -131,8 +342,33 @@ type CacheConfig struct {
 	MaxAge       int64 `json:"max_age"`        // 最大缓存时间（分钟）
 	CleanupTick  int64 `json:"cleanup_tick"`   // 清理间隔（分钟）
 	MaxCacheSize int64 `json:"max_cache_size"` // 最大缓存大小（GB）
+	// MaxObjectSize 单个响应最大可缓存大小（MB），超过时边转发边放弃缓存；0 表示不限制
+	MaxObjectSize int64 `json:"max_object_size,omitempty"`
+	// EvictionPolicy 淘汰策略: lru (默认) / lfu / greedydual / tinylfu
+	EvictionPolicy string `json:"eviction_policy,omitempty"`
+	// HighWatermark / LowWatermark 缓存目录实际磁盘占用达到 max_cache_size 的 HighWatermark% 时开始淘汰,
+	// 降到 LowWatermark% 为止; 0 表示默认 95 / 85。仅全局配置生效
+	HighWatermark int `json:"high_watermark,omitempty"`
+	LowWatermark  int `json:"low_watermark,omitempty"`
+	// MinFreeSpace 缓存所在磁盘至少保留的可用空间（MB），不足时收缩缓存；0 表示不检查。仅全局配置生效
+	MinFreeSpace int64 `json:"min_free_space,omitempty"`
+	// MemoryTierSize 小对象内存层总大小（MB），0 表示关闭；MemoryObjectSize 可进入内存层的最大对象（KB，默认 64）；
+	// MemoryPromoteHits 命中多少次后提升到内存层（默认 2）。仅全局配置生效
+	MemoryTierSize    int64 `json:"memory_tier_size,omitempty"`
+	MemoryObjectSize  int64 `json:"memory_object_size,omitempty"`
+	MemoryPromoteHits int64 `json:"memory_promote_hits,omitempty"`
+	// ScrubRate 后台完整性校验的读取速率（MB/s），0 表示关闭；ScrubInterval 两轮校验的间隔（小时，默认 24）。仅全局配置生效
+	ScrubRate     int64 `json:"scrub_rate,omitempty"`
+	ScrubInterval int64 `json:"scrub_interval,omitempty"`
 }
 
+const (
+	EvictionLRU        = "lru"
+	EvictionLFU        = "lfu"
+	EvictionGreedyDual = "greedydual" // 按大小加权的 GreedyDual-Size-Frequency
+	EvictionTinyLFU    = "tinylfu"    // 频率草图准入 + 按估计频率淘汰
+)
+
 // 扩展名映射配置结构
 type ExtRuleConfig struct {
 	Extensions    string `json:"Extensions"`    // 逗号分隔的扩展名
@@ -164,6 +400,66 @@ func (p *PathConfig) GetTargets() []string {
 	return nil
 }
 
+// StatusCacheable 可通过 PathConfig.StatusCache 缓存的状态码
+var StatusCacheable = map[int]bool{
+	404: true, 410: true,
+	301: true, 302: true, 308: true,
+}
+
+// StatusCacheTTLs 解析 StatusCache 为 状态码 -> TTL; 无效项 (不可缓存的状态码 / 非正数 TTL) 被跳过并以 error 报告
+func (p *PathConfig) StatusCacheTTLs() (map[int]time.Duration, error) {
+	if len(p.StatusCache) == 0 {
+		return nil, nil
+	}
+	ttls := make(map[int]time.Duration, len(p.StatusCache))
+	var err error
+	for key, seconds := range p.StatusCache {
+		code, convErr := strconv.Atoi(strings.TrimSpace(key))
+		if convErr != nil || !StatusCacheable[code] {
+			err = fmt.Errorf("StatusCache 不支持的状态码: %q (仅支持 404 / 410 / 301 / 302 / 308)", key)
+			continue
+		}
+		if seconds <= 0 {
+			err = fmt.Errorf("StatusCache %s 的缓存时间必须为正数", key)
+			continue
+		}
+		ttls[code] = time.Duration(seconds) * time.Second
+	}
+	return ttls, err
+}
+
+// IsFileTarget 判断目标是否为本地目录 (file://)
+func IsFileTarget(target string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(target)), "file://")
+}
+
+// FileTargetRoot 返回 file:// 目标对应的根目录 (去掉末尾 "/"), 如 file:///srv/assets/ -> /srv/assets
+func FileTargetRoot(target string) string {
+	target = strings.TrimSpace(target)
+	root := target[len("file://"):]
+	if root != "/" {
+		root = strings.TrimRight(root, "/")
+	}
+	return root
+}
+
+// OriginAuthFor 返回 target 对应的回源鉴权配置: 精确匹配目标 URL (忽略末尾 /) 优先, 其次 "*"; 未配置返回 nil
+func (p *PathConfig) OriginAuthFor(target string) *OriginAuthConfig {
+	if len(p.OriginAuth) == 0 {
+		return nil
+	}
+	target = strings.TrimRight(strings.TrimSpace(target), "/")
+	for key, auth := range p.OriginAuth {
+		if key != "*" && strings.TrimRight(strings.TrimSpace(key), "/") == target {
+			return &auth
+		}
+	}
+	if auth, ok := p.OriginAuth["*"]; ok {
+		return &auth
+	}
+	return nil
+}
+
 // 处理扩展名映射的方法
 func (p *PathConfig) ProcessExtensionMap() {
 	p.ExtRules = nil
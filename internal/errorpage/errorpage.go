@@ -0,0 +1,340 @@
+package errorpage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"proxy-go/internal/config"
+	"proxy-go/internal/utils"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	texttemplate "text/template"
+	"time"
+)
+
+// banErrorText 封禁 JSON 响应的 error 字段
+const banErrorText = "IP temporarily banned due to excessive 404 errors"
+
+// Info 渲染错误响应时的附加信息
+type Info struct {
+	Message    string    // 给客户端的说明, 为空时用状态码标准文案
+	BanEndTime time.Time // 非零表示 IP 封禁响应, 模板可读取剩余时间
+}
+
+// TemplateData 模板可用变量
+//
+//	{{.Status}} {{.StatusText}} {{.Message}} {{.RequestID}} {{.Method}} {{.Path}}
+//	{{.BanEndTime}} {{.BanRemainingSeconds}} (仅封禁响应非空)
+type TemplateData struct {
+	Status              int
+	StatusText          string
+	Message             string
+	RequestID           string
+	Method              string
+	Path                string
+	BanEndTime          string
+	BanRemainingSeconds int64
+}
+
+// page 已编译的单个错误页; body / json 任一可为 nil
+type page struct {
+	contentType string
+	body        func(*bytes.Buffer, *TemplateData) error
+	json        func(*bytes.Buffer, *TemplateData) error
+}
+
+// pageSet key 为 "404" / "4xx" 形式
+type pageSet map[string]*page
+
+// lookup 具体状态码优先, 其次状态类
+func (ps pageSet) lookup(status int) *page {
+	if ps == nil {
+		return nil
+	}
+	if p, ok := ps[strconv.Itoa(status)]; ok {
+		return p
+	}
+	return ps[fmt.Sprintf("%dxx", status/100)]
+}
+
+// Renderer 按 全局 + 路径级 配置渲染错误响应
+// 模板在配置加载 / 热更新时整体编译后 Store, 读侧无锁。
+type Renderer struct {
+	global atomic.Pointer[pageSet]
+	paths  atomic.Pointer[map[string]pageSet]
+}
+
+// NewRenderer 创建渲染器; 未加载配置时全部使用内置响应
+func NewRenderer() *Renderer {
+	return &Renderer{}
+}
+
+// Load 编译配置中的全局与路径级错误页; 单个错误页编译失败只记录日志并跳过, 回落到内置响应
+func (r *Renderer) Load(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	global := compilePages(cfg.ErrorPages, "global")
+	paths := make(map[string]pageSet)
+	for prefix, pc := range cfg.MAP {
+		if len(pc.ErrorPages) > 0 {
+			paths[prefix] = compilePages(pc.ErrorPages, prefix)
+		}
+	}
+	r.global.Store(&global)
+	r.paths.Store(&paths)
+}
+
+// Render 写出错误响应
+// prefix 为匹配到的路径前缀 (未匹配传空串); 查找顺序: 路径级具体码 → 路径级状态类 → 全局具体码 → 全局状态类 → 内置。
+// 客户端偏好 JSON 时使用错误页的 JSONBody, 未配置则输出内置结构化 JSON。
+func (r *Renderer) Render(w http.ResponseWriter, req *http.Request, prefix string, status int, info Info) {
+	data := newTemplateData(req, status, info)
+	wantJSON := WantsJSON(req)
+
+	if p := r.lookup(prefix, status); p != nil {
+		render, contentType := p.body, p.contentType
+		if wantJSON {
+			switch {
+			case p.json != nil:
+				render, contentType = p.json, "application/json; charset=utf-8"
+			case !strings.Contains(p.contentType, "json"):
+				// 错误页本身就是 JSON 时两种客户端共用, 否则 JSON 客户端走内置结构化 JSON
+				render = nil
+			}
+		}
+		if render != nil {
+			var buf bytes.Buffer
+			err := render(&buf, data)
+			if err == nil {
+				writeResponse(w, status, contentType, buf.Bytes())
+				return
+			}
+			log.Printf("[ErrorPage] 渲染 %d 错误页失败, 使用内置响应: %v", status, err)
+		}
+	}
+
+	// 封禁响应历史上始终为 JSON, 未配置错误页时保持不变
+	if wantJSON || !info.BanEndTime.IsZero() {
+		writeResponse(w, status, "application/json; charset=utf-8", builtinJSON(data))
+		return
+	}
+	writeResponse(w, status, "text/plain; charset=utf-8", []byte(data.Message+"\n"))
+}
+
+func (r *Renderer) lookup(prefix string, status int) *page {
+	if prefix != "" {
+		if paths := r.paths.Load(); paths != nil {
+			if p := (*paths)[prefix].lookup(status); p != nil {
+				return p
+			}
+		}
+	}
+	if global := r.global.Load(); global != nil {
+		return global.lookup(status)
+	}
+	return nil
+}
+
+func newTemplateData(req *http.Request, status int, info Info) *TemplateData {
+	data := &TemplateData{
+		Status:     status,
+		StatusText: http.StatusText(status),
+		Message:    info.Message,
+		Method:     req.Method,
+		Path:       req.URL.Path,
+	}
+	// 错误可能在入口生成请求 ID 之前产生 (如 IP 封禁), 只回显格式合法的 ID, 避免把任意客户端输入写进页面
+	if id := req.Header.Get(utils.RequestIDHeader); utils.ValidRequestID(id) {
+		data.RequestID = id
+	}
+	if data.Message == "" {
+		data.Message = data.StatusText
+	}
+	if !info.BanEndTime.IsZero() {
+		data.BanEndTime = info.BanEndTime.Format("2006-01-02 15:04:05")
+		data.BanRemainingSeconds = int64(math.Max(0, math.Round(time.Until(info.BanEndTime).Seconds())))
+	}
+	return data
+}
+
+// builtinJSON 内置结构化 JSON 错误体
+func builtinJSON(data *TemplateData) []byte {
+	body := map[string]any{
+		"code":    data.Status,
+		"error":   data.StatusText,
+		"message": data.Message,
+	}
+	if data.RequestID != "" {
+		body["request_id"] = data.RequestID
+	}
+	if data.BanEndTime != "" {
+		// 与旧版封禁响应保持一致, 已有客户端按该文案识别
+		body["error"] = banErrorText
+		body["ban_end_time"] = data.BanEndTime
+		body["remaining_seconds"] = data.BanRemainingSeconds
+	}
+	out, _ := json.Marshal(body)
+	return out
+}
+
+func writeResponse(w http.ResponseWriter, status int, contentType string, body []byte) {
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Cache-Control", "no-store")
+	// 上游响应头可能已复制到 w (如 Content-Encoding), 错误体是本地生成的明文
+	h.Del("Content-Encoding")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// WantsJSON 按 Accept 判断客户端是否偏好 JSON: 出现 application/json 或 +json,
+// 且其 q 值不低于 text/html (浏览器的 Accept 通常带 text/html 且不带 json)
+func WantsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return false
+	}
+	jsonQ, htmlQ := -1.0, -1.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			jsonQ = math.Max(jsonQ, q)
+		case mediaType == "text/html":
+			htmlQ = math.Max(htmlQ, q)
+		}
+	}
+	return jsonQ > 0 && jsonQ >= htmlQ
+}
+
+// Validate 校验错误页配置: key 格式、模板语法、文件可读; 供配置保存前调用
+func Validate(pages map[string]config.ErrorPage) error {
+	for key, ep := range pages {
+		if !validKey(strings.ToLower(strings.TrimSpace(key))) {
+			return fmt.Errorf("错误页 key 无效: %q (应为 \"404\" 或 \"4xx\" 形式)", key)
+		}
+		if _, err := compilePage(ep); err != nil {
+			return fmt.Errorf("错误页 %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// validKey 400-599 的具体状态码, 或 "4xx" / "5xx"
+func validKey(key string) bool {
+	if len(key) != 3 {
+		return false
+	}
+	if key == "4xx" || key == "5xx" {
+		return true
+	}
+	code, err := strconv.Atoi(key)
+	return err == nil && code >= 400 && code <= 599
+}
+
+func compilePages(pages map[string]config.ErrorPage, scope string) pageSet {
+	out := make(pageSet, len(pages))
+	for key, ep := range pages {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !validKey(key) {
+			log.Printf("[ErrorPage] 忽略无效的错误页 key %q (%s)", key, scope)
+			continue
+		}
+		p, err := compilePage(ep)
+		if err != nil {
+			log.Printf("[ErrorPage] 错误页 %s (%s) 编译失败, 使用内置响应: %v", key, scope, err)
+			continue
+		}
+		out[key] = p
+	}
+	return out
+}
+
+func compilePage(ep config.ErrorPage) (*page, error) {
+	p := &page{contentType: ep.ContentType}
+
+	body := ep.Body
+	if ep.File != "" {
+		raw, err := os.ReadFile(ep.File)
+		if err != nil {
+			return nil, fmt.Errorf("读取模板文件失败: %v", err)
+		}
+		body = string(raw)
+		if p.contentType == "" {
+			p.contentType = mime.TypeByExtension(filepath.Ext(ep.File))
+		}
+	}
+	if p.contentType == "" {
+		p.contentType = "text/html; charset=utf-8"
+	}
+
+	if body != "" {
+		render, err := compileTemplate(body, p.contentType)
+		if err != nil {
+			return nil, err
+		}
+		p.body = render
+	}
+	if ep.JSONBody != "" {
+		render, err := compileTemplate(ep.JSONBody, "application/json")
+		if err != nil {
+			return nil, err
+		}
+		p.json = render
+	}
+	return p, nil
+}
+
+// compileTemplate HTML 用 html/template 自动转义; 其余用 text/template, 并提供 json 函数供 JSON 模板安全嵌入字符串
+func compileTemplate(src, contentType string) (func(*bytes.Buffer, *TemplateData) error, error) {
+	if strings.HasPrefix(contentType, "text/html") {
+		t, err := htmltemplate.New("errorpage").Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("模板语法错误: %v", err)
+		}
+		return func(buf *bytes.Buffer, data *TemplateData) error { return t.Execute(buf, data) }, nil
+	}
+	t, err := texttemplate.New("errorpage").Funcs(texttemplate.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("模板语法错误: %v", err)
+	}
+	return func(buf *bytes.Buffer, data *TemplateData) error { return t.Execute(buf, data) }, nil
+}
+
+// defaultRenderer 进程级渲染器; 代理处理器 / 封禁中间件 / 主路由的 404 统一走 Render
+var defaultRenderer = NewRenderer()
+
+// Load 更新进程级渲染器, 由 config 热更新回调调用
+func Load(cfg *config.Config) {
+	defaultRenderer.Load(cfg)
+}
+
+// Render 使用进程级渲染器写出错误响应
+func Render(w http.ResponseWriter, r *http.Request, prefix string, status int, info Info) {
+	defaultRenderer.Render(w, r, prefix, status, info)
+}
@@ -0,0 +1,140 @@
+package errorpage
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"proxy-go/internal/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRenderer() *Renderer {
+	r := NewRenderer()
+	r.Load(&config.Config{
+		ErrorPages: map[string]config.ErrorPage{
+			"5xx": {Body: "<h1>{{.Status}} {{.Message}}</h1><p>{{.RequestID}}</p>"},
+			"404": {Body: "global 404", ContentType: "text/plain; charset=utf-8"},
+		},
+		MAP: map[string]config.PathConfig{
+			"/api": {ErrorPages: map[string]config.ErrorPage{
+				"4xx": {Body: "api {{.Status}}", JSONBody: `{"err":{{json .Message}},"rid":{{json .RequestID}}}`},
+			}},
+		},
+	})
+	return r
+}
+
+func TestRenderLookupOrder(t *testing.T) {
+	r := newTestRenderer()
+
+	tests := []struct {
+		name   string
+		prefix string
+		status int
+		want   string
+	}{
+		{"path status class wins over global code", "/api", 404, "api 404"},
+		{"falls back to global code", "/other", 404, "global 404"},
+		{"falls back to global class", "/api", 502, "<h1>502 Bad Gateway</h1><p></p>"},
+		{"builtin when nothing matches", "", 403, "Forbidden\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.Render(w, httptest.NewRequest("GET", "/x", nil), tt.prefix, tt.status, Info{})
+			if w.Code != tt.status || w.Body.String() != tt.want {
+				t.Fatalf("got %d %q, want %d %q", w.Code, w.Body.String(), tt.status, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderEscapesHTMLTemplates(t *testing.T) {
+	r := newTestRenderer()
+	w := httptest.NewRecorder()
+	r.Render(w, httptest.NewRequest("GET", "/x", nil), "", 500, Info{Message: "<script>"})
+	if strings.Contains(w.Body.String(), "<script>") {
+		t.Fatalf("message not escaped: %q", w.Body.String())
+	}
+}
+
+func TestRenderJSONNegotiation(t *testing.T) {
+	r := newTestRenderer()
+
+	req := httptest.NewRequest("GET", "/api/x", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Request-ID", "abc")
+	w := httptest.NewRecorder()
+	r.Render(w, req, "/api", 404, Info{Message: `say "hi"`})
+	if got := w.Body.String(); got != `{"err":"say \"hi\"","rid":"abc"}` {
+		t.Fatalf("custom JSON body = %q", got)
+	}
+
+	// 无 JSONBody 的错误页, JSON 客户端拿到内置结构化 JSON
+	w = httptest.NewRecorder()
+	r.Render(w, req, "", 502, Info{})
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("builtin JSON invalid: %v (%q)", err, w.Body.String())
+	}
+	if body["code"] != float64(502) || body["request_id"] != "abc" {
+		t.Fatalf("builtin JSON = %v", body)
+	}
+
+	// 不合法的客户端请求 ID 不回显
+	req.Header.Set("X-Request-ID", "<script>"+strings.Repeat("x", 200))
+	w = httptest.NewRecorder()
+	r.Render(w, req, "", 502, Info{})
+	if strings.Contains(w.Body.String(), "request_id") || strings.Contains(w.Body.String(), "script") {
+		t.Fatalf("invalid request ID echoed: %q", w.Body.String())
+	}
+}
+
+func TestRenderBanDefaultsToJSON(t *testing.T) {
+	r := NewRenderer()
+	w := httptest.NewRecorder()
+	r.Render(w, httptest.NewRequest("GET", "/x", nil), "", 429, Info{BanEndTime: time.Now().Add(time.Minute)})
+
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("ban response not JSON: %v", err)
+	}
+	if body["error"] != "IP temporarily banned due to excessive 404 errors" {
+		t.Fatalf("error = %v", body["error"])
+	}
+	if secs, _ := body["remaining_seconds"].(float64); secs < 59 || secs > 60 {
+		t.Fatalf("remaining_seconds = %v", body["remaining_seconds"])
+	}
+}
+
+func TestWantsJSON(t *testing.T) {
+	tests := map[string]bool{
+		"":                                    false,
+		"*/*":                                 false,
+		"application/json":                    true,
+		"application/problem+json":            true,
+		"text/html,application/xhtml+xml,*/*": false,
+		"text/html;q=0.5, application/json":   true,
+		"application/json;q=0.1, text/html":   false,
+	}
+	for accept, want := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", accept)
+		if got := WantsJSON(req); got != want {
+			t.Errorf("WantsJSON(%q) = %v, want %v", accept, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(map[string]config.ErrorPage{"200": {Body: "x"}}); err == nil {
+		t.Error("expected error for non-error status key")
+	}
+	if err := Validate(map[string]config.ErrorPage{"5xx": {Body: "{{.Status"}}); err == nil {
+		t.Error("expected error for broken template")
+	}
+	if err := Validate(map[string]config.ErrorPage{"404": {Body: "{{.Path}}"}, "4xx": {}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
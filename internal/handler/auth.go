@@ -5,11 +5,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"proxy-go/internal/security"
 	"proxy-go/internal/service"
 	"proxy-go/internal/utils"
 	"strings"
-
-	"github.com/woodchen-ink/go-web-utils/iputil"
 )
 
 // AuthHandler 认证处理器
@@ -33,7 +32,7 @@ func (h *AuthHandler) CheckAuth(token string) bool {
 func (h *AuthHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	auth := r.Header.Get("Authorization")
 	if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
-		log.Printf("[Auth] ERR %s %s -> 401 (%s) no token from %s", r.Method, r.URL.Path, iputil.GetClientIP(r), utils.GetRequestSource(r))
+		log.Printf("[Auth] ERR %s %s -> 401 (%s) no token from %s", r.Method, r.URL.Path, security.ClientIP(r), utils.GetRequestSource(r))
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -41,7 +40,7 @@ func (h *AuthHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	token := strings.TrimPrefix(auth, "Bearer ")
 	h.authService.RemoveToken(token)
 
-	log.Printf("[Auth] %s %s -> 200 (%s) logout success from %s", r.Method, r.URL.Path, iputil.GetClientIP(r), utils.GetRequestSource(r))
+	log.Printf("[Auth] %s %s -> 200 (%s) logout success from %s", r.Method, r.URL.Path, security.ClientIP(r), utils.GetRequestSource(r))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
@@ -59,7 +58,7 @@ func (h *AuthHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	authURL, err := h.authService.StartOAuthFlow(r)
 	if err != nil {
 		log.Printf("[Auth] ERR %s %s -> 500 (%s) OAuth flow start failed: %v from %s", 
-			r.Method, r.URL.Path, iputil.GetClientIP(r), err, utils.GetRequestSource(r))
+			r.Method, r.URL.Path, security.ClientIP(r), err, utils.GetRequestSource(r))
 		http.Error(w, "OAuth configuration error", http.StatusInternalServerError)
 		return
 	}
@@ -80,14 +79,14 @@ func (h *AuthHandler) OAuthCallbackHandler(w http.ResponseWriter, r *http.Reques
 	result, err := h.authService.HandleOAuthCallback(r, code, state)
 	if err != nil {
 		log.Printf("[Auth] ERR %s %s -> 500 (%s) OAuth callback failed: %v from %s", 
-			r.Method, r.URL.Path, iputil.GetClientIP(r), err, utils.GetRequestSource(r))
+			r.Method, r.URL.Path, security.ClientIP(r), err, utils.GetRequestSource(r))
 		http.Error(w, "OAuth callback error", http.StatusInternalServerError)
 		return
 	}
 
 	if !result.Success {
 		log.Printf("[Auth] ERR %s %s -> 400 (%s) OAuth callback failed: %s from %s", 
-			r.Method, r.URL.Path, iputil.GetClientIP(r), result.ErrorMessage, utils.GetRequestSource(r))
+			r.Method, r.URL.Path, security.ClientIP(r), result.ErrorMessage, utils.GetRequestSource(r))
 		http.Error(w, result.ErrorMessage, http.StatusBadRequest)
 		return
 	}
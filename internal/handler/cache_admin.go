@@ -1,24 +1,37 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"proxy-go/internal/cache"
 	"proxy-go/internal/config"
 	"proxy-go/internal/service"
+	"time"
 )
 
 type CacheAdminHandler struct {
 	cacheService *service.CacheService
+	cdnPurger    remoteCacheCDNPurger
 }
 
+// NewCacheAdminHandler 创建缓存管理处理器, 需要同步清理 CDN 时再调用 SetCDNHandler
 func NewCacheAdminHandler(proxyCache, mirrorCache *cache.CacheManager) *CacheAdminHandler {
 	return &CacheAdminHandler{
 		cacheService: service.NewCacheService(proxyCache, mirrorCache),
 	}
 }
 
+// SetCDNHandler 设置按标签清理时同步清理 CDN 所用的 CDN 处理器; 未设置时只清理本地缓存
+func (h *CacheAdminHandler) SetCDNHandler(cdnHandler *CDNHandler) {
+	if cdnHandler != nil {
+		h.cdnPurger = cdnHandler.cdnService
+	}
+}
+
 
 // GetCacheStats 获取缓存统计信息
 func (h *CacheAdminHandler) GetCacheStats(w http.ResponseWriter, r *http.Request) {
@@ -160,6 +173,7 @@ func (h *CacheAdminHandler) ClearCacheByPath(w http.ResponseWriter, r *http.Requ
 	var req struct {
 		Type       string `json:"type"`        // "proxy", "mirror" 或 "all"
 		PathPrefix string `json:"path_prefix"` // 路径前缀，例如 "/path1"
+		Soft       bool   `json:"soft"`        // 软清理: 只标记过期, 回源失败时仍可返回旧内容
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -172,7 +186,8 @@ func (h *CacheAdminHandler) ClearCacheByPath(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	count, err := h.cacheService.ClearCacheByPath(req.Type, req.PathPrefix)
+	opts := cache.PurgeOptions{Soft: req.Soft}
+	count, err := h.cacheService.ClearCacheByPath(req.Type, req.PathPrefix, opts)
 	if err != nil {
 		if err.Error() == "invalid cache type" {
 			http.Error(w, "Invalid cache type", http.StatusBadRequest)
@@ -186,7 +201,8 @@ func (h *CacheAdminHandler) ClearCacheByPath(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success":       true,
 		"cleared_items": count,
-		"message":       fmt.Sprintf("Cleared %d cache items for path prefix: %s", count, req.PathPrefix),
+		"soft":          req.Soft,
+		"message":       fmt.Sprintf("%s %d cache items for path prefix: %s", opts.Verb(), count, req.PathPrefix),
 	})
 }
 
@@ -200,6 +216,7 @@ func (h *CacheAdminHandler) ClearCacheByURLs(w http.ResponseWriter, r *http.Requ
 	var req struct {
 		Type string   `json:"type"` // "proxy", "mirror" 或 "all"
 		URLs []string `json:"urls"` // URL 列表，例如 ["/b2/img/photo.jpg", "/oracle/file.pdf"]
+		Soft bool     `json:"soft"` // 软清理: 只标记过期, 回源失败时仍可返回旧内容
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -212,7 +229,8 @@ func (h *CacheAdminHandler) ClearCacheByURLs(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	count, err := h.cacheService.ClearCacheByURLs(req.Type, req.URLs)
+	opts := cache.PurgeOptions{Soft: req.Soft}
+	count, err := h.cacheService.ClearCacheByURLs(req.Type, req.URLs, opts)
 	if err != nil {
 		if err.Error() == "invalid cache type" {
 			http.Error(w, "Invalid cache type", http.StatusBadRequest)
@@ -226,6 +244,190 @@ func (h *CacheAdminHandler) ClearCacheByURLs(w http.ResponseWriter, r *http.Requ
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success":       true,
 		"cleared_items": count,
-		"message":       fmt.Sprintf("Cleared %d cache items for %d URLs", count, len(req.URLs)),
+		"soft":          req.Soft,
+		"message":       fmt.Sprintf("%s %d cache items for %d URLs", opts.Verb(), count, len(req.URLs)),
+	})
+}
+
+// ClearCacheByTags 清空带有任一指定标签的缓存, purge_cdn 为 true 时同步清理 CDN 上的同名标签
+func (h *CacheAdminHandler) ClearCacheByTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Type     string   `json:"type"`      // "proxy", "mirror" 或 "all"
+		Tags     []string `json:"tags"`      // 标签列表，例如 ["article-42", "author-7"]
+		PurgeCDN bool     `json:"purge_cdn"` // 是否同时清理 CDN
+		Soft     bool     `json:"soft"`      // 软清理: 只标记过期, 回源失败时仍可返回旧内容
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tags := normalizeCacheTags(req.Tags)
+	if len(tags) == 0 {
+		http.Error(w, "tags is required and cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	opts := cache.PurgeOptions{Soft: req.Soft}
+	count, err := h.cacheService.ClearCacheByTags(req.Type, tags, opts)
+	if err != nil {
+		if err.Error() == "invalid cache type" {
+			http.Error(w, "Invalid cache type", http.StatusBadRequest)
+		} else {
+			http.Error(w, "Failed to clear cache: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := map[string]interface{}{
+		"success":       true,
+		"cleared_items": count,
+		"soft":          req.Soft,
+		"message":       fmt.Sprintf("%s %d cache items for %d tags", opts.Verb(), count, len(tags)),
+	}
+	if req.PurgeCDN {
+		result, err := h.purgeCDNByTags(r.Context(), tags, req.Soft)
+		if err != nil {
+			resp["cdn_error"] = err.Error()
+		} else {
+			resp["cdn"] = result
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ExportCache 导出缓存快照 (tar.gz / tar.zst), 参数: type=proxy|mirror, prefix=路径前缀, max_age=只导出该时长内写入的缓存 (如 24h), format=gzip|zstd
+func (h *CacheAdminHandler) ExportCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	cacheType := query.Get("type")
+	if cacheType != "proxy" && cacheType != "mirror" {
+		http.Error(w, "Invalid cache type", http.StatusBadRequest)
+		return
+	}
+	filter := cache.SnapshotFilter{PathPrefix: query.Get("prefix")}
+	if raw := query.Get("max_age"); raw != "" {
+		maxAge, err := time.ParseDuration(raw)
+		if err != nil || maxAge <= 0 {
+			http.Error(w, "Invalid max_age", http.StatusBadRequest)
+			return
+		}
+		filter.MaxAge = maxAge
+	}
+	format, err := cache.ParseSnapshotFormat(query.Get("format"))
+	if err != nil {
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+
+	ext, contentType := "tar.gz", "application/gzip"
+	if format == cache.SnapshotZstd {
+		ext, contentType = "tar.zst", "application/zstd"
+	}
+	filename := fmt.Sprintf("%s-cache-%s.%s", cacheType, time.Now().Format("20060102-150405"), ext)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	// 响应头已发出, 中途出错时客户端只会收到不完整的压缩流 (解压失败)
+	if _, err := h.cacheService.ExportCache(cacheType, w, filter, format); err != nil {
+		log.Printf("[Cache] ERR Failed to export cache: %v", err)
+	}
+}
+
+// ImportCache 导入缓存快照, 请求体为 ExportCache 导出的 tar.gz / tar.zst (或 tar), 参数: type=proxy|mirror
+func (h *CacheAdminHandler) ImportCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := h.cacheService.ImportCache(r.URL.Query().Get("type"), r.Body)
+	if err != nil {
+		switch {
+		case err.Error() == "invalid cache type":
+			http.Error(w, "Invalid cache type", http.StatusBadRequest)
+		case errors.Is(err, cache.ErrInvalidSnapshot):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to import cache: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"result":  result,
+	})
+}
+
+// VerifyCache 在后台校验指定路径前缀的缓存文件完整性, 进度与结果见缓存统计的 scrub 字段
+func (h *CacheAdminHandler) VerifyCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Type       string `json:"type"`        // "proxy" 或 "mirror"
+		PathPrefix string `json:"path_prefix"` // 路径前缀, 为空表示全部
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	files, err := h.cacheService.VerifyCache(req.Type, req.PathPrefix)
+	if err != nil {
+		switch {
+		case err.Error() == "invalid cache type":
+			http.Error(w, "Invalid cache type", http.StatusBadRequest)
+		case errors.Is(err, cache.ErrScrubRunning):
+			http.Error(w, "Cache verification already running", http.StatusConflict)
+		default:
+			http.Error(w, "Failed to verify cache: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"files":   files,
+	})
+}
+
+// purgeCDNByTags 调用启用中的 CDN provider 按标签清理
+func (h *CacheAdminHandler) purgeCDNByTags(ctx context.Context, tags []string, soft bool) (*service.CDNPurgeResult, error) {
+	if h.cdnPurger == nil {
+		return nil, service.ErrCDNNoEnabledProvider
+	}
+	provider, ok := enabledCDNProvider(h.cdnPurger.ListProviders())
+	if !ok {
+		return nil, service.ErrCDNNoEnabledProvider
+	}
+	if !service.CDNProviderSupportsTags(provider.Type) {
+		return nil, fmt.Errorf("CDN provider %s 不支持按标签清理", provider.Type)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 35*time.Second)
+	defer cancel()
+	return h.cdnPurger.Purge(ctx, service.CDNPurgeRequest{
+		Type:    service.CDNPurgeTypeTags,
+		Targets: tags,
+		Soft:    soft,
 	})
 }
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"proxy-go/internal/cache"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListCacheEntries 按条件分页列出缓存条目
+// 参数: type=proxy|mirror, q=URL 子串, prefix=URL 前缀, content_type=类型前缀, min_size / max_size=字节,
+// min_age / max_age=写入至今的时长 (如 10m, 24h), sort=last_access|created|size|access_count|url, offset, limit
+func (h *CacheAdminHandler) ListCacheEntries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := parseEntryQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.cacheService.ListCacheEntries(r.URL.Query().Get("type"), query)
+	if err != nil {
+		http.Error(w, "Invalid cache type", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// GetCacheEntry 查看单个缓存条目的完整元数据, 参数: type, id
+func (h *CacheAdminHandler) GetCacheEntry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	info, err := h.cacheService.GetCacheEntry(r.URL.Query().Get("type"), r.URL.Query().Get("id"))
+	if err != nil {
+		writeCacheEntryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// DownloadCacheEntry 下载缓存条目的内容 (按缓存原样输出, 不解压 Content-Encoding), 参数: type, id
+func (h *CacheAdminHandler) DownloadCacheEntry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	info, content, closeContent, err := h.cacheService.OpenCacheEntry(r.URL.Query().Get("type"), r.URL.Query().Get("id"))
+	if err != nil {
+		writeCacheEntryError(w, err)
+		return
+	}
+	defer closeContent()
+
+	name := path.Base(strings.SplitN(info.URL, "?", 2)[0])
+	if name == "/" || name == "." {
+		name = info.ID
+	}
+	if info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	if info.ContentEncoding != "" {
+		w.Header().Set("X-Cache-Content-Encoding", info.ContentEncoding)
+	}
+	http.ServeContent(w, r, "", info.CreatedAt, content)
+}
+
+// writeCacheEntryError 把缓存条目查询错误转换为 HTTP 响应
+func writeCacheEntryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, cache.ErrEntryNotFound):
+		http.Error(w, "Cache entry not found", http.StatusNotFound)
+	case err.Error() == "invalid cache type":
+		http.Error(w, "Invalid cache type", http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to read cache entry: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// parseEntryQuery 解析缓存条目查询参数
+func parseEntryQuery(r *http.Request) (cache.EntryQuery, error) {
+	values := r.URL.Query()
+	q := cache.EntryQuery{
+		Search:      values.Get("q"),
+		Prefix:      values.Get("prefix"),
+		ContentType: values.Get("content_type"),
+		Sort:        values.Get("sort"),
+	}
+
+	ints := map[string]*int64{"min_size": &q.MinSize, "max_size": &q.MaxSize}
+	for name, dst := range ints {
+		if raw := values.Get(name); raw != "" {
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || n < 0 {
+				return q, fmt.Errorf("invalid %s", name)
+			}
+			*dst = n
+		}
+	}
+	durations := map[string]*time.Duration{"min_age": &q.MinAge, "max_age": &q.MaxAge}
+	for name, dst := range durations {
+		if raw := values.Get(name); raw != "" {
+			d, err := time.ParseDuration(raw)
+			if err != nil || d < 0 {
+				return q, fmt.Errorf("invalid %s", name)
+			}
+			*dst = d
+		}
+	}
+	pages := map[string]*int{"offset": &q.Offset, "limit": &q.Limit}
+	for name, dst := range pages {
+		if raw := values.Get(name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				return q, fmt.Errorf("invalid %s", name)
+			}
+			*dst = n
+		}
+	}
+	return q, nil
+}
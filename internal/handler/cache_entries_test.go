@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"proxy-go/internal/cache"
+)
+
+func TestCacheEntriesInspection(t *testing.T) {
+	proxyCache, err := cache.NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(proxyCache.Stop)
+	mirrorCache, err := cache.NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mirrorCache.Stop)
+
+	put := func(path, contentType, body string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		header := make(http.Header)
+		header.Set("Content-Type", contentType)
+		if _, err := proxyCache.Put(proxyCache.GenerateCacheKey(req, false), &http.Response{StatusCode: 200, Header: header, Request: req}, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("/img/a.png", "image/png", "aaaa")
+	put("/img/b.png", "image/png", "bbbbbbbb")
+	put("/css/site.css?v=2", "text/css", "body{}")
+
+	h := NewCacheAdminHandler(proxyCache, mirrorCache)
+
+	rec := httptest.NewRecorder()
+	h.ListCacheEntries(rec, httptest.NewRequest(http.MethodGet, "/admin/api/cache/entries?type=proxy&content_type=image/&sort=size&limit=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var page cache.EntryPage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || len(page.Entries) != 1 || page.Entries[0].URL != "/img/b.png" || page.Entries[0].TTL <= 0 {
+		t.Fatalf("page = %+v", page)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ListCacheEntries(rec, httptest.NewRequest(http.MethodGet, "/admin/api/cache/entries?type=proxy&q=site.css", nil))
+	page = cache.EntryPage{}
+	json.NewDecoder(rec.Body).Decode(&page)
+	if page.Total != 1 {
+		t.Fatalf("search total = %d", page.Total)
+	}
+	id := page.Entries[0].ID
+
+	rec = httptest.NewRecorder()
+	h.GetCacheEntry(rec, httptest.NewRequest(http.MethodGet, "/admin/api/cache/entry?type=proxy&id="+id, nil))
+	var info cache.EntryInfo
+	json.NewDecoder(rec.Body).Decode(&info)
+	if rec.Code != http.StatusOK || info.URL != "/css/site.css?v=2" || info.Hash == "" || info.Size != 6 {
+		t.Fatalf("entry = %d %+v", rec.Code, info)
+	}
+
+	rec = httptest.NewRecorder()
+	h.DownloadCacheEntry(rec, httptest.NewRequest(http.MethodGet, "/admin/api/cache/entry/body?type=proxy&id="+id, nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "body{}" || rec.Header().Get("Content-Type") != "text/css" {
+		t.Fatalf("download = %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="site.css"` {
+		t.Fatalf("Content-Disposition = %s", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetCacheEntry(rec, httptest.NewRequest(http.MethodGet, "/admin/api/cache/entry?type=mirror&id="+id, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("missing entry status = %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	h.ListCacheEntries(rec, httptest.NewRequest(http.MethodGet, "/admin/api/cache/entries?type=proxy&max_age=bogus", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad query status = %d", rec.Code)
+	}
+}
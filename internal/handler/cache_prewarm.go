@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"proxy-go/internal/service"
+	"strings"
+)
+
+// CachePrewarmHandler 缓存预热管理接口 (需登录)
+type CachePrewarmHandler struct {
+	prewarm *service.PrewarmService
+}
+
+// NewCachePrewarmHandler 创建预热处理器; 预热请求交给 proxy / mirror 处理器执行, 与真实访问走同一条流水线
+func NewCachePrewarmHandler(proxy *ProxyHandler, mirror *MirrorProxyHandler) *CachePrewarmHandler {
+	var proxyHandler, mirrorHandler http.Handler
+	if proxy != nil {
+		proxyHandler = proxy
+	}
+	if mirror != nil {
+		mirrorHandler = mirror
+	}
+	return &CachePrewarmHandler{prewarm: service.NewPrewarmService(proxyHandler, mirrorHandler)}
+}
+
+// Service 返回预热服务, 供远程接口共用同一任务列表
+func (h *CachePrewarmHandler) Service() *service.PrewarmService {
+	return h.prewarm
+}
+
+// Start 创建预热任务, 立即返回任务 ID, 进度通过 Status 查询
+func (h *CachePrewarmHandler) Start(w http.ResponseWriter, r *http.Request) {
+	var req service.PrewarmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.prewarm.Start(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// Status 查询预热任务: 带 id 返回该任务及逐条结果, 否则返回最近任务列表
+func (h *CachePrewarmHandler) Status(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	if id == "" {
+		writeJSON(w, http.StatusOK, map[string]any{"jobs": h.prewarm.List()})
+		return
+	}
+
+	job, err := h.prewarm.Get(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// Cancel 取消预热任务
+func (h *CachePrewarmHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.prewarm.Cancel(req.ID); err != nil {
+		if errors.Is(err, service.ErrPrewarmJobNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"success": true})
+}
@@ -15,14 +15,14 @@ import (
 
 	"proxy-go/internal/cache"
 	"proxy-go/internal/config"
+	"proxy-go/internal/security"
 	"proxy-go/internal/service"
-
-	"github.com/woodchen-ink/go-web-utils/iputil"
 )
 
 type CacheRemoteHandler struct {
 	cacheService *service.CacheService
 	cdnPurger    remoteCacheCDNPurger
+	prewarm      *service.PrewarmService
 	token        string
 }
 
@@ -34,6 +34,7 @@ type remoteCacheCDNPurger interface {
 type remoteCacheClearRequest struct {
 	URL  string `json:"url"`
 	Type string `json:"type"`
+	Soft bool   `json:"soft"`
 }
 
 type remoteCacheClearResponse struct {
@@ -47,6 +48,34 @@ type remoteCacheClearResponseData struct {
 	NormalizedURL string `json:"normalized_url"`
 	Type          string `json:"type"`
 	ClearedItems  int    `json:"cleared_items"`
+	Soft          bool   `json:"soft,omitempty"`
+}
+
+type remoteCacheTagClearRequest struct {
+	Tags     []string `json:"tags"`
+	Type     string   `json:"type"`
+	PurgeCDN bool     `json:"purge_cdn"`
+	Soft     bool     `json:"soft"`
+}
+
+type remoteCachePrewarmResponse struct {
+	Code int                `json:"code"`
+	Data service.PrewarmJob `json:"data"`
+	Msg  string             `json:"msg"`
+}
+
+type remoteCacheTagClearResponse struct {
+	Code int                             `json:"code"`
+	Data remoteCacheTagClearResponseData `json:"data"`
+	Msg  string                          `json:"msg"`
+}
+
+type remoteCacheTagClearResponseData struct {
+	Tags         []string `json:"tags"`
+	Type         string   `json:"type"`
+	ClearedItems int      `json:"cleared_items"`
+	CDNPurge     string   `json:"cdn_purge"` // queued / skipped / unsupported
+	Soft         bool     `json:"soft,omitempty"`
 }
 
 // NewCacheRemoteHandler 创建远程单 URL 清理缓存处理器。
@@ -65,19 +94,7 @@ func NewCacheRemoteHandler(proxyCache, mirrorCache *cache.CacheManager, configMa
 
 // ClearCacheByURL 提供给外部三方调用的单 URL 缓存清理接口。
 func (h *CacheRemoteHandler) ClearCacheByURL(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		h.writeError(w, http.StatusMethodNotAllowed, "method not allowed")
-		return
-	}
-
-	if h.token == "" {
-		h.writeError(w, http.StatusNotFound, "not found")
-		return
-	}
-
-	if !h.isAuthorized(r) {
-		log.Printf("[RemoteCacheClear] AUTH_FAIL %s %s ip=%s", r.Method, r.URL.Path, iputil.GetClientIP(r))
-		h.writeError(w, http.StatusUnauthorized, "unauthorized")
+	if !h.checkRequest(w, r) {
 		return
 	}
 
@@ -98,20 +115,20 @@ func (h *CacheRemoteHandler) ClearCacheByURL(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	clearedItems, err := h.cacheService.ClearCacheByURL(cacheType, normalizedURL)
+	clearedItems, err := h.cacheService.ClearCacheByURL(cacheType, normalizedURL, cache.PurgeOptions{Soft: req.Soft})
 	if err != nil {
 		if err.Error() == "invalid cache type" {
 			h.writeError(w, http.StatusBadRequest, "invalid cache type")
 			return
 		}
 
-		log.Printf("[RemoteCacheClear] ERR ip=%s type=%s input=%q normalized=%q err=%v", iputil.GetClientIP(r), cacheType, req.URL, normalizedURL, err)
+		log.Printf("[RemoteCacheClear] ERR ip=%s type=%s input=%q normalized=%q err=%v", security.ClientIP(r), cacheType, req.URL, normalizedURL, err)
 		h.writeError(w, http.StatusInternalServerError, "failed to clear cache")
 		return
 	}
 
-	log.Printf("[RemoteCacheClear] OK ip=%s type=%s input=%q normalized=%q cleared_items=%d", iputil.GetClientIP(r), cacheType, req.URL, normalizedURL, clearedItems)
-	h.purgeCDNByURLAsync(r, req.URL, normalizedURL)
+	log.Printf("[RemoteCacheClear] OK ip=%s type=%s input=%q normalized=%q cleared_items=%d soft=%v", security.ClientIP(r), cacheType, req.URL, normalizedURL, clearedItems, req.Soft)
+	h.purgeCDNByURLAsync(r, req.URL, normalizedURL, req.Soft)
 	h.writeJSON(w, http.StatusOK, remoteCacheClearResponse{
 		Code: http.StatusOK,
 		Data: remoteCacheClearResponseData{
@@ -119,13 +136,188 @@ func (h *CacheRemoteHandler) ClearCacheByURL(w http.ResponseWriter, r *http.Requ
 			NormalizedURL: normalizedURL,
 			Type:          cacheType,
 			ClearedItems:  clearedItems,
+			Soft:          req.Soft,
+		},
+		Msg: remoteClearMessage(req.Soft),
+	})
+}
+
+// ClearCacheByTags 提供给外部三方调用的按缓存标签清理接口, purge_cdn 为 true 时同步清理 CDN 上的同名标签。
+func (h *CacheRemoteHandler) ClearCacheByTags(w http.ResponseWriter, r *http.Request) {
+	if !h.checkRequest(w, r) {
+		return
+	}
+
+	var req remoteCacheTagClearRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	tags := normalizeCacheTags(req.Tags)
+	if len(tags) == 0 {
+		h.writeError(w, http.StatusBadRequest, "tags is required")
+		return
+	}
+
+	cacheType := strings.TrimSpace(req.Type)
+	if cacheType == "" {
+		cacheType = "all"
+	}
+
+	clearedItems, err := h.cacheService.ClearCacheByTags(cacheType, tags, cache.PurgeOptions{Soft: req.Soft})
+	if err != nil {
+		if err.Error() == "invalid cache type" {
+			h.writeError(w, http.StatusBadRequest, "invalid cache type")
+			return
+		}
+
+		log.Printf("[RemoteCacheClear] ERR ip=%s type=%s tags=%q err=%v", security.ClientIP(r), cacheType, tags, err)
+		h.writeError(w, http.StatusInternalServerError, "failed to clear cache")
+		return
+	}
+
+	cdnPurge := "skipped"
+	if req.PurgeCDN {
+		cdnPurge = h.purgeCDNByTagsAsync(tags, req.Soft)
+	}
+
+	log.Printf("[RemoteCacheClear] OK ip=%s type=%s tags=%q cleared_items=%d cdn=%s", security.ClientIP(r), cacheType, tags, clearedItems, cdnPurge)
+	h.writeJSON(w, http.StatusOK, remoteCacheTagClearResponse{
+		Code: http.StatusOK,
+		Data: remoteCacheTagClearResponseData{
+			Tags:         tags,
+			Type:         cacheType,
+			ClearedItems: clearedItems,
+			CDNPurge:     cdnPurge,
+			Soft:         req.Soft,
 		},
-		Msg: "cache cleared",
+		Msg: remoteClearMessage(req.Soft),
+	})
+}
+
+// WithPrewarm 启用远程预热接口, 与管理后台共用同一个预热服务 (任务列表)。
+func (h *CacheRemoteHandler) WithPrewarm(prewarm *service.PrewarmService) *CacheRemoteHandler {
+	h.prewarm = prewarm
+	return h
+}
+
+// Prewarm 提供给外部三方调用的缓存预热接口: POST 创建任务, GET ?id= 查询任务进度与逐条结果。
+func (h *CacheRemoteHandler) Prewarm(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		if !h.checkToken(w, r) {
+			return
+		}
+		h.prewarmStatus(w, r)
+		return
+	}
+	if !h.checkRequest(w, r) {
+		return
+	}
+	if h.prewarm == nil {
+		h.writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	var req service.PrewarmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	job, err := h.prewarm.Start(req)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.Printf("[RemoteCachePrewarm] OK ip=%s type=%s job=%s", security.ClientIP(r), job.Type, job.ID)
+	h.writeJSON(w, http.StatusAccepted, remoteCachePrewarmResponse{
+		Code: http.StatusAccepted,
+		Data: job,
+		Msg:  "prewarm started",
+	})
+}
+
+func (h *CacheRemoteHandler) prewarmStatus(w http.ResponseWriter, r *http.Request) {
+	if h.prewarm == nil {
+		h.writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	job, err := h.prewarm.Get(strings.TrimSpace(r.URL.Query().Get("id")))
+	if err != nil {
+		h.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	h.writeJSON(w, http.StatusOK, remoteCachePrewarmResponse{
+		Code: http.StatusOK,
+		Data: job,
+		Msg:  job.Status,
 	})
 }
 
+// checkRequest 校验请求方法、接口开关与 Bearer Token, 失败时已写出错误响应。
+func (h *CacheRemoteHandler) checkRequest(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		h.writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	return h.checkToken(w, r)
+}
+
+// checkToken 校验接口开关与 Bearer Token, 失败时已写出错误响应。
+func (h *CacheRemoteHandler) checkToken(w http.ResponseWriter, r *http.Request) bool {
+	if h.token == "" {
+		h.writeError(w, http.StatusNotFound, "not found")
+		return false
+	}
+
+	if !h.isAuthorized(r) {
+		log.Printf("[RemoteCacheClear] AUTH_FAIL %s %s ip=%s", r.Method, r.URL.Path, security.ClientIP(r))
+		h.writeError(w, http.StatusUnauthorized, "unauthorized")
+		return false
+	}
+	return true
+}
+
+// purgeCDNByTagsAsync 后台按标签清理 CDN 缓存, 返回 queued / skipped / unsupported。
+func (h *CacheRemoteHandler) purgeCDNByTagsAsync(tags []string, soft bool) string {
+	if h.cdnPurger == nil {
+		return "skipped"
+	}
+	provider, ok := enabledCDNProvider(h.cdnPurger.ListProviders())
+	if !ok {
+		return "skipped"
+	}
+	if !service.CDNProviderSupportsTags(provider.Type) {
+		return "unsupported"
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 35*time.Second)
+		defer cancel()
+
+		result, err := h.cdnPurger.Purge(ctx, service.CDNPurgeRequest{
+			Type:    service.CDNPurgeTypeTags,
+			Targets: tags,
+			Soft:    soft,
+		})
+		if err != nil {
+			log.Printf("[RemoteCacheClear] CDN_ERR tags=%q err=%v", tags, err)
+			return
+		}
+
+		jobID := ""
+		if result != nil {
+			jobID = result.JobID
+		}
+		log.Printf("[RemoteCacheClear] CDN_OK tags=%q job_id=%q", tags, jobID)
+	}()
+	return "queued"
+}
+
 // purgeCDNByURLAsync 在本地缓存清理成功后后台触发当前启用 CDN provider 的 URL purge。
-func (h *CacheRemoteHandler) purgeCDNByURLAsync(r *http.Request, inputURL, normalizedURL string) {
+func (h *CacheRemoteHandler) purgeCDNByURLAsync(r *http.Request, inputURL, normalizedURL string, soft bool) {
 	if h.cdnPurger == nil || !hasEnabledCDNProvider(h.cdnPurger.ListProviders()) {
 		return
 	}
@@ -143,6 +335,7 @@ func (h *CacheRemoteHandler) purgeCDNByURLAsync(r *http.Request, inputURL, norma
 		result, err := h.cdnPurger.Purge(ctx, service.CDNPurgeRequest{
 			Type:    service.CDNPurgeTypeURLs,
 			Targets: []string{target},
+			Soft:    soft,
 		})
 		if err != nil {
 			log.Printf("[RemoteCacheClear] CDN_ERR target=%q err=%v", target, err)
@@ -157,14 +350,43 @@ func (h *CacheRemoteHandler) purgeCDNByURLAsync(r *http.Request, inputURL, norma
 	}()
 }
 
+// remoteClearMessage 清理成功的提示信息
+func remoteClearMessage(soft bool) string {
+	if soft {
+		return "cache soft-purged"
+	}
+	return "cache cleared"
+}
+
 // hasEnabledCDNProvider 判断是否存在启用中的 CDN provider, 未配置时远程清理保持本地 no-op。
 func hasEnabledCDNProvider(providers []config.CDNProvider) bool {
+	_, ok := enabledCDNProvider(providers)
+	return ok
+}
+
+// enabledCDNProvider 返回启用中的 CDN provider (最多一个)。
+func enabledCDNProvider(providers []config.CDNProvider) (config.CDNProvider, bool) {
 	for _, provider := range providers {
 		if provider.Enabled {
-			return true
+			return provider, true
+		}
+	}
+	return config.CDNProvider{}, false
+}
+
+// normalizeCacheTags 去除空白与重复标签, 兼容单个元素内以空格 / 逗号分隔的写法。
+func normalizeCacheTags(raw []string) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, value := range raw {
+		for _, tag := range cache.ParseTags(value) {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
 		}
 	}
-	return false
+	return tags
 }
 
 // buildRemoteCacheCDNTarget 把远程清理输入转为 CDN purge 需要的完整 URL。
@@ -253,7 +475,7 @@ func (h *CacheRemoteHandler) writeError(w http.ResponseWriter, statusCode int, m
 }
 
 // writeJSON 写出 JSON 响应。
-func (h *CacheRemoteHandler) writeJSON(w http.ResponseWriter, statusCode int, payload remoteCacheClearResponse) {
+func (h *CacheRemoteHandler) writeJSON(w http.ResponseWriter, statusCode int, payload any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 	if err := json.NewEncoder(w).Encode(payload); err != nil {
@@ -273,3 +273,46 @@ func addRemoteTestCacheEntry(t *testing.T, cacheManager *cache.CacheManager, raw
 		t.Fatalf("Put(%q) error = %v", rawURL, err)
 	}
 }
+
+func TestCacheRemoteHandlerClearCacheByTags(t *testing.T) {
+	t.Setenv("CACHE_CLEAR_REMOTE_TOKEN", "secret-token")
+
+	proxyCache := newRemoteTestCacheManager(t, "proxy")
+	mirrorCache := newRemoteTestCacheManager(t, "mirror")
+	req := httptest.NewRequest(http.MethodGet, "/b2/img/a.jpg", nil)
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Surrogate-Key": {"article-42"}}, Request: req}
+	if _, err := proxyCache.Put(proxyCache.GenerateCacheKey(req, false), resp, []byte("tagged")); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewCacheRemoteHandler(proxyCache, mirrorCache)
+	fakePurger := &remoteCacheTestCDNPurger{
+		providers: []config.CDNProvider{{ID: "cf", Type: service.CDNProviderCloudflare, Enabled: true}},
+		calls:     make(chan service.CDNPurgeRequest, 1),
+	}
+	handler.cdnPurger = fakePurger
+
+	body := `{"tags":["article-42 article-43"],"purge_cdn":true}`
+	httpReq := httptest.NewRequest(http.MethodPost, "/api/cache/clear-tags", strings.NewReader(body))
+	httpReq.Header.Set("Authorization", "Bearer secret-token")
+	recorder := httptest.NewRecorder()
+
+	handler.ClearCacheByTags(recorder, httpReq)
+
+	var response remoteCacheTagClearResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if recorder.Code != http.StatusOK || response.Data.ClearedItems != 1 || response.Data.CDNPurge != "queued" {
+		t.Fatalf("status = %d, data = %+v", recorder.Code, response.Data)
+	}
+
+	select {
+	case purgeReq := <-fakePurger.calls:
+		if purgeReq.Type != service.CDNPurgeTypeTags || len(purgeReq.Targets) != 2 {
+			t.Fatalf("purge request = %+v", purgeReq)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected async CDN tag purge call")
+	}
+}
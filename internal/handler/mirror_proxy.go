@@ -8,10 +8,10 @@ import (
 	"proxy-go/internal/cache"
 	"proxy-go/internal/config"
 	"proxy-go/internal/metrics"
+	"proxy-go/internal/security"
 	"proxy-go/internal/service"
 	"time"
 
-	"github.com/woodchen-ink/go-web-utils/iputil"
 	"golang.org/x/net/http2"
 )
 
@@ -117,8 +117,11 @@ func (h *MirrorProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// 检查缓存
 	if item, hit, notModified := h.mirrorService.CheckCache(mirrorReq); hit {
-		h.handleCacheHit(w, r, item, notModified, startTime, collector)
-		return
+		if h.handleCacheHit(w, r, item, notModified, startTime, collector) {
+			return
+		}
+		// 缓存文件已丢失, 清理记录后继续回源
+		h.mirrorService.InvalidateCache(mirrorReq)
 	}
 
 	// 创建代理请求
@@ -147,7 +150,7 @@ func (h *MirrorProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	log.Printf(h.mirrorService.CreateLogEntry(mirrorReq, resp.StatusCode, time.Since(startTime), written))
 
 	// 记录统计信息（缓存未命中）
-	collector.RecordRequestWithCache(r.URL.Path, "/mirror", resp.StatusCode, time.Since(startTime), written, iputil.GetClientIP(r), r, false, 0)
+	collector.RecordRequestWithCache(r.URL.Path, "/mirror", resp.StatusCode, time.Since(startTime), written, security.ClientIP(r), r, false, 0)
 }
 
 // handleCORSPreflight 处理CORS预检请求
@@ -155,7 +158,7 @@ func (h *MirrorProxyHandler) handleCORSPreflight(w http.ResponseWriter, r *http.
 	w.WriteHeader(http.StatusOK)
 	log.Printf("| %-6s | %3d | %12s | %15s | %10s | %-30s | CORS Preflight",
 		r.Method, http.StatusOK, time.Since(startTime),
-		iputil.GetClientIP(r), "-", r.URL.Path)
+		security.ClientIP(r), "-", r.URL.Path)
 }
 
 // handleError 处理错误
@@ -163,25 +166,34 @@ func (h *MirrorProxyHandler) handleError(w http.ResponseWriter, r *http.Request,
 	http.Error(w, message, statusCode)
 	log.Printf("| %-6s | %3d | %12s | %15s | %10s | %-30s | Error: %v",
 		r.Method, statusCode, time.Since(startTime),
-		iputil.GetClientIP(r), "-", r.URL.Path, err)
+		security.ClientIP(r), "-", r.URL.Path, err)
 }
 
-// handleCacheHit 处理缓存命中
-func (h *MirrorProxyHandler) handleCacheHit(w http.ResponseWriter, r *http.Request, item *cache.CacheItem, notModified bool, startTime time.Time, collector *metrics.Collector) {
-	w.Header().Set("Content-Type", item.ContentType)
-	if item.ContentEncoding != "" {
-		w.Header().Set("Content-Encoding", item.ContentEncoding)
+// handleCacheHit 处理缓存命中; 缓存文件无法打开时不写响应并返回 false
+func (h *MirrorProxyHandler) handleCacheHit(w http.ResponseWriter, r *http.Request, item *cache.CacheItem, notModified bool, startTime time.Time, collector *metrics.Collector) bool {
+	content, err := openCachedContent(h.Cache, item, r)
+	if err != nil {
+		log.Printf("[Cache] Cannot serve cached %s (%v), invalidated", r.URL.Path, err)
+		return false
 	}
+	defer content.Close()
+
 	w.Header().Set("CZL-Proxy-Cache-HIT", "1")
+	w.Header().Set(cache.CacheStatusHeader, cache.CacheStatusHit)
+
+	// 强 ETag 由内容哈希与编码生成, ServeContent 据此处理 If-Range / If-Match
+	item.SetValidators(w.Header())
+	content.SetHeaders(w.Header())
 
 	if notModified {
 		w.WriteHeader(http.StatusNotModified)
 		// 记录缓存命中（304响应也算命中，节省了带宽）
-		collector.RecordRequestWithCache(r.URL.Path, "/mirror", http.StatusNotModified, time.Since(startTime), 0, iputil.GetClientIP(r), r, true, item.Size)
-		return
+		collector.RecordRequestWithCache(r.URL.Path, "/mirror", http.StatusNotModified, time.Since(startTime), 0, security.ClientIP(r), r, true, item.Size)
+		return true
 	}
 
-	http.ServeFile(w, r, item.FilePath)
+	serveCachedContent(w, r, item, content)
 	// 记录缓存命中，节省的字节数等于文件大小
-	collector.RecordRequestWithCache(r.URL.Path, "/mirror", http.StatusOK, time.Since(startTime), item.Size, iputil.GetClientIP(r), r, true, item.Size)
+	collector.RecordRequestWithCache(r.URL.Path, "/mirror", http.StatusOK, time.Since(startTime), item.Size, security.ClientIP(r), r, true, item.Size)
+	return true
 }
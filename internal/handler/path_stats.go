@@ -60,6 +60,7 @@ func (h *PathStatsHandler) GetAllPathStats(w http.ResponseWriter, r *http.Reques
 			existing.Status5xx += stat.Status5xx
 			existing.CacheHits += stat.CacheHits
 			existing.CacheMisses += stat.CacheMisses
+			existing.CacheBypass += stat.CacheBypass
 			existing.BytesSaved += stat.BytesSaved
 
 			// 更新平均延迟（加权平均）
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"proxy-go/internal/errorpage"
+	"proxy-go/internal/security"
+	"proxy-go/internal/service"
+)
+
+// PeerCacheHandler 对等缓存内部接口: 其他节点缓存未命中时带令牌请求 uri (及原始 host) 对应的内容,
+// 本节点按普通代理请求处理 (命中直接返回, 未命中回源并写入缓存), 但不会再转给其他节点
+type PeerCacheHandler struct {
+	peers *service.PeerService
+	proxy http.Handler
+}
+
+// NewPeerCacheHandler 创建对等缓存内部接口
+func NewPeerCacheHandler(proxyHandler *ProxyHandler) *PeerCacheHandler {
+	return &PeerCacheHandler{
+		peers: proxyHandler.GetProxyService().Peers(),
+		proxy: proxyHandler,
+	}
+}
+
+func (h *PeerCacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 未启用时与普通不存在的路径一致
+	if h.peers == nil || !h.peers.Enabled() {
+		errorpage.Render(w, r, "", http.StatusNotFound, errorpage.Info{})
+		return
+	}
+	if !h.peers.Authorized(r) {
+		log.Printf("[Peer] Unauthorized fetch from %s", security.ClientIP(r))
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	uri := query.Get("uri")
+	target, err := url.ParseRequestURI(uri)
+	if err != nil || !strings.HasPrefix(uri, "/") || strings.HasPrefix(uri, "//") ||
+		strings.HasPrefix(target.Path, "/internal/") || strings.HasPrefix(target.Path, "/admin") {
+		http.Error(w, "invalid uri", http.StatusBadRequest)
+		return
+	}
+
+	host := query.Get("host")
+	if strings.ContainsAny(host, "/?#@ \t") {
+		http.Error(w, "invalid host", http.StatusBadRequest)
+		return
+	}
+
+	req := r.Clone(r.Context())
+	if host != "" {
+		req.Host = host
+	}
+	req.URL.Path = target.Path
+	req.URL.RawPath = target.RawPath
+	req.URL.RawQuery = target.RawQuery
+	req.RequestURI = uri
+	req.Header.Del("Authorization")
+	h.proxy.ServeHTTP(w, service.WithPeerRequest(req))
+}
@@ -2,13 +2,15 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
-	"os"
 	"proxy-go/internal/cache"
 	"proxy-go/internal/config"
+	"proxy-go/internal/errorpage"
 	"proxy-go/internal/metrics"
 	"proxy-go/internal/security"
 	"proxy-go/internal/service"
@@ -18,7 +20,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/woodchen-ink/go-web-utils/iputil"
 	"golang.org/x/net/http2"
 )
 
@@ -46,8 +47,8 @@ const (
 	maxReadFrameSize = 64 * 1024 // HTTP/2 最大读帧大小（增加）
 )
 
-// ErrorHandler 定义错误处理函数类型
-type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+// ErrorHandler 定义错误处理函数类型; status 为返回给客户端的状态码
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, status int, err error)
 
 type ProxyHandler struct {
 	// Service层依赖
@@ -223,6 +224,9 @@ func NewProxyHandler(cfg *config.Config) *ProxyHandler {
 
 	}
 
+	// file:// 目标由本地目录 Transport 处理, 与 HTTP 源站共用重试 / 回落 / 缓存流程
+	transport.RegisterProtocol("file", service.NewFileTransport())
+
 	// 初始化缓存管理器 - 从主配置获取缓存配置
 	mainConfig := config.GetConfig()
 	var cacheConfig *config.CacheConfig
@@ -245,6 +249,10 @@ func NewProxyHandler(cfg *config.Config) *ProxyHandler {
 		Transport: transport,
 		Timeout:   proxyRespTimeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			// 路径配置了重定向状态码缓存时不跟随, 由 ProcessResponse 原样返回并缓存
+			if service.PassRedirects(req) {
+				return http.ErrUseLastResponse
+			}
 			if len(via) >= 10 {
 				return fmt.Errorf("stopped after 10 redirects")
 			}
@@ -257,7 +265,8 @@ func NewProxyHandler(cfg *config.Config) *ProxyHandler {
 
 	// 初始化Service层
 	pathMatcherService := service.NewPathMatcherService(cfg.MAP)
-	proxyService := service.NewProxyService(client, cacheManager, ruleService)
+	proxyService := service.NewProxyService(client, cacheManager, ruleService).
+		WithPeers(service.NewPeerService(cfg.PeerCache))
 
 	handler := &ProxyHandler{
 		// Service层依赖
@@ -268,13 +277,19 @@ func NewProxyHandler(cfg *config.Config) *ProxyHandler {
 		startTime: startTime,
 		config:    cfg,
 		Cache:     cacheManager,
-		errorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
-			log.Printf("[Error] %s %s -> %v from %s", r.Method, r.URL.Path, err, utils.GetRequestSource(r))
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("Internal Server Error"))
+		errorHandler: func(w http.ResponseWriter, r *http.Request, status int, err error) {
+			log.Printf("[Error] %s %s -> %d %v from %s (rid: %s)", r.Method, r.URL.Path, status, err, utils.GetRequestSource(r), r.Header.Get(utils.RequestIDHeader))
+			matchResult := pathMatcherService.MatchPath(r.URL.Path)
+			errorpage.Render(w, r, matchResult.MatchedPrefix, status, errorpage.Info{})
 		},
 	}
 
+	// 初始化路径级缓存策略 (缓存键 / 独立缓存配置)
+	if cacheManager != nil {
+		cacheManager.SetKeyPolicies(cfg.MAP)
+		cacheManager.SetTagHeaders(cfg.CacheTagHeaders)
+	}
+
 	// 初始化路径级 Referer 黑名单与 Referer 重定向
 	initMatchers := buildPathRefererMatchers(cfg.MAP)
 	handler.pathRefererMatchers.Store(&initMatchers)
@@ -294,12 +309,16 @@ func NewProxyHandler(cfg *config.Config) *ProxyHandler {
 		newRedirects := buildPathRefererRedirects(newCfg.MAP)
 		handler.pathRefererRedirects.Store(&newRedirects)
 
-		// 清理ExtensionMatcher缓存，确保使用新配置
+		// 重建路径级缓存策略, 清理ExtensionMatcher缓存，确保使用新配置
 		if handler.Cache != nil {
+			handler.Cache.SetKeyPolicies(newCfg.MAP)
+			handler.Cache.SetTagHeaders(newCfg.CacheTagHeaders)
 			handler.Cache.InvalidateAllExtensionMatchers()
 			log.Printf("[Config] ExtensionMatcher缓存已清理")
 		}
 
+		handler.proxyService.Peers().Update(newCfg.PeerCache)
+
 		// 清理URL可访问性缓存和文件大小缓存
 		utils.ClearAccessibilityCache()
 		utils.ClearFileSizeCache()
@@ -315,7 +334,7 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		if err := recover(); err != nil {
 			log.Printf("[Panic] %s %s -> %v from %s", r.Method, r.URL.Path, err, utils.GetRequestSource(r))
-			h.errorHandler(w, r, fmt.Errorf("panic: %v", err))
+			h.errorHandler(w, r, http.StatusInternalServerError, fmt.Errorf("panic: %v", err))
 		}
 	}()
 
@@ -325,6 +344,9 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	start := time.Now()
 
+	// 请求 ID: 写入原始请求 (回源透传 / 指标日志读取) 并回显给客户端
+	w.Header().Set(utils.RequestIDHeader, ensureRequestID(r))
+
 	// 创建带超时的上下文
 	ctx, cancel := context.WithTimeout(r.Context(), proxyRespTimeout)
 	defer cancel()
@@ -339,7 +361,7 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// 使用路径匹配服务查找匹配的路径
 	matchResult := h.pathMatcherService.MatchPath(r.URL.Path)
 	if !matchResult.Matched {
-		http.NotFound(w, r)
+		errorpage.Render(w, r, "", http.StatusNotFound, errorpage.Info{})
 		return
 	}
 
@@ -355,7 +377,7 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 					http.Redirect(w, r, targetURL, http.StatusFound)
 					log.Printf("[RefererRedirect] %s %s (referer=%q) -> 302 %s from %s",
 						r.Method, r.URL.Path, referer, targetURL, utils.GetRequestSource(r))
-					collector.RecordRequest(r.URL.Path, matchResult.MatchedPrefix, http.StatusFound, time.Since(start), 0, iputil.GetClientIP(r), r)
+					collector.RecordRequest(r.URL.Path, matchResult.MatchedPrefix, http.StatusFound, time.Since(start), 0, security.ClientIP(r), r)
 					return
 				}
 			}
@@ -365,8 +387,8 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// 路径级 Referer 黑名单 (与全局规则叠加, 任一命中即拒); 在缓存检查之前, 防止已缓存内容被盗链方拿到
 	if matchers := h.pathRefererMatchers.Load(); matchers != nil {
 		if m, ok := (*matchers)[matchResult.MatchedPrefix]; ok && m.IsBlocked(referer) {
-			http.Error(w, "Forbidden: referer not allowed", http.StatusForbidden)
-			collector.RecordRequest(r.URL.Path, matchResult.MatchedPrefix, http.StatusForbidden, time.Since(start), 0, iputil.GetClientIP(r), r)
+			errorpage.Render(w, r, matchResult.MatchedPrefix, http.StatusForbidden, errorpage.Info{Message: "Forbidden: referer not allowed"})
+			collector.RecordRequest(r.URL.Path, matchResult.MatchedPrefix, http.StatusForbidden, time.Since(start), 0, security.ClientIP(r), r)
 			return
 		}
 	}
@@ -380,9 +402,22 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		StartTime:       start,
 	}
 
+	// 按请求体缓存的 POST / QUERY 请求先读取请求体, 用于计算缓存键与回落重放
+	if err := h.proxyService.BufferBody(proxyReq); err != nil {
+		h.errorHandler(w, r, http.StatusBadRequest, err)
+		collector.RecordRequest(r.URL.Path, matchResult.MatchedPrefix, http.StatusBadRequest, time.Since(start), 0, security.ClientIP(r), r)
+		return
+	}
+
+	// 检查状态码缓存 (404 / 301 等), 命中直接返回缓存的状态码与响应头
+	if entry, hit := h.proxyService.CheckStatusCache(proxyReq); hit {
+		h.handleStatusHit(w, r, entry, start, collector, matchResult.MatchedPrefix)
+		return
+	}
+
 	// 检查缓存
 	if item, hit, notModified := h.proxyService.CheckCache(proxyReq); hit {
-		h.handleCacheHit(w, r, item, notModified, start, collector, matchResult.MatchedPrefix, matchResult.PathConfig)
+		h.handleCacheHit(w, r, proxyReq, item, notModified, start, collector, matchResult.MatchedPrefix)
 		return
 	}
 
@@ -390,76 +425,190 @@ func (h *ProxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.runProxyOnce(w, r, proxyReq, matchResult.MatchedPrefix, start, collector)
 }
 
+// ensureRequestID 确定本次请求的 ID 并写入 r.Header: 可信代理带来的合法 X-Request-ID 原样沿用 (串起整条链路日志),
+// 否则用 utils.GenerateRequestID 生成, 防止客户端伪造任意字符串写进源站与本地日志
+func ensureRequestID(r *http.Request) string {
+	if id := r.Header.Get(utils.RequestIDHeader); utils.ValidRequestID(id) {
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil && security.IsTrustedProxy(net.ParseIP(host)) {
+			return id
+		}
+	}
+	id := utils.GenerateRequestID()
+	r.Header.Set(utils.RequestIDHeader, id)
+	return id
+}
+
+// upstreamErrorStatus 回源失败 (无任何响应) 时返回给客户端的状态码: 超时 504, 其余 (连接拒绝 / DNS / TLS 等) 502
+func upstreamErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
+
 // runProxyOnce 执行一次完整的代理流程: 检查重定向 -> 选择多源并按序回落执行 -> 处理响应 -> 记录统计。
 // 由 ServeHTTP (缓存未命中) 与 handleMissedCache (缓存文件丢失重试) 共用, 避免两处重复维护代理逻辑。
 func (h *ProxyHandler) runProxyOnce(w http.ResponseWriter, r *http.Request, proxyReq *service.ProxyRequest, matchedPrefix string, start time.Time, collector *metrics.Collector) {
 	// 检查重定向
 	if h.proxyService.CheckRedirect(proxyReq, w) {
-		collector.RecordRequest(r.URL.Path, matchedPrefix, http.StatusFound, time.Since(start), 0, iputil.GetClientIP(r), r)
+		collector.RecordRequest(r.URL.Path, matchedPrefix, http.StatusFound, time.Since(start), 0, security.ClientIP(r), r)
 		return
 	}
 
-	// 选择有序回源列表 (扩展名规则单源 / 路径级多源)
-	targets, altTarget := h.proxyService.SelectTargets(proxyReq)
-
-	// 按序执行, 失败自动回落到下一个源
-	resp, _, didFailover, err := h.proxyService.ExecuteRequestWithFailover(proxyReq, targets)
-	if err != nil {
-		h.errorHandler(w, r, fmt.Errorf("error executing request: %v", err))
-		return
+	// 对等缓存: 先向负责该 URL 的节点获取, 失败或超时再回源
+	resp, fromPeer := h.proxyService.FetchFromPeer(proxyReq)
+	var altTarget, didFailover bool
+	if !fromPeer {
+		// 选择有序回源列表 (扩展名规则单源 / 路径级多源)
+		var targets []string
+		targets, altTarget = h.proxyService.SelectTargets(proxyReq)
+
+		// 按序执行, 失败自动回落到下一个源
+		var err error
+		resp, _, didFailover, err = h.proxyService.ExecuteRequestWithFailover(proxyReq, targets)
+		if err != nil {
+			if h.serveStale(w, r, proxyReq, matchedPrefix, start, collector, err.Error()) {
+				return
+			}
+			status := upstreamErrorStatus(err)
+			h.errorHandler(w, r, status, fmt.Errorf("error executing request: %w", err))
+			collector.RecordRequest(r.URL.Path, matchedPrefix, status, time.Since(start), 0, security.ClientIP(r), r)
+			return
+		}
 	}
 
 	// 处理响应; 命中扩展名规则或发生过回落都视为 AltTarget
 	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError && h.serveStale(w, r, proxyReq, matchedPrefix, start, collector, resp.Status) {
+		return
+	}
 	written, err := h.proxyService.ProcessResponse(proxyReq, resp, w, altTarget || didFailover)
 	if err != nil {
-		h.errorHandler(w, r, err)
+		// 响应头已发出, 无法再改状态码, 只记录日志
+		log.Printf("[Error] %s %s -> %v from %s (rid: %s)", r.Method, r.URL.Path, err, utils.GetRequestSource(r), r.Header.Get(utils.RequestIDHeader))
 		return
 	}
 
-	// 记录统计信息（缓存未命中）
-	collector.RecordRequestWithCache(r.URL.Path, matchedPrefix, resp.StatusCode, time.Since(start), written, iputil.GetClientIP(r), r, false, 0)
+	// 记录统计信息（缓存未命中 / 按规则绕过缓存）
+	if proxyReq.CacheStatus() == cache.CacheStatusBypass {
+		collector.RecordCacheBypass(r.URL.Path, matchedPrefix, resp.StatusCode, time.Since(start), written, security.ClientIP(r), r)
+		return
+	}
+	collector.RecordRequestWithCache(r.URL.Path, matchedPrefix, resp.StatusCode, time.Since(start), written, security.ClientIP(r), r, false, 0)
 }
 
 // handleWelcome 处理根路径欢迎消息
 func (h *ProxyHandler) handleWelcome(w http.ResponseWriter, r *http.Request, start time.Time) {
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprint(w, "Welcome to CZL proxy.")
-	log.Printf("[Proxy] %s %s -> %d (%s) from %s", r.Method, r.URL.Path, http.StatusOK, iputil.GetClientIP(r), utils.GetRequestSource(r))
+	log.Printf("[Proxy] %s %s -> %d (%s) from %s", r.Method, r.URL.Path, http.StatusOK, security.ClientIP(r), utils.GetRequestSource(r))
 }
 
 // handleCacheHit 处理缓存命中
-func (h *ProxyHandler) handleCacheHit(w http.ResponseWriter, r *http.Request, item *cache.CacheItem, notModified bool, start time.Time, collector *metrics.Collector, matchedPrefix string, pathCfg config.PathConfig) {
-	// 🔧 修复缓存文件被删除后404的问题：在提供文件前再次验证文件是否存在
-	if _, err := os.Stat(item.FilePath); err != nil {
-		// 缓存文件不存在，清理缓存记录并重新处理请求
+func (h *ProxyHandler) handleCacheHit(w http.ResponseWriter, r *http.Request, proxyReq *service.ProxyRequest, item *cache.CacheItem, notModified bool, start time.Time, collector *metrics.Collector, matchedPrefix string) {
+	// 🔧 修复缓存文件被删除后404的问题：直接打开文件, 打开失败说明文件已不存在
+	// (缓存层只按间隔 stat, 这里的 open 同时用于发送内容, 命中路径不再额外 stat; 内存层对象不访问磁盘)
+	content, err := openCachedContent(h.Cache, item, r)
+	if err != nil {
+		// 缓存文件不存在或编码客户端无法接受，清理缓存记录并重新处理请求
 		if h.Cache != nil {
-			// 清理内存中的缓存记录, 使用与命中时一致的 cfImageOpt 策略
-			cacheKey := h.Cache.GenerateCacheKey(r, pathCfg.CFImageOpt)
-			h.Cache.InvalidateCacheItem(cacheKey)
-			log.Printf("[Cache] File missing, invalidated cache for %s", r.URL.Path)
+			// 清理内存中的缓存记录, 使用与命中时相同的缓存键 (cfImageOpt / 请求体)
+			h.proxyService.InvalidateCache(proxyReq)
+			log.Printf("[Cache] Cannot serve cached %s (%v), invalidated", r.URL.Path, err)
 		}
 		// 重新执行正常的代理流程
 		h.handleMissedCache(w, r, start, collector)
 		return
 	}
+	defer content.Close()
 
-	w.Header().Set("Content-Type", item.ContentType)
-	if item.ContentEncoding != "" {
-		w.Header().Set("Content-Encoding", item.ContentEncoding)
-	}
 	w.Header().Set("CZL-Proxy-Cache-HIT", "1")
+	w.Header().Set(cache.CacheStatusHeader, cache.CacheStatusHit)
 	w.Header().Set("CZL-Proxy-AltTarget", "0") // 缓存命中时设为0
 
+	// 强 ETag 由内容哈希与编码生成, ServeContent 据此处理 If-Range / If-Match
+	item.SetValidators(w.Header())
+	content.SetHeaders(w.Header())
+
 	if notModified {
 		w.WriteHeader(http.StatusNotModified)
 		// 记录缓存命中（304响应也算命中，节省了带宽）
-		collector.RecordRequestWithCache(r.URL.Path, matchedPrefix, http.StatusNotModified, time.Since(start), 0, iputil.GetClientIP(r), r, true, item.Size)
+		collector.RecordRequestWithCache(r.URL.Path, matchedPrefix, http.StatusNotModified, time.Since(start), 0, security.ClientIP(r), r, true, item.Size)
 		return
 	}
-	http.ServeFile(w, r, item.FilePath)
+	serveCachedContent(w, r, item, content)
 	// 记录缓存命中，节省的字节数等于文件大小
-	collector.RecordRequestWithCache(r.URL.Path, matchedPrefix, http.StatusOK, time.Since(start), item.Size, iputil.GetClientIP(r), r, true, item.Size)
+	collector.RecordRequestWithCache(r.URL.Path, matchedPrefix, http.StatusOK, time.Since(start), item.Size, security.ClientIP(r), r, true, item.Size)
+}
+
+// serveStale 回源失败 (无响应或 5xx) 时返回软清理后保留的旧内容 (stale-if-error); 没有可用的旧内容时返回 false
+func (h *ProxyHandler) serveStale(w http.ResponseWriter, r *http.Request, proxyReq *service.ProxyRequest, matchedPrefix string, start time.Time, collector *metrics.Collector, cause string) bool {
+	item, ok := h.proxyService.CheckStaleCache(proxyReq)
+	if !ok {
+		return false
+	}
+	content, err := openCachedContent(h.Cache, item, r)
+	if err != nil {
+		return false
+	}
+	defer content.Close()
+
+	w.Header().Set("CZL-Proxy-Cache-HIT", "1")
+	w.Header().Set(cache.CacheStatusHeader, cache.CacheStatusStale)
+	w.Header().Set("CZL-Proxy-AltTarget", "0")
+	item.SetValidators(w.Header())
+	content.SetHeaders(w.Header())
+	serveCachedContent(w, r, item, content)
+	log.Printf("[Cache] STALE %s %s (origin failed: %s)", r.Method, r.URL.Path, cause)
+	collector.RecordRequestWithCache(r.URL.Path, matchedPrefix, http.StatusOK, time.Since(start), item.Size, security.ClientIP(r), r, true, item.Size)
+	return true
+}
+
+// handleStatusHit 处理状态码缓存命中: 跳转只返回状态码与 Location, 404 / 410 按错误页输出
+func (h *ProxyHandler) handleStatusHit(w http.ResponseWriter, r *http.Request, entry *cache.StatusEntry, start time.Time, collector *metrics.Collector, matchedPrefix string) {
+	for name, values := range entry.Header {
+		if name == "Content-Type" && entry.StatusCode >= 400 {
+			continue // 错误页自带 Content-Type
+		}
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	w.Header().Set("CZL-Proxy-Cache-HIT", "1")
+	w.Header().Set(cache.CacheStatusHeader, cache.CacheStatusHit)
+	w.Header().Set("CZL-Proxy-AltTarget", "0")
+
+	if entry.StatusCode >= 400 {
+		errorpage.Render(w, r, matchedPrefix, entry.StatusCode, errorpage.Info{})
+	} else {
+		w.WriteHeader(entry.StatusCode)
+	}
+	collector.RecordRequestWithCache(r.URL.Path, matchedPrefix, entry.StatusCode, time.Since(start), 0, security.ClientIP(r), r, true, 0)
+}
+
+// openCachedContent 按请求的 Accept-Encoding 打开缓存内容 (未压缩副本 / br、gzip 变体 / 即时解压),
+// 内存层中的小对象直接使用内存副本; 校验头统一由 CacheItem.SetValidators 与 Content.SetHeaders 生成
+func openCachedContent(c *cache.CacheManager, item *cache.CacheItem, r *http.Request) (*cache.Content, error) {
+	return c.OpenContent(item, r.Header.Get("Accept-Encoding"))
+}
+
+// serveCachedContent 输出缓存内容: 可 Seek 的内容交给 ServeContent 处理 Range / If-Range, 即时解压的内容直接复制
+func serveCachedContent(w http.ResponseWriter, r *http.Request, item *cache.CacheItem, content *cache.Content) {
+	w.Header().Set("Content-Type", item.ContentType)
+	if body, ok := content.Body.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, item.FilePath, item.ModTime(), body)
+		return
+	}
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	buf := cache.GetBuffer(32 * 1024)
+	defer cache.PutBuffer(buf)
+	io.CopyBuffer(w, content.Body, buf)
 }
 
 // handleMissedCache 处理缓存未命中或缓存失效的情况，重新执行代理请求
@@ -467,7 +616,7 @@ func (h *ProxyHandler) handleMissedCache(w http.ResponseWriter, r *http.Request,
 	// 使用路径匹配服务查找匹配的路径
 	matchResult := h.pathMatcherService.MatchPath(r.URL.Path)
 	if !matchResult.Matched {
-		http.NotFound(w, r)
+		errorpage.Render(w, r, "", http.StatusNotFound, errorpage.Info{})
 		return
 	}
 
@@ -479,6 +628,10 @@ func (h *ProxyHandler) handleMissedCache(w http.ResponseWriter, r *http.Request,
 		TargetPath:      matchResult.TargetPath,
 		StartTime:       start,
 	}
+	if err := h.proxyService.BufferBody(proxyReq); err != nil {
+		h.errorHandler(w, r, http.StatusBadRequest, err)
+		return
+	}
 
 	// 复用统一的单次代理流程 (重定向 / 多源回落 / 响应处理 / 统计)
 	h.runProxyOnce(w, r, proxyReq, matchResult.MatchedPrefix, start, collector)
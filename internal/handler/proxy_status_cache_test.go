@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"proxy-go/internal/config"
+	"proxy-go/internal/metrics"
+)
+
+func TestProxyCachesOriginRedirects(t *testing.T) {
+	// NewProxyHandler 与指标收集器使用相对路径 data/, 在临时目录中运行
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	var originHits atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		originHits.Add(1)
+		if r.URL.Path == "/latest" {
+			http.Redirect(w, r, "/v2.zip", http.StatusFound)
+			return
+		}
+		w.Write([]byte("zip"))
+	}))
+	t.Cleanup(origin.Close)
+
+	cfg := &config.Config{MAP: map[string]config.PathConfig{
+		"/dl": {DefaultTarget: origin.URL, Enabled: true, StatusCache: map[string]int{"302": 30}},
+	}}
+	metrics.InitCollector(cfg)
+	h := NewProxyHandler(cfg)
+	t.Cleanup(h.Cache.Stop)
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dl/latest", nil))
+		if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/v2.zip" {
+			t.Fatalf("request %d: status = %d, location = %q", i+1, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+	if got := originHits.Load(); got != 1 {
+		t.Fatalf("origin hits = %d, want 1 (second redirect served from status cache)", got)
+	}
+}
@@ -5,8 +5,6 @@ import (
 	"net/http"
 	"proxy-go/internal/security"
 	"proxy-go/internal/service"
-
-	"github.com/woodchen-ink/go-web-utils/iputil"
 )
 
 // SecurityHandler 安全管理处理器
@@ -78,7 +76,7 @@ func (sh *SecurityHandler) GetSecurityStats(w http.ResponseWriter, r *http.Reque
 // CheckIPStatus 检查IP状态
 func (sh *SecurityHandler) CheckIPStatus(w http.ResponseWriter, r *http.Request) {
 	ip := r.URL.Query().Get("ip")
-	fallbackIP := iputil.GetClientIP(r)
+	fallbackIP := security.ClientIP(r)
 
 	result, err := sh.securityService.CheckIPStatus(ip, fallbackIP)
 	if err != nil {
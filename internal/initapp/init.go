@@ -3,7 +3,9 @@ package initapp
 import (
 	"context"
 	"log"
+	"net/http"
 	"proxy-go/internal/config"
+	"proxy-go/internal/errorpage"
 	"proxy-go/internal/handler"
 	"proxy-go/internal/metrics"
 	"proxy-go/internal/middleware"
@@ -37,6 +39,7 @@ type AppComponents struct {
 	MetricsHandler   *handler.MetricsHandler
 	PathStatsHandler *handler.PathStatsHandler
 	CDNHandler       *handler.CDNHandler
+	PrewarmHandler   *handler.CachePrewarmHandler
 	// Routes
 	AdminHandler router.RouteHandler
 	MainRoutes   []router.RouteHandler
@@ -178,6 +181,17 @@ func initServices(components *AppComponents) error {
 	applyReferer(components.Config)
 	config.RegisterUpdateCallback(applyReferer)
 
+	// 可信代理网段: 客户端 IP 解析 (封禁 / 指标 / 日志) 统一走 security.ClientIP, 热更新时整体替换
+	applyTrustedProxies := func(cfg *config.Config) {
+		security.SetTrustedProxies(cfg.Security.TrustedProxies)
+	}
+	applyTrustedProxies(components.Config)
+	config.RegisterUpdateCallback(applyTrustedProxies)
+
+	// 自定义错误页: 模板在加载 / 热更新时整体编译
+	errorpage.Load(components.Config)
+	config.RegisterUpdateCallback(errorpage.Load)
+
 	// 创建服务层
 	startTime := time.Now()
 	components.MetricsService = service.NewMetricsService(startTime)
@@ -195,6 +209,13 @@ func createHandlers(components *AppComponents) error {
 	components.MirrorHandler = handler.NewMirrorProxyHandler()
 	components.ProxyHandler = handler.NewProxyHandler(components.Config)
 
+	// 只有令牌有效的对等缓存请求才免于 IP 封禁
+	if peers := components.ProxyHandler.GetProxyService().Peers(); peers != nil {
+		components.SecurityMiddleware.SetTrustedRequest(func(r *http.Request) bool {
+			return r.URL.Path == service.PeerFetchPath && peers.Authorized(r)
+		})
+	}
+
 	// 创建配置处理器
 	components.ConfigHandler = handler.NewConfigHandler(components.ConfigManager)
 
@@ -213,6 +234,9 @@ func createHandlers(components *AppComponents) error {
 	// 创建 CDN 缓存清理处理器
 	components.CDNHandler = handler.NewCDNHandler(components.ConfigManager)
 
+	// 创建缓存预热处理器 (管理接口与远程接口共用任务列表)
+	components.PrewarmHandler = handler.NewCachePrewarmHandler(components.ProxyHandler, components.MirrorHandler)
+
 	log.Printf("[Init] 处理器创建完成")
 	return nil
 }
@@ -231,8 +255,9 @@ func setupRoutes(components *AppComponents) error {
 		components.SecurityHandler,
 		components.PathStatsHandler,
 		components.CDNHandler,
+		components.PrewarmHandler,
 	)
-	components.MainRoutes = router.SetupMainRoutes(components.MirrorHandler, components.ProxyHandler, components.ConfigManager)
+	components.MainRoutes = router.SetupMainRoutes(components.MirrorHandler, components.ProxyHandler, components.ConfigManager, components.PrewarmHandler)
 
 	log.Printf("[Init] 路由设置完成")
 	return nil
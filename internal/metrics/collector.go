@@ -241,6 +241,7 @@ type RequestMetric struct {
 	ClientIP    string
 	Request     *http.Request
 	CacheHit    bool  // 是否缓存命中
+	CacheBypass bool  // 是否按缓存规则绕过缓存
 	BytesSaved  int64 // 通过缓存节省的字节数
 }
 
@@ -381,6 +382,26 @@ func (c *Collector) RecordRequestWithCache(fullPath, statsPrefix string, status
 	}
 }
 
+// RecordCacheBypass 记录按缓存规则绕过缓存的请求, 单独计入 BYPASS, 不影响缓存命中率
+func (c *Collector) RecordCacheBypass(fullPath, statsPrefix string, status int, latency time.Duration, bytes int64, clientIP string, r *http.Request) {
+	metric := RequestMetric{
+		FullPath:    fullPath,
+		StatsPrefix: statsPrefix,
+		Status:      status,
+		Latency:     latency,
+		Bytes:       bytes,
+		ClientIP:    clientIP,
+		Request:     r,
+		CacheBypass: true,
+	}
+	select {
+	case requestChan <- metric:
+		// ok
+	default:
+		c.recordDrop()
+	}
+}
+
 // recordDrop 记录一次指标事件丢弃，并按水位触发告警日志
 func (c *Collector) recordDrop() {
 	dropped := atomic.AddInt64(&c.droppedMetrics, 1)
@@ -936,7 +957,9 @@ func (c *Collector) updateMetricsBatch(batch []RequestMetric) {
 		}
 
 		// 更新缓存统计
-		if m.CacheHit {
+		if m.CacheBypass {
+			pathMetrics.CacheBypass.Add(1)
+		} else if m.CacheHit {
 			pathMetrics.CacheHits.Add(1)
 			pathMetrics.BytesSaved.Add(m.BytesSaved)
 		} else {
@@ -972,9 +995,10 @@ func (c *Collector) updateMetricsBatch(batch []RequestMetric) {
 		}
 
 		// 更新最近请求记录(使用完整路径), Referer 仅在请求头存在时填入
-		var referer string
+		var referer, requestID string
 		if m.Request != nil {
 			referer = m.Request.Header.Get("Referer")
+			requestID = m.Request.Header.Get(utils.RequestIDHeader)
 		}
 		c.recentRequests.Push(models.RequestLog{
 			Time:      time.Now(),
@@ -984,6 +1008,7 @@ func (c *Collector) updateMetricsBatch(batch []RequestMetric) {
 			BytesSent: m.Bytes,
 			ClientIP:  m.ClientIP,
 			Referer:   referer,
+			RequestID: requestID,
 		})
 	}
 }
@@ -1165,6 +1190,7 @@ func (c *Collector) ResetPathStats(pathPrefix string) error {
 				stats.Status5xx.Store(0)
 				stats.CacheHits.Store(0)
 				stats.CacheMisses.Store(0)
+				stats.CacheBypass.Store(0)
 				stats.BytesSaved.Store(0)
 				stats.LastAccessTime.Store(time.Now().Unix())
 				resetCount++
@@ -1212,6 +1238,7 @@ func (c *Collector) ResetAllPathStats() error {
 		value.Status5xx.Store(0)
 		value.CacheHits.Store(0)
 		value.CacheMisses.Store(0)
+		value.CacheBypass.Store(0)
 		value.BytesSaved.Store(0)
 		value.LastAccessTime.Store(time.Now().Unix())
 		count++
@@ -140,6 +140,7 @@ func MergePathStats(current, loaded []models.PathMetricsJSON) []models.PathMetri
 			existing.Status5xx += stat.Status5xx
 			existing.CacheHits += stat.CacheHits
 			existing.CacheMisses += stat.CacheMisses
+			existing.CacheBypass += stat.CacheBypass
 			existing.BytesSaved += stat.BytesSaved
 
 			// 更新最后访问时间（取最新的）
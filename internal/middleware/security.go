@@ -3,18 +3,18 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"proxy-go/internal/errorpage"
 	"proxy-go/internal/security"
 	"strings"
 	"sync/atomic"
 	"time"
-
-	"github.com/woodchen-ink/go-web-utils/iputil"
 )
 
 // SecurityMiddleware 安全中间件
 type SecurityMiddleware struct {
 	banManager     *security.IPBanManager
 	refererMatcher atomic.Pointer[security.RefererMatcher] // 全局 Referer 黑名单, 热更新时整体替换
+	trusted        func(*http.Request) bool                // 已鉴权的节点间内部请求, 不参与封禁与错误计数
 }
 
 // NewSecurityMiddleware 创建安全中间件
@@ -29,6 +29,11 @@ func (sm *SecurityMiddleware) SetRefererMatcher(m *security.RefererMatcher) {
 	sm.refererMatcher.Store(m)
 }
 
+// SetTrustedRequest 设置可信请求判断 (如携带有效令牌的对等缓存请求), 应在开始服务前调用
+func (sm *SecurityMiddleware) SetTrustedRequest(fn func(*http.Request) bool) {
+	sm.trusted = fn
+}
+
 // isAdminPath 判断是否是管理后台路径
 func isAdminPath(path string) bool {
 	// 管理后台路径前缀
@@ -50,7 +55,7 @@ func isAdminPath(path string) bool {
 // 顺序: admin 放行 → Referer 黑名单 → IP 封禁; Referer 命中直接 403, 不计入 IP 封禁 404 计数
 func (sm *SecurityMiddleware) IPBanMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		clientIP := iputil.GetClientIP(r)
+		clientIP := security.ClientIP(r)
 
 		// 管理后台路径不受IP封禁限制
 		if isAdminPath(r.URL.Path) {
@@ -59,9 +64,16 @@ func (sm *SecurityMiddleware) IPBanMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// 令牌有效的节点间内部请求, 客户端 IP 是其他节点, 不参与封禁与 404 计数;
+		// 未通过鉴权的 /internal/ 请求与普通请求一样受封禁限制
+		if sm.trusted != nil && sm.trusted(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// 全局 Referer 黑名单
 		if m := sm.refererMatcher.Load(); m.HasRules() && m.IsBlocked(r.Header.Get("Referer")) {
-			http.Error(w, "Forbidden: referer not allowed", http.StatusForbidden)
+			errorpage.Render(w, r, "", http.StatusForbidden, errorpage.Info{Message: "Forbidden: referer not allowed"})
 			return
 		}
 
@@ -69,20 +81,12 @@ func (sm *SecurityMiddleware) IPBanMiddleware(next http.Handler) http.Handler {
 		if sm.banManager != nil && sm.banManager.IsIPBanned(clientIP) {
 			banned, banEndTime := sm.banManager.GetBanInfo(clientIP)
 			if banned {
-				// 返回429状态码和封禁信息
-				w.Header().Set("Content-Type", "application/json")
+				// 返回429状态码和封禁信息 (未配置 429 错误页时为结构化 JSON)
 				w.Header().Set("Retry-After", fmt.Sprintf("%.0f", time.Until(banEndTime).Seconds()))
-				w.WriteHeader(http.StatusTooManyRequests)
-
-				remainingTime := time.Until(banEndTime)
-				response := fmt.Sprintf(`{
-					"error": "IP temporarily banned due to excessive 404 errors",
-					"message": "您的IP因频繁访问不存在的资源而被暂时封禁",
-					"ban_end_time": "%s",
-					"remaining_seconds": %.0f
-				}`, banEndTime.Format("2006-01-02 15:04:05"), remainingTime.Seconds())
-
-				w.Write([]byte(response))
+				errorpage.Render(w, r, "", http.StatusTooManyRequests, errorpage.Info{
+					Message:    "您的IP因频繁访问不存在的资源而被暂时封禁",
+					BanEndTime: banEndTime,
+				})
 				return
 			}
 		}
@@ -96,8 +100,9 @@ func (sm *SecurityMiddleware) IPBanMiddleware(next http.Handler) http.Handler {
 		// 继续处理请求
 		next.ServeHTTP(wrapper, r)
 
-		// 如果响应是404，记录错误 (banManager 可能未启用)
-		if wrapper.statusCode == http.StatusNotFound && sm.banManager != nil {
+		// 如果响应是404 (或内部接口令牌错误的401)，记录错误 (banManager 可能未启用)
+		if sm.banManager != nil && (wrapper.statusCode == http.StatusNotFound ||
+			wrapper.statusCode == http.StatusUnauthorized && strings.HasPrefix(r.URL.Path, "/internal/")) {
 			sm.banManager.RecordError(clientIP)
 		}
 	})
@@ -30,6 +30,7 @@ type PathMetrics struct {
 	// 缓存统计
 	CacheHits        atomic.Int64 `json:"cache_hits"`   // 缓存命中
 	CacheMisses      atomic.Int64 `json:"cache_misses"` // 缓存未命中
+	CacheBypass      atomic.Int64 `json:"cache_bypass"` // 按缓存规则绕过缓存 (不计入命中率)
 	BytesSaved       atomic.Int64 `json:"bytes_saved"`  // 通过缓存节省的字节数
 }
 
@@ -51,6 +52,7 @@ type PathMetricsJSON struct {
 	// 缓存统计
 	CacheHits        int64   `json:"cache_hits"`
 	CacheMisses      int64   `json:"cache_misses"`
+	CacheBypass      int64   `json:"cache_bypass"`
 	CacheHitRate     float64 `json:"cache_hit_rate"` // 缓存命中率
 	BytesSaved       int64   `json:"bytes_saved"`
 }
@@ -118,6 +120,7 @@ func (p *PathMetrics) ToJSON() PathMetricsJSON {
 		Status5xx:        p.Status5xx.Load(),
 		CacheHits:        cacheHits,
 		CacheMisses:      cacheMisses,
+		CacheBypass:      p.CacheBypass.Load(),
 		CacheHitRate:     cacheHitRate,
 		BytesSaved:       p.BytesSaved.Load(),
 	}
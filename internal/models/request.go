@@ -13,7 +13,8 @@ type RequestLog struct {
 	Latency   int64     `json:"Latency"`
 	BytesSent int64     `json:"BytesSent"`
 	ClientIP  string    `json:"ClientIP"`
-	Referer   string    `json:"Referer"` // 引用来源 URL, 来自请求头 Referer, 可能为空
+	Referer   string    `json:"Referer"`             // 引用来源 URL, 来自请求头 Referer, 可能为空
+	RequestID string    `json:"RequestID,omitempty"` // 请求 ID (X-Request-ID), 与响应头 / 源站日志对应
 }
 
 // RequestQueue 请求队列
@@ -11,6 +11,11 @@ import (
 // 配置 JSON 一般 < 100KB，留出 5MB 余量足以覆盖 cache 批量清理等场景
 const adminMaxBodyBytes = 5 * 1024 * 1024
 
+// adminStreamingRoutes 请求体为大文件流的接口, 不受 adminMaxBodyBytes 限制
+var adminStreamingRoutes = map[string]bool{
+	"/admin/api/cache/import": true,
+}
+
 // Route 定义路由结构
 type Route struct {
 	Method      string
@@ -20,7 +25,11 @@ type Route struct {
 }
 
 // SetupAdminRoutes 设置管理员路由
-func SetupAdminRoutes(proxyHandler *handler.ProxyHandler, authHandler *handler.AuthHandler, metricsHandler *handler.MetricsHandler, mirrorHandler *handler.MirrorProxyHandler, configHandler *handler.ConfigHandler, securityHandler *handler.SecurityHandler, pathStatsHandler *handler.PathStatsHandler, cdnHandler *handler.CDNHandler) ([]Route, RouteHandler) {
+func SetupAdminRoutes(proxyHandler *handler.ProxyHandler, authHandler *handler.AuthHandler, metricsHandler *handler.MetricsHandler, mirrorHandler *handler.MirrorProxyHandler, configHandler *handler.ConfigHandler, securityHandler *handler.SecurityHandler, pathStatsHandler *handler.PathStatsHandler, cdnHandler *handler.CDNHandler, prewarmHandler *handler.CachePrewarmHandler) ([]Route, RouteHandler) {
+	// 按标签清理时同步清理 CDN 缓存
+	tagPurgeHandler := handler.NewCacheAdminHandler(proxyHandler.Cache, mirrorHandler.Cache)
+	tagPurgeHandler.SetCDNHandler(cdnHandler)
+
 	// 定义API路由
 	apiRoutes := []Route{
 		{http.MethodGet, "/admin/api/auth", authHandler.LoginHandler, false},
@@ -38,8 +47,18 @@ func SetupAdminRoutes(proxyHandler *handler.ProxyHandler, authHandler *handler.A
 		{http.MethodPost, "/admin/api/cache/clear", handler.NewCacheAdminHandler(proxyHandler.Cache, mirrorHandler.Cache).ClearCache, true},
 		{http.MethodPost, "/admin/api/cache/clear-by-path", handler.NewCacheAdminHandler(proxyHandler.Cache, mirrorHandler.Cache).ClearCacheByPath, true},
 		{http.MethodPost, "/admin/api/cache/clear-by-urls", handler.NewCacheAdminHandler(proxyHandler.Cache, mirrorHandler.Cache).ClearCacheByURLs, true},
+		{http.MethodPost, "/admin/api/cache/clear-by-tags", tagPurgeHandler.ClearCacheByTags, true},
 		{http.MethodGet, "/admin/api/cache/config", handler.NewCacheAdminHandler(proxyHandler.Cache, mirrorHandler.Cache).GetCacheConfig, true},
 		{http.MethodPost, "/admin/api/cache/config", handler.NewCacheAdminHandler(proxyHandler.Cache, mirrorHandler.Cache).UpdateCacheConfig, true},
+		{http.MethodGet, "/admin/api/cache/entries", handler.NewCacheAdminHandler(proxyHandler.Cache, mirrorHandler.Cache).ListCacheEntries, true},
+		{http.MethodGet, "/admin/api/cache/entry", handler.NewCacheAdminHandler(proxyHandler.Cache, mirrorHandler.Cache).GetCacheEntry, true},
+		{http.MethodGet, "/admin/api/cache/entry/body", handler.NewCacheAdminHandler(proxyHandler.Cache, mirrorHandler.Cache).DownloadCacheEntry, true},
+		{http.MethodGet, "/admin/api/cache/export", handler.NewCacheAdminHandler(proxyHandler.Cache, mirrorHandler.Cache).ExportCache, true},
+		{http.MethodPost, "/admin/api/cache/import", handler.NewCacheAdminHandler(proxyHandler.Cache, mirrorHandler.Cache).ImportCache, true},
+		{http.MethodPost, "/admin/api/cache/verify", handler.NewCacheAdminHandler(proxyHandler.Cache, mirrorHandler.Cache).VerifyCache, true},
+		{http.MethodPost, "/admin/api/cache/prewarm", prewarmHandler.Start, true},
+		{http.MethodGet, "/admin/api/cache/prewarm", prewarmHandler.Status, true},
+		{http.MethodPost, "/admin/api/cache/prewarm/cancel", prewarmHandler.Cancel, true},
 		{http.MethodGet, "/admin/api/path-stats", pathStatsHandler.GetAllPathStats, true},
 		{http.MethodPost, "/admin/api/path-stats/reset", pathStatsHandler.ResetPathStats, true},
 		{http.MethodPost, "/admin/api/path-stats/reset-all", pathStatsHandler.ResetAllPathStats, true},
@@ -71,7 +90,7 @@ func SetupAdminRoutes(proxyHandler *handler.ProxyHandler, authHandler *handler.A
 			// API请求处理
 			if strings.HasPrefix(r.URL.Path, "/admin/api/") {
 				// 对写入类请求限制 body 大小
-				if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
+				if (r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch) && !adminStreamingRoutes[r.URL.Path] {
 					r.Body = http.MaxBytesReader(w, r.Body, adminMaxBodyBytes)
 				}
 				for _, route := range apiRoutes {
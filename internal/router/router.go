@@ -10,6 +10,7 @@ import (
 	"os"
 	"proxy-go/internal/config"
 	"proxy-go/internal/handler"
+	"proxy-go/internal/service"
 	"strings"
 	"time"
 )
@@ -55,8 +56,11 @@ type RouteHandler struct {
 }
 
 // SetupMainRoutes 设置主要路由
-func SetupMainRoutes(mirrorHandler *handler.MirrorProxyHandler, proxyHandler *handler.ProxyHandler, configManager *config.ConfigManager) []RouteHandler {
+func SetupMainRoutes(mirrorHandler *handler.MirrorProxyHandler, proxyHandler *handler.ProxyHandler, configManager *config.ConfigManager, prewarmHandler *handler.CachePrewarmHandler) []RouteHandler {
 	remoteCacheHandler := handler.NewCacheRemoteHandler(proxyHandler.Cache, mirrorHandler.Cache, configManager)
+	if prewarmHandler != nil {
+		remoteCacheHandler.WithPrewarm(prewarmHandler.Service())
+	}
 
 	return []RouteHandler{
 		// 远程缓存清理接口
@@ -66,6 +70,25 @@ func SetupMainRoutes(mirrorHandler *handler.MirrorProxyHandler, proxyHandler *ha
 			},
 			Handler: http.HandlerFunc(remoteCacheHandler.ClearCacheByURL),
 		},
+		{
+			Matcher: func(r *http.Request) bool {
+				return r.URL.Path == "/api/cache/clear-tags"
+			},
+			Handler: http.HandlerFunc(remoteCacheHandler.ClearCacheByTags),
+		},
+		{
+			Matcher: func(r *http.Request) bool {
+				return r.URL.Path == "/api/cache/prewarm"
+			},
+			Handler: http.HandlerFunc(remoteCacheHandler.Prewarm),
+		},
+		// 对等缓存内部接口 (节点间令牌鉴权)
+		{
+			Matcher: func(r *http.Request) bool {
+				return r.URL.Path == service.PeerFetchPath
+			},
+			Handler: handler.NewPeerCacheHandler(proxyHandler),
+		},
 		// favicon.ico 处理器
 		{
 			Matcher: func(r *http.Request) bool {
@@ -22,7 +22,7 @@ func TestSetupMainRoutesPrioritizesRemoteCacheEndpoint(t *testing.T) {
 		}
 	})
 
-	routes := SetupMainRoutes(mirrorHandler, proxyHandler, nil)
+	routes := SetupMainRoutes(mirrorHandler, proxyHandler, nil, nil)
 	req, err := http.NewRequest(http.MethodPost, "/api/cache/clear-url", nil)
 	if err != nil {
 		t.Fatalf("http.NewRequest() error = %v", err)
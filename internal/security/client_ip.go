@@ -0,0 +1,191 @@
+package security
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultTrustedProxies 未配置 TrustedProxies 时默认信任的对端网段: 回环 + 私网 + 链路本地。
+// 覆盖"同机 / 同 VPC 的 nginx、Docker 网桥"这类最常见部署, 公网对端的转发头一律不信任。
+var defaultTrustedProxies = []string{
+	"127.0.0.0/8",
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"169.254.0.0/16",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+}
+
+// ClientIPResolver 按"可信代理"语义解析客户端真实 IP
+//
+// 规则:
+//   - 直连对端 (RemoteAddr, 启用 PROXY protocol 时为协议头中的源地址) 不在可信网段: 直接返回对端 IP, 忽略所有转发头
+//   - 对端可信: 依次取 CF-Connecting-IP / X-Real-IP; 再从右向左遍历 X-Forwarded-For,
+//     跳过可信跳, 返回第一个不可信的地址; 全部可信时返回最左侧地址
+//
+// 网段列表整体替换 (atomic.Pointer), 读侧无锁, 支持配置热更新。
+type ClientIPResolver struct {
+	trusted atomic.Pointer[[]*net.IPNet]
+}
+
+// NewClientIPResolver 用 CIDR 列表创建解析器; 列表为空时使用 defaultTrustedProxies
+func NewClientIPResolver(cidrs []string) *ClientIPResolver {
+	r := &ClientIPResolver{}
+	r.SetTrustedProxies(cidrs)
+	return r
+}
+
+// SetTrustedProxies 替换可信代理网段; 单个 IP (不带掩码) 按 /32 或 /128 处理, 非法条目记录日志后跳过
+func (r *ClientIPResolver) SetTrustedProxies(cidrs []string) {
+	nets := ParseCIDRs(cidrs)
+	if len(nets) == 0 {
+		nets = ParseCIDRs(defaultTrustedProxies)
+	}
+	r.trusted.Store(&nets)
+}
+
+// IsTrusted 判断 ip 是否属于可信代理网段
+func (r *ClientIPResolver) IsTrusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	nets := r.trusted.Load()
+	if nets == nil {
+		return false
+	}
+	for _, n := range *nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 解析请求的客户端 IP
+func (r *ClientIPResolver) ClientIP(req *http.Request) string {
+	peer := remoteHost(req.RemoteAddr)
+	peerIP := net.ParseIP(peer)
+	if !r.IsTrusted(peerIP) {
+		return peer
+	}
+
+	if ip := headerIP(req.Header.Get("CF-Connecting-IP")); ip != "" {
+		return ip
+	}
+	if ip := headerIP(req.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+
+	hops := forwardedForHops(req.Header.Values("X-Forwarded-For"))
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !r.IsTrusted(net.ParseIP(hops[i])) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		return hops[0]
+	}
+	return peer
+}
+
+// ParseCIDRs 解析 CIDR / 单 IP 列表, 非法条目记录日志后跳过
+func ParseCIDRs(cidrs []string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, raw := range cidrs {
+		s := strings.TrimSpace(raw)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				log.Printf("[Security] 忽略非法的可信代理地址: %q", raw)
+				continue
+			}
+			if ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			log.Printf("[Security] 忽略非法的可信代理网段: %q (%v)", raw, err)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// remoteHost 去掉 RemoteAddr 中的端口; 解析失败时原样返回
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// headerIP 校验单值头部是否为合法 IP, 非法值视为未设置, 防止把任意字符串写进封禁表 / 日志
+func headerIP(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" || net.ParseIP(value) == nil {
+		return ""
+	}
+	return value
+}
+
+// forwardedForHops 把 (可能多行的) X-Forwarded-For 展开为有序地址列表, 非法条目剔除
+func forwardedForHops(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if ip := headerIP(part); ip != "" {
+				hops = append(hops, ip)
+			}
+		}
+	}
+	return hops
+}
+
+// defaultResolver 进程级解析器; 所有客户端 IP 的消费方 (封禁中间件 / 指标 / 日志) 统一走 ClientIP
+var defaultResolver = NewClientIPResolver(nil)
+
+// ClientIP 使用进程级解析器解析客户端 IP
+func ClientIP(r *http.Request) string {
+	return defaultResolver.ClientIP(r)
+}
+
+// SetTrustedProxies 更新进程级解析器的可信代理网段, 由 config 热更新回调调用
+func SetTrustedProxies(cidrs []string) {
+	defaultResolver.SetTrustedProxies(cidrs)
+}
+
+// IsTrustedProxy 判断 ip 是否属于进程级可信代理网段
+func IsTrustedProxy(ip net.IP) bool {
+	return defaultResolver.IsTrusted(ip)
+}
+
+// RequestScheme 推断客户端请求协议: 本端 TLS 直接判 https; 对端为可信代理时采信 X-Forwarded-Proto, 否则按 http
+func RequestScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	if IsTrustedProxy(net.ParseIP(remoteHost(r.RemoteAddr))) {
+		proto := r.Header.Get("X-Forwarded-Proto")
+		if i := strings.Index(proto, ","); i >= 0 {
+			proto = proto[:i]
+		}
+		proto = strings.ToLower(strings.TrimSpace(proto))
+		if proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+	return "http"
+}
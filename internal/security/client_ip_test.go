@@ -0,0 +1,116 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPResolverIgnoresHeadersFromUntrustedPeer(t *testing.T) {
+	resolver := NewClientIPResolver([]string{"10.0.0.0/8"})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "203.0.113.9:5555"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	req.Header.Set("CF-Connecting-IP", "5.6.7.8")
+
+	if got := resolver.ClientIP(req); got != "203.0.113.9" {
+		t.Fatalf("ClientIP() = %q, want peer address", got)
+	}
+}
+
+func TestClientIPResolverWalksForwardedForFromTrustedPeer(t *testing.T) {
+	resolver := NewClientIPResolver([]string{"10.0.0.0/8", "192.0.2.1"})
+
+	tests := []struct {
+		name string
+		xff  []string
+		want string
+	}{
+		{"rightmost untrusted hop", []string{"6.6.6.6, 198.51.100.7, 192.0.2.1"}, "198.51.100.7"},
+		{"multiple header lines", []string{"6.6.6.6", "198.51.100.7", "10.1.1.1"}, "198.51.100.7"},
+		{"all trusted returns leftmost", []string{"10.0.0.2, 192.0.2.1"}, "10.0.0.2"},
+		{"garbage entries skipped", []string{"not-an-ip, 198.51.100.8"}, "198.51.100.8"},
+		{"no header returns peer", nil, "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if got := resolver.ClientIP(req); got != tt.want {
+				t.Fatalf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPResolverDefaultsToPrivateNetworks(t *testing.T) {
+	resolver := NewClientIPResolver(nil)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "127.0.0.1:1234"
+	req.Header.Set("X-Real-IP", "198.51.100.1")
+	if got := resolver.ClientIP(req); got != "198.51.100.1" {
+		t.Fatalf("ClientIP() from loopback = %q, want header value", got)
+	}
+
+	req.Header.Set("X-Real-IP", "<script>")
+	if got := resolver.ClientIP(req); got != "127.0.0.1" {
+		t.Fatalf("ClientIP() with invalid X-Real-IP = %q, want peer address", got)
+	}
+}
+
+func TestReadProxyProtocolV1(t *testing.T) {
+	r := bufio.NewReader(bytes.NewBufferString("PROXY TCP4 198.51.100.1 10.0.0.1 40000 443\r\nGET / HTTP/1.1\r\n"))
+	src, dst, err := readProxyProtocolHeader(r)
+	if err != nil {
+		t.Fatalf("readProxyProtocolHeader() error = %v", err)
+	}
+	if src.String() != "198.51.100.1:40000" || dst.String() != "10.0.0.1:443" {
+		t.Fatalf("got src=%v dst=%v", src, dst)
+	}
+	rest, _ := r.ReadString('\n')
+	if rest != "GET / HTTP/1.1\r\n" {
+		t.Fatalf("payload after header = %q", rest)
+	}
+}
+
+func TestReadProxyProtocolV2(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(proxyProtocolV2Sig)
+	buf.WriteByte(0x21) // v2 PROXY
+	buf.WriteByte(0x11) // TCP over IPv4
+	binary.Write(&buf, binary.BigEndian, uint16(12))
+	buf.Write(net.ParseIP("198.51.100.2").To4())
+	buf.Write(net.ParseIP("10.0.0.1").To4())
+	binary.Write(&buf, binary.BigEndian, uint16(40001))
+	binary.Write(&buf, binary.BigEndian, uint16(80))
+	buf.WriteString("GET")
+
+	r := bufio.NewReader(&buf)
+	src, _, err := readProxyProtocolHeader(r)
+	if err != nil {
+		t.Fatalf("readProxyProtocolHeader() error = %v", err)
+	}
+	if src.String() != "198.51.100.2:40001" {
+		t.Fatalf("src = %v", src)
+	}
+	rest := make([]byte, 3)
+	if _, err := r.Read(rest); err != nil || string(rest) != "GET" {
+		t.Fatalf("payload after header = %q (%v)", rest, err)
+	}
+}
+
+func TestReadProxyProtocolRejectsMissingSignature(t *testing.T) {
+	r := bufio.NewReader(bytes.NewBufferString("GET / HTTP/1.1\r\nHost: x\r\n\r\n"))
+	if _, _, err := readProxyProtocolHeader(r); err == nil {
+		t.Fatal("expected error for plain HTTP request")
+	}
+}
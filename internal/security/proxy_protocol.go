@@ -0,0 +1,210 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyProtocolV2Sig PROXY protocol v2 固定 12 字节签名
+var proxyProtocolV2Sig = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+const (
+	// proxyProtocolV1MaxLen v1 头部最大长度 (含 CRLF), 见 haproxy proxy-protocol.txt §2.1
+	proxyProtocolV1MaxLen = 107
+	// proxyProtocolHeaderTimeout 读取 PROXY 头的超时, 防止慢速连接占住 goroutine
+	proxyProtocolHeaderTimeout = 5 * time.Second
+)
+
+var errProxyProtocolInvalid = errors.New("invalid PROXY protocol header")
+
+// ProxyProtocolListener 在 L4 负载均衡 (HAProxy / AWS NLB / 腾讯云 CLB 等) 之后解析 PROXY protocol v1/v2 头,
+// 让 conn.RemoteAddr() 返回真实客户端地址, 后续 ClientIP 按普通直连处理。
+//
+// 只接受来自 allowed 判定为可信的对端发出的 PROXY 头; 不可信对端的连接原样透传 (不解析), 防止伪造源地址。
+// 头部在连接 goroutine 里首次 RemoteAddr/Read 时惰性解析, 不阻塞 Accept 循环。
+type ProxyProtocolListener struct {
+	net.Listener
+	allowed func(net.IP) bool
+}
+
+// NewProxyProtocolListener 包装 listener; allowed 为 nil 时接受所有对端的 PROXY 头
+func NewProxyProtocolListener(ln net.Listener, allowed func(net.IP) bool) *ProxyProtocolListener {
+	return &ProxyProtocolListener{Listener: ln, allowed: allowed}
+}
+
+// Accept 返回包装后的连接
+func (l *ProxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if l.allowed != nil {
+		if tcpAddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok && !l.allowed(tcpAddr.IP) {
+			return conn, nil
+		}
+	}
+	return &proxyProtocolConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// proxyProtocolConn 惰性解析 PROXY 头的连接包装
+type proxyProtocolConn struct {
+	net.Conn
+	reader *bufio.Reader
+	once   sync.Once
+	remote net.Addr
+	local  net.Addr
+	err    error
+}
+
+func (c *proxyProtocolConn) init() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(proxyProtocolHeaderTimeout))
+		src, dst, err := readProxyProtocolHeader(c.reader)
+		c.Conn.SetReadDeadline(time.Time{})
+		if err != nil {
+			log.Printf("[ProxyProtocol] %s: %v", c.Conn.RemoteAddr(), err)
+			c.err = err
+			return
+		}
+		c.remote, c.local = src, dst
+	})
+}
+
+// Read 首次读取前先消费 PROXY 头
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr 返回 PROXY 头中的源地址; LOCAL 命令 / 无地址时回落到真实对端
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr 返回 PROXY 头中的目的地址; 无地址时回落到真实本端
+func (c *proxyProtocolConn) LocalAddr() net.Addr {
+	c.init()
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+// readProxyProtocolHeader 识别并解析 v1/v2 头; 返回的地址可能为 nil (UNKNOWN / LOCAL)
+func readProxyProtocolHeader(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	peek, err := r.Peek(len(proxyProtocolV2Sig))
+	if err != nil && len(peek) < 5 {
+		return nil, nil, fmt.Errorf("%w: %v", errProxyProtocolInvalid, err)
+	}
+	switch {
+	case bytes.Equal(peek, proxyProtocolV2Sig):
+		return readProxyProtocolV2(r)
+	case bytes.HasPrefix(peek, []byte("PROXY")):
+		return readProxyProtocolV1(r)
+	default:
+		return nil, nil, fmt.Errorf("%w: missing signature", errProxyProtocolInvalid)
+	}
+}
+
+// readProxyProtocolV1 解析文本格式: "PROXY TCP4|TCP6|UNKNOWN src dst sport dport\r\n"
+func readProxyProtocolV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	var line []byte
+	for len(line) < proxyProtocolV1MaxLen {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", errProxyProtocolInvalid, err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, fmt.Errorf("%w: v1 header too long or not CRLF terminated", errProxyProtocolInvalid)
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, nil, fmt.Errorf("%w: malformed v1 header", errProxyProtocolInvalid)
+	}
+	if fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, fmt.Errorf("%w: malformed v1 header", errProxyProtocolInvalid)
+	}
+
+	srcIP, dstIP := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	srcPort, err1 := strconv.ParseUint(fields[4], 10, 16)
+	dstPort, err2 := strconv.ParseUint(fields[5], 10, 16)
+	if srcIP == nil || dstIP == nil || err1 != nil || err2 != nil {
+		return nil, nil, fmt.Errorf("%w: bad v1 address", errProxyProtocolInvalid)
+	}
+	return &net.TCPAddr{IP: srcIP, Port: int(srcPort)}, &net.TCPAddr{IP: dstIP, Port: int(dstPort)}, nil
+}
+
+// readProxyProtocolV2 解析二进制格式; 仅提取 TCP/UDP over IPv4/IPv6 地址, TLV 扩展整体跳过
+func readProxyProtocolV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", errProxyProtocolInvalid, err)
+	}
+	verCmd, fam := hdr[12], hdr[13]
+	length := int(binary.BigEndian.Uint16(hdr[14:16]))
+	if verCmd>>4 != 2 {
+		return nil, nil, fmt.Errorf("%w: unsupported version %d", errProxyProtocolInvalid, verCmd>>4)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", errProxyProtocolInvalid, err)
+	}
+
+	switch verCmd & 0x0F {
+	case 0x0: // LOCAL: 健康检查等, 使用真实连接地址
+		return nil, nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, nil, fmt.Errorf("%w: unsupported command %d", errProxyProtocolInvalid, verCmd&0x0F)
+	}
+
+	var ipLen int
+	switch fam >> 4 {
+	case 0x1:
+		ipLen = net.IPv4len
+	case 0x2:
+		ipLen = net.IPv6len
+	default: // AF_UNSPEC / AF_UNIX: 没有可用的 IP 地址
+		return nil, nil, nil
+	}
+	if len(payload) < ipLen*2+4 {
+		return nil, nil, fmt.Errorf("%w: short v2 address block", errProxyProtocolInvalid)
+	}
+
+	srcIP := net.IP(append([]byte(nil), payload[:ipLen]...))
+	dstIP := net.IP(append([]byte(nil), payload[ipLen:ipLen*2]...))
+	srcPort := int(binary.BigEndian.Uint16(payload[ipLen*2:]))
+	dstPort := int(binary.BigEndian.Uint16(payload[ipLen*2+2:]))
+
+	if fam&0x0F == 0x2 {
+		return &net.UDPAddr{IP: srcIP, Port: srcPort}, &net.UDPAddr{IP: dstIP, Port: dstPort}, nil
+	}
+	return &net.TCPAddr{IP: srcIP, Port: srcPort}, &net.TCPAddr{IP: dstIP, Port: dstPort}, nil
+}
@@ -10,12 +10,11 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"proxy-go/internal/security"
 	"proxy-go/internal/utils"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/woodchen-ink/go-web-utils/iputil"
 )
 
 const (
@@ -316,7 +315,7 @@ func (s *AuthService) HandleOAuthCallback(r *http.Request, code, state string) (
 	// 验证 state
 	if !s.ValidateState(state) {
 		log.Printf("[Auth] ERR %s %s -> 400 (%s) invalid state from %s",
-			r.Method, r.URL.Path, iputil.GetClientIP(r), utils.GetRequestSource(r))
+			r.Method, r.URL.Path, security.ClientIP(r), utils.GetRequestSource(r))
 		return &AuthResult{
 			Success:      false,
 			ErrorMessage: "Invalid state",
@@ -326,7 +325,7 @@ func (s *AuthService) HandleOAuthCallback(r *http.Request, code, state string) (
 	// 验证code参数
 	if code == "" {
 		log.Printf("[Auth] ERR %s %s -> 400 (%s) missing code parameter from %s",
-			r.Method, r.URL.Path, iputil.GetClientIP(r), utils.GetRequestSource(r))
+			r.Method, r.URL.Path, security.ClientIP(r), utils.GetRequestSource(r))
 		return &AuthResult{
 			Success:      false,
 			ErrorMessage: "Missing code parameter",
@@ -344,7 +343,7 @@ func (s *AuthService) HandleOAuthCallback(r *http.Request, code, state string) (
 	token, err := s.exchangeCodeForToken(code, redirectURI)
 	if err != nil {
 		log.Printf("[Auth] ERR %s %s -> 500 (%s) failed to get access token: %v from %s",
-			r.Method, r.URL.Path, iputil.GetClientIP(r), err, utils.GetRequestSource(r))
+			r.Method, r.URL.Path, security.ClientIP(r), err, utils.GetRequestSource(r))
 		return &AuthResult{
 			Success:      false,
 			ErrorMessage: fmt.Sprintf("Failed to get access token: %v", err),
@@ -355,7 +354,7 @@ func (s *AuthService) HandleOAuthCallback(r *http.Request, code, state string) (
 	userInfo, err := s.getUserInfo(token.AccessToken)
 	if err != nil {
 		log.Printf("[Auth] ERR %s %s -> 500 (%s) failed to get user info: %v from %s",
-			r.Method, r.URL.Path, iputil.GetClientIP(r), err, utils.GetRequestSource(r))
+			r.Method, r.URL.Path, security.ClientIP(r), err, utils.GetRequestSource(r))
 		return &AuthResult{
 			Success:      false,
 			ErrorMessage: fmt.Sprintf("Failed to get user info: %v", err),
@@ -365,7 +364,7 @@ func (s *AuthService) HandleOAuthCallback(r *http.Request, code, state string) (
 	// 验证用户信息
 	if userInfo.Username == "" {
 		log.Printf("[Auth] ERR %s %s -> 500 (%s) could not extract username from user info from %s",
-			r.Method, r.URL.Path, iputil.GetClientIP(r), utils.GetRequestSource(r))
+			r.Method, r.URL.Path, security.ClientIP(r), utils.GetRequestSource(r))
 		return &AuthResult{
 			Success:      false,
 			ErrorMessage: "Invalid user information: missing username",
@@ -376,7 +375,7 @@ func (s *AuthService) HandleOAuthCallback(r *http.Request, code, state string) (
 	internalToken := s.AddToken(userInfo.Username)
 
 	log.Printf("[Auth] %s %s -> 200 (%s) login success for user %s from %s",
-		r.Method, r.URL.Path, iputil.GetClientIP(r), userInfo.Username, utils.GetRequestSource(r))
+		r.Method, r.URL.Path, security.ClientIP(r), userInfo.Username, utils.GetRequestSource(r))
 
 	return &AuthResult{
 		Success:     true,
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"proxy-go/internal/cache"
+)
+
+// BufferBody 路径配置了 BodyCache 且方法匹配时读取请求体, 用于计算缓存键并在多源回落时重放;
+// 请求体超过 MaxBodySize 时恢复原请求体, 按普通请求回源且不缓存
+func (s *ProxyService) BufferBody(req *ProxyRequest) error {
+	r := req.OriginalRequest
+	cfg := req.PathConfig.BodyCache
+	if s.cache == nil || cfg == nil || req.body != nil || !cfg.AllowsMethod(r.Method) {
+		return nil
+	}
+	limit := cfg.MaxBody()
+	if r.ContentLength > limit {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	if int64(len(body)) > limit {
+		r.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+		return nil
+	}
+	// 替换为可重复读取的副本, 缓存文件丢失后重新处理 (handleMissedCache) 时还能再次读取
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	req.body = body
+	req.bodyKey = cache.BodyKey(r.Method, body, cfg)
+	return nil
+}
+
+// cacheableMethod 判断请求方法能否使用缓存: GET, 或已按请求体计算缓存键的 POST / QUERY
+func (req *ProxyRequest) cacheableMethod() bool {
+	return req.OriginalRequest.Method == http.MethodGet || req.bodyKey != ""
+}
+
+// requestBody 返回回源请求体: 已缓冲的请求体每次返回新的 Reader, 可重放给多个源
+func (req *ProxyRequest) requestBody() io.Reader {
+	if req.body != nil {
+		return bytes.NewReader(req.body)
+	}
+	return req.OriginalRequest.Body
+}
+
+// replayBody 超过上限的请求体: 先返回已读取的部分, 再继续读取原请求体
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
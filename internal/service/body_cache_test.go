@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"proxy-go/internal/cache"
+	"proxy-go/internal/config"
+)
+
+func TestPostCachedByBodyHashWithFailover(t *testing.T) {
+	var primaryHits, backupHits atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits.Add(1)
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backupHits.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"method":%q,"echo":%q}`, r.Method, body)
+	}))
+	defer backup.Close()
+
+	cm, err := cache.NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+	s := newFailoverTestService()
+	s.cache = cm
+	pathConfig := config.PathConfig{BodyCache: &config.BodyCacheConfig{CanonicalJSON: true, IgnoreFields: []string{"requestId"}, MaxBodySize: 256}}
+	targets := []string{primary.URL, backup.URL}
+
+	newReq := func(method, body string) *ProxyRequest {
+		r := httptest.NewRequest(method, "/api/graphql", strings.NewReader(body))
+		req := &ProxyRequest{OriginalRequest: r, MatchedPrefix: "/api", PathConfig: pathConfig, TargetPath: "/graphql", StartTime: time.Now()}
+		if err := s.BufferBody(req); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	// fetch 未命中时回源 (主源 503, 回落到备源) 并写入缓存, 返回响应体
+	fetch := func(req *ProxyRequest) string {
+		t.Helper()
+		if _, hit, _ := s.CheckCache(req); hit {
+			t.Fatal("unexpected cache hit")
+		}
+		resp, _, didFailover, err := s.ExecuteRequestWithFailover(req, targets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if !didFailover {
+			t.Fatal("expected failover to the backup origin")
+		}
+		w := httptest.NewRecorder()
+		if _, err := s.ProcessResponse(req, resp, w, true); err != nil {
+			t.Fatal(err)
+		}
+		return w.Body.String()
+	}
+	waitCached := func(req *ProxyRequest) *cache.CacheItem {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if item, hit, _ := s.CheckCache(req); hit {
+				return item
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("response was not cached")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	body := `{"query":"{ user(id: 1) { name } }","requestId":"a"}`
+	if got, want := fetch(newReq(http.MethodPost, body)), fmt.Sprintf(`{"method":"POST","echo":%q}`, body); got != want {
+		t.Fatalf("replayed body = %s, want %s", got, want)
+	}
+
+	// 键顺序不同、忽略字段不同的同一查询命中缓存
+	item := waitCached(newReq(http.MethodPost, `{"requestId":"b","query":"{ user(id: 1) { name } }"}`))
+	if !strings.Contains(item.ContentType, "json") {
+		t.Fatalf("cached content type = %q", item.ContentType)
+	}
+	// 不同查询、不同方法分别缓存
+	fetch(newReq(http.MethodPost, `{"query":"{ user(id: 2) { name } }"}`))
+	fetch(newReq(config.MethodQuery, body))
+	if got := backupHits.Load(); got != 3 {
+		t.Fatalf("backup hits = %d, want 3", got)
+	}
+
+	// 超过 MaxBodySize 的请求体不缓存, 也不回落; 请求体仍完整转发
+	large := `{"query":"` + strings.Repeat("x", 300) + `"}`
+	req := newReq(http.MethodPost, large)
+	if req.cacheableMethod() {
+		t.Fatal("oversized body should not be cacheable")
+	}
+	resp, _, didFailover, err := s.ExecuteRequestWithFailover(req, []string{backup.URL, primary.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if didFailover || !strings.Contains(string(got), strings.Repeat("x", 300)) {
+		t.Fatalf("oversized body: failover=%v response=%s", didFailover, got)
+	}
+
+	// 未配置 BodyCache 的路径不读取请求体
+	plain := &ProxyRequest{OriginalRequest: httptest.NewRequest(http.MethodPost, "/api/graphql", strings.NewReader(body)), StartTime: time.Now()}
+	if err := s.BufferBody(plain); err != nil || plain.body != nil || plain.cacheableMethod() {
+		t.Fatal("POST without BodyCache should not be buffered")
+	}
+}
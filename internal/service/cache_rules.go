@@ -0,0 +1,99 @@
+package service
+
+import (
+	"net/http"
+	"proxy-go/internal/config"
+	"strings"
+)
+
+// cacheBypassedByRequest 判断请求是否命中 bypass 规则 (不查缓存); 带响应条件的规则不参与
+func cacheBypassedByRequest(rules []config.CacheRule, r *http.Request) bool {
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Action != "" && rule.Action != config.CacheRuleBypass {
+			continue
+		}
+		if rule.ResponseHeader == "" && matchCacheRuleRequest(rule, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheNoStore 判断回源响应是否命中任一规则 (bypass 或 no-store), 命中时不写入缓存
+func cacheNoStore(rules []config.CacheRule, r *http.Request, resp *http.Response) bool {
+	for i := range rules {
+		rule := &rules[i]
+		if matchCacheRuleRequest(rule, r) && matchCacheRuleResponse(rule, resp) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchCacheRuleRequest 判断请求条件; 规则没有请求条件时视为满足
+func matchCacheRuleRequest(rule *config.CacheRule, r *http.Request) bool {
+	if len(rule.Methods) > 0 {
+		matched := false
+		for _, method := range rule.Methods {
+			if strings.EqualFold(method, r.Method) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if rule.Header != "" && !matchHeaderValue(r.Header, rule.Header, rule.HeaderValue) {
+		return false
+	}
+	if rule.Cookie != "" {
+		cookie, err := r.Cookie(rule.Cookie)
+		if err != nil || (rule.CookieValue != "" && cookie.Value != rule.CookieValue) {
+			return false
+		}
+	}
+	if rule.Query != "" {
+		values, ok := r.URL.Query()[rule.Query]
+		if !ok {
+			return false
+		}
+		if rule.QueryValue != "" {
+			matched := false
+			for _, v := range values {
+				if v == rule.QueryValue {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchCacheRuleResponse 判断响应条件; 规则没有响应条件时视为满足
+func matchCacheRuleResponse(rule *config.CacheRule, resp *http.Response) bool {
+	return rule.ResponseHeader == "" || matchHeaderValue(resp.Header, rule.ResponseHeader, rule.ResponseHeaderValue)
+}
+
+// matchHeaderValue 头存在且 (want 为空或任一值包含 want, 忽略大小写)
+func matchHeaderValue(h http.Header, name, want string) bool {
+	values := h.Values(name)
+	if len(values) == 0 {
+		return false
+	}
+	if want == "" {
+		return true
+	}
+	want = strings.ToLower(want)
+	for _, v := range values {
+		if strings.Contains(strings.ToLower(v), want) {
+			return true
+		}
+	}
+	return false
+}
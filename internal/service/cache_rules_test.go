@@ -0,0 +1,136 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"proxy-go/internal/cache"
+	"proxy-go/internal/config"
+)
+
+func TestCacheRuleMatching(t *testing.T) {
+	rules := []config.CacheRule{
+		{Action: config.CacheRuleBypass, Header: "Authorization"},
+		{Action: config.CacheRuleBypass, Cookie: "session"},
+		{Action: config.CacheRuleBypass, Query: "nocache", QueryValue: "1"},
+		{Action: config.CacheRuleNoStore, ResponseHeader: "Set-Cookie"},
+		{Action: config.CacheRuleNoStore, ResponseHeader: "Cache-Control", ResponseHeaderValue: "private"},
+	}
+
+	tests := []struct {
+		name   string
+		setup  func(r *http.Request)
+		bypass bool
+	}{
+		{"plain", func(r *http.Request) {}, false},
+		{"authorization", func(r *http.Request) { r.Header.Set("Authorization", "Bearer x") }, true},
+		{"session cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "session", Value: "abc"}) }, true},
+		{"other cookie", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "theme", Value: "dark"}) }, false},
+		{"query match", func(r *http.Request) { r.URL.RawQuery = "nocache=1" }, true},
+		{"query other value", func(r *http.Request) { r.URL.RawQuery = "nocache=0" }, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/static/app.js", nil)
+		tt.setup(r)
+		if got := cacheBypassedByRequest(rules, r); got != tt.bypass {
+			t.Errorf("%s: bypass = %v, want %v", tt.name, got, tt.bypass)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/static/app.js", nil)
+	responses := []struct {
+		header  http.Header
+		noStore bool
+	}{
+		{http.Header{}, false},
+		{http.Header{"Set-Cookie": {"id=1"}}, true},
+		{http.Header{"Cache-Control": {"Private, max-age=0"}}, true},
+		{http.Header{"Cache-Control": {"public"}}, false},
+	}
+	for _, tt := range responses {
+		if got := cacheNoStore(rules, r, &http.Response{Header: tt.header}); got != tt.noStore {
+			t.Errorf("header %v: no-store = %v, want %v", tt.header, got, tt.noStore)
+		}
+	}
+}
+
+func TestProcessResponseCacheStatusHeader(t *testing.T) {
+	cm, err := cache.NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+	pathConfig := config.PathConfig{CacheRules: []config.CacheRule{
+		{Action: config.CacheRuleBypass, Cookie: "session"},
+		{Action: config.CacheRuleNoStore, ResponseHeader: "Set-Cookie"},
+	}}
+	cm.SetKeyPolicies(map[string]config.PathConfig{"/app": pathConfig})
+	s := &ProxyService{cache: cm}
+
+	newReq := func(path string) *ProxyRequest {
+		return &ProxyRequest{
+			OriginalRequest: httptest.NewRequest(http.MethodGet, path, nil),
+			MatchedPrefix:   "/app",
+			PathConfig:      pathConfig,
+			TargetPath:      strings.TrimPrefix(path, "/app"),
+			StartTime:       time.Now(),
+		}
+	}
+	serve := func(req *ProxyRequest, header http.Header) string {
+		t.Helper()
+		s.CheckCache(req)
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     header,
+			Body:       io.NopCloser(strings.NewReader("body")),
+			Request:    req.OriginalRequest,
+		}
+		w := httptest.NewRecorder()
+		if _, err := s.ProcessResponse(req, resp, w, false); err != nil {
+			t.Fatal(err)
+		}
+		return w.Header().Get(cache.CacheStatusHeader)
+	}
+
+	// 带 session cookie 的请求绕过缓存, 响应也不写入缓存
+	req := newReq("/app/a.js")
+	req.OriginalRequest.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	if got := serve(req, http.Header{}); got != cache.CacheStatusBypass || req.CacheStatus() != cache.CacheStatusBypass {
+		t.Fatalf("cookie request status = %q", got)
+	}
+	if _, hit, _ := s.CheckCache(newReq("/app/a.js")); hit {
+		t.Fatal("bypassed response was cached")
+	}
+
+	// 响应带 Set-Cookie 时标记为 BYPASS 且不缓存
+	if got := serve(newReq("/app/b.js"), http.Header{"Set-Cookie": {"id=1"}}); got != cache.CacheStatusBypass {
+		t.Fatalf("set-cookie response status = %q", got)
+	}
+	if _, hit, _ := s.CheckCache(newReq("/app/b.js")); hit {
+		t.Fatal("set-cookie response was cached")
+	}
+
+	// 普通请求: MISS 后写入缓存, 再次请求命中
+	if got := serve(newReq("/app/c.js"), http.Header{}); got != cache.CacheStatusMiss {
+		t.Fatalf("plain request status = %q", got)
+	}
+	// 缓存异步提交, 等待写入完成
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		hitReq := newReq("/app/c.js")
+		if _, hit, _ := s.CheckCache(hitReq); hit {
+			if hitReq.CacheStatus() != cache.CacheStatusHit {
+				t.Fatalf("hit status = %q", hitReq.CacheStatus())
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("plain response was not cached")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
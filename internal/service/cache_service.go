@@ -2,6 +2,7 @@ package service
 
 import (
 	"errors"
+	"io"
 	"net/url"
 	"proxy-go/internal/cache"
 	"proxy-go/internal/config"
@@ -83,16 +84,16 @@ func (s *CacheService) ClearCache(cacheType string) error {
 	}
 }
 
-// ClearCacheByPath 清空指定路径前缀的缓存
-func (s *CacheService) ClearCacheByPath(cacheType string, pathPrefix string) (int, error) {
+// ClearCacheByPath 清空指定路径前缀的缓存, opts.Soft 为 true 时只标记过期
+func (s *CacheService) ClearCacheByPath(cacheType string, pathPrefix string, opts cache.PurgeOptions) (int, error) {
 	switch cacheType {
 	case "proxy":
-		return s.proxyCache.ClearCacheByPrefix(pathPrefix)
+		return s.proxyCache.ClearCacheByPrefix(pathPrefix, opts)
 	case "mirror":
-		return s.mirrorCache.ClearCacheByPrefix(pathPrefix)
+		return s.mirrorCache.ClearCacheByPrefix(pathPrefix, opts)
 	case "all":
-		proxyCount, err1 := s.proxyCache.ClearCacheByPrefix(pathPrefix)
-		mirrorCount, err2 := s.mirrorCache.ClearCacheByPrefix(pathPrefix)
+		proxyCount, err1 := s.proxyCache.ClearCacheByPrefix(pathPrefix, opts)
+		mirrorCount, err2 := s.mirrorCache.ClearCacheByPrefix(pathPrefix, opts)
 
 		// 如果任一缓存清理失败，返回错误
 		if err1 != nil {
@@ -108,16 +109,16 @@ func (s *CacheService) ClearCacheByPath(cacheType string, pathPrefix string) (in
 	}
 }
 
-// ClearCacheByURLs 清空指定 URL 列表的缓存
-func (s *CacheService) ClearCacheByURLs(cacheType string, urls []string) (int, error) {
+// ClearCacheByURLs 清空指定 URL 列表的缓存, opts.Soft 为 true 时只标记过期
+func (s *CacheService) ClearCacheByURLs(cacheType string, urls []string, opts cache.PurgeOptions) (int, error) {
 	switch cacheType {
 	case "proxy":
-		return s.proxyCache.ClearCacheByURLs(urls)
+		return s.proxyCache.ClearCacheByURLs(urls, opts)
 	case "mirror":
-		return s.mirrorCache.ClearCacheByURLs(urls)
+		return s.mirrorCache.ClearCacheByURLs(urls, opts)
 	case "all":
-		proxyCount, err1 := s.proxyCache.ClearCacheByURLs(urls)
-		mirrorCount, err2 := s.mirrorCache.ClearCacheByURLs(urls)
+		proxyCount, err1 := s.proxyCache.ClearCacheByURLs(urls, opts)
+		mirrorCount, err2 := s.mirrorCache.ClearCacheByURLs(urls, opts)
 
 		// 如果任一缓存清理失败，返回错误
 		if err1 != nil {
@@ -133,18 +134,42 @@ func (s *CacheService) ClearCacheByURLs(cacheType string, urls []string) (int, e
 	}
 }
 
-// ClearCacheByURL 清空单个 URL 对应的缓存。
-func (s *CacheService) ClearCacheByURL(cacheType string, url string) (int, error) {
+// ClearCacheByTags 清空带有任一指定标签的缓存, opts.Soft 为 true 时只标记过期
+func (s *CacheService) ClearCacheByTags(cacheType string, tags []string, opts cache.PurgeOptions) (int, error) {
+	switch cacheType {
+	case "proxy":
+		return s.proxyCache.ClearCacheByTags(tags, opts)
+	case "mirror":
+		return s.mirrorCache.ClearCacheByTags(tags, opts)
+	case "all":
+		proxyCount, err1 := s.proxyCache.ClearCacheByTags(tags, opts)
+		mirrorCount, err2 := s.mirrorCache.ClearCacheByTags(tags, opts)
+
+		if err1 != nil {
+			return proxyCount, err1
+		}
+		if err2 != nil {
+			return proxyCount + mirrorCount, err2
+		}
+
+		return proxyCount + mirrorCount, nil
+	default:
+		return 0, errors.New("invalid cache type")
+	}
+}
+
+// ClearCacheByURL 清空单个 URL 对应的缓存, opts.Soft 为 true 时只标记过期。
+func (s *CacheService) ClearCacheByURL(cacheType string, url string, opts cache.PurgeOptions) (int, error) {
 	normalizedURL := normalizeSingleCacheURL(url)
 
 	switch cacheType {
 	case "proxy":
-		return s.proxyCache.ClearCacheByURL(normalizedURL)
+		return s.proxyCache.ClearCacheByURL(normalizedURL, opts)
 	case "mirror":
-		return s.mirrorCache.ClearCacheByURL(normalizedURL)
+		return s.mirrorCache.ClearCacheByURL(normalizedURL, opts)
 	case "all":
-		proxyCount, err1 := s.proxyCache.ClearCacheByURL(normalizedURL)
-		mirrorCount, err2 := s.mirrorCache.ClearCacheByURL(normalizedURL)
+		proxyCount, err1 := s.proxyCache.ClearCacheByURL(normalizedURL, opts)
+		mirrorCount, err2 := s.mirrorCache.ClearCacheByURL(normalizedURL, opts)
 
 		if err1 != nil {
 			return proxyCount, err1
@@ -159,6 +184,72 @@ func (s *CacheService) ClearCacheByURL(cacheType string, url string) (int, error
 	}
 }
 
+// managerFor 返回 proxy / mirror 对应的缓存管理器, 只作用于单个缓存的操作使用
+func (s *CacheService) managerFor(cacheType string) (*cache.CacheManager, error) {
+	switch cacheType {
+	case "proxy":
+		return s.proxyCache, nil
+	case "mirror":
+		return s.mirrorCache, nil
+	default:
+		return nil, errors.New("invalid cache type")
+	}
+}
+
+// ExportCache 把指定类型的缓存导出为 format 压缩的快照
+func (s *CacheService) ExportCache(cacheType string, w io.Writer, filter cache.SnapshotFilter, format cache.SnapshotFormat) (cache.SnapshotExportResult, error) {
+	cm, err := s.managerFor(cacheType)
+	if err != nil {
+		return cache.SnapshotExportResult{}, err
+	}
+	return cm.Export(w, filter, format)
+}
+
+// ImportCache 把快照导入指定类型的缓存
+func (s *CacheService) ImportCache(cacheType string, r io.Reader) (cache.SnapshotImportResult, error) {
+	cm, err := s.managerFor(cacheType)
+	if err != nil {
+		return cache.SnapshotImportResult{}, err
+	}
+	return cm.Import(r)
+}
+
+// VerifyCache 在后台校验指定类型缓存中 URL 以 prefix 开头的内容文件, 返回待校验文件数
+func (s *CacheService) VerifyCache(cacheType, prefix string) (int, error) {
+	cm, err := s.managerFor(cacheType)
+	if err != nil {
+		return 0, err
+	}
+	return cm.VerifyPrefix(prefix)
+}
+
+// ListCacheEntries 按条件分页列出缓存条目
+func (s *CacheService) ListCacheEntries(cacheType string, query cache.EntryQuery) (cache.EntryPage, error) {
+	cm, err := s.managerFor(cacheType)
+	if err != nil {
+		return cache.EntryPage{}, err
+	}
+	return cm.Entries(query), nil
+}
+
+// GetCacheEntry 获取单个缓存条目的元数据
+func (s *CacheService) GetCacheEntry(cacheType, id string) (cache.EntryInfo, error) {
+	cm, err := s.managerFor(cacheType)
+	if err != nil {
+		return cache.EntryInfo{}, err
+	}
+	return cm.Entry(id)
+}
+
+// OpenCacheEntry 打开单个缓存条目的内容
+func (s *CacheService) OpenCacheEntry(cacheType, id string) (cache.EntryInfo, io.ReadSeeker, func(), error) {
+	cm, err := s.managerFor(cacheType)
+	if err != nil {
+		return cache.EntryInfo{}, nil, nil, err
+	}
+	return cm.OpenEntry(id)
+}
+
 // normalizeSingleCacheURL 将完整 URL 或路径统一为缓存清理使用的路径。
 func normalizeSingleCacheURL(rawURL string) string {
 	normalized := strings.TrimSpace(rawURL)
@@ -19,7 +19,7 @@ func TestClearCacheByURLHonorsTypeAndIgnoresQuery(t *testing.T) {
 
 	cacheService := NewCacheService(proxyCache, mirrorCache)
 
-	count, err := cacheService.ClearCacheByURL("proxy", "/b2/img/a.jpg")
+	count, err := cacheService.ClearCacheByURL("proxy", "/b2/img/a.jpg", cache.PurgeOptions{})
 	if err != nil {
 		t.Fatalf("ClearCacheByURL(proxy) returned error: %v", err)
 	}
@@ -33,7 +33,7 @@ func TestClearCacheByURLHonorsTypeAndIgnoresQuery(t *testing.T) {
 		t.Fatalf("mirror cache items after proxy clear = %d, want 1", got)
 	}
 
-	count, err = cacheService.ClearCacheByURL("mirror", "https://example.com/b2/img/a.jpg?refresh=1")
+	count, err = cacheService.ClearCacheByURL("mirror", "https://example.com/b2/img/a.jpg?refresh=1", cache.PurgeOptions{})
 	if err != nil {
 		t.Fatalf("ClearCacheByURL(mirror) returned error: %v", err)
 	}
@@ -47,7 +47,7 @@ func TestClearCacheByURLHonorsTypeAndIgnoresQuery(t *testing.T) {
 	addTestCacheEntry(t, proxyCache, "/b2/img/a.jpg?v=11")
 	addTestCacheEntry(t, mirrorCache, "/b2/img/a.jpg?v=22")
 
-	count, err = cacheService.ClearCacheByURL("all", "/b2/img/a.jpg")
+	count, err = cacheService.ClearCacheByURL("all", "/b2/img/a.jpg", cache.PurgeOptions{})
 	if err != nil {
 		t.Fatalf("ClearCacheByURL(all) returned error: %v", err)
 	}
@@ -61,7 +61,7 @@ func TestClearCacheByURLRejectsInvalidType(t *testing.T) {
 	mirrorCache := newTestCacheManager(t, "mirror")
 
 	cacheService := NewCacheService(proxyCache, mirrorCache)
-	if _, err := cacheService.ClearCacheByURL("invalid", "/b2/img/a.jpg"); err == nil {
+	if _, err := cacheService.ClearCacheByURL("invalid", "/b2/img/a.jpg", cache.PurgeOptions{}); err == nil {
 		t.Fatal("expected invalid cache type error, got nil")
 	}
 }
@@ -45,6 +45,8 @@ const (
 type CDNPurgeRequest struct {
 	Type    string   `json:"type"`
 	Targets []string `json:"targets"`
+	// Soft 软清理: 只让 CDN 节点重新验证而不直接删除 (EdgeOne 的 invalidate); 不支持的厂商按普通清理执行
+	Soft bool `json:"soft,omitempty"`
 }
 
 // CDNPurgeResult 业务层 purge 响应
@@ -125,6 +127,17 @@ func (s *CDNService) Purge(ctx context.Context, req CDNPurgeRequest) (*CDNPurgeR
 	return s.PurgeWithProvider(ctx, provider, req)
 }
 
+// CDNProviderSupportsTags 判断 provider 是否支持按缓存标签 purge
+// Cloudflare 对应 Cache-Tag (企业版), EdgeOne 对应 purge_cache_tag
+func CDNProviderSupportsTags(providerType string) bool {
+	switch providerType {
+	case CDNProviderCloudflare, CDNProviderEdgeOne:
+		return true
+	default:
+		return false
+	}
+}
+
 // PurgeWithProvider 按指定 provider 执行 purge (供测试或显式调用)
 func (s *CDNService) PurgeWithProvider(ctx context.Context, provider config.CDNProvider, req CDNPurgeRequest) (*CDNPurgeResult, error) {
 	if err := validateCDNPurgeRequest(req); err != nil {
@@ -267,6 +280,9 @@ func (s *CDNService) purgeCloudflare(ctx context.Context, provider config.CDNPro
 		}
 		return result, fmt.Errorf("cloudflare purge: %s", result.Message)
 	}
+	if req.Soft {
+		result.Message = "Cloudflare 不支持软清理, 已按普通清理执行"
+	}
 	return result, nil
 }
 
@@ -292,6 +308,10 @@ func (s *CDNService) purgeEdgeOne(ctx context.Context, provider config.CDNProvid
 	if req.Type == CDNPurgeTypeAll {
 		payload["Targets"] = []string{}
 	}
+	// invalidate 只让节点重新验证; 仅对目录 / Hostname / 全部刷新有效, URL 与标签刷新不受影响
+	if req.Soft && (req.Type == CDNPurgeTypePrefixes || req.Type == CDNPurgeTypeHosts || req.Type == CDNPurgeTypeAll) {
+		payload["Method"] = "invalidate"
+	}
 
 	const (
 		host    = "teo.tencentcloudapi.com"
@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
 	"proxy-go/internal/config"
+	"proxy-go/internal/errorpage"
 	"proxy-go/pkg/sync"
+	"strings"
 	"time"
 )
 
@@ -28,8 +31,8 @@ func (s *ConfigService) GetConfig() ([]byte, error) {
 		return nil, fmt.Errorf("配置未初始化")
 	}
 
-	// 序列化为 JSON
-	configData, err := json.Marshal(cfg)
+	// 序列化为 JSON (回源鉴权密钥脱敏)
+	configData, err := json.Marshal(redactConfig(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("序列化配置失败: %v", err)
 	}
@@ -39,6 +42,9 @@ func (s *ConfigService) GetConfig() ([]byte, error) {
 
 // SaveConfig 保存并验证配置
 func (s *ConfigService) SaveConfig(newConfig *config.Config) error {
+	// 前端回传的脱敏占位符还原为当前密钥
+	restoreRedactedSecrets(newConfig, s.configManager.GetConfig())
+
 	// 验证新配置
 	if err := s.validateConfig(newConfig); err != nil {
 		return fmt.Errorf("配置验证失败: %v", err)
@@ -98,8 +104,52 @@ func (s *ConfigService) PullConfigFromD1() ([]byte, error) {
 
 	fmt.Printf("[Config] 从 D1 拉取配置成功: %d 个路径映射\n", len(newConfig.MAP))
 
-	// 返回配置数据
-	return configJson, nil
+	// 返回配置数据 (回源鉴权密钥脱敏)
+	redacted, err := json.Marshal(redactConfig(&newConfig))
+	if err != nil {
+		return nil, fmt.Errorf("序列化配置失败: %v", err)
+	}
+	return redacted, nil
+}
+
+// validateEviction 验证淘汰策略与磁盘水位
+func validateEviction(cc config.CacheConfig) error {
+	switch strings.ToLower(cc.EvictionPolicy) {
+	case "", config.EvictionLRU, config.EvictionLFU, config.EvictionGreedyDual, config.EvictionTinyLFU:
+	default:
+		return fmt.Errorf("eviction_policy 取值无效: %s", cc.EvictionPolicy)
+	}
+	high, low := cc.HighWatermark, cc.LowWatermark
+	if high == 0 {
+		high = 95
+	}
+	if low == 0 {
+		low = 85
+	}
+	if high < 1 || high > 100 || low < 1 || low >= high {
+		return fmt.Errorf("水位需满足 0 < low_watermark < high_watermark <= 100")
+	}
+	if cc.MinFreeSpace < 0 {
+		return fmt.Errorf("min_free_space 不能为负数")
+	}
+	return nil
+}
+
+// validateCacheRule 验证缓存绕过规则: 动作取值有效且至少有一个条件
+func validateCacheRule(rule config.CacheRule) error {
+	switch rule.Action {
+	case "", config.CacheRuleBypass, config.CacheRuleNoStore:
+	default:
+		return fmt.Errorf("Action 取值无效: %s", rule.Action)
+	}
+	if len(rule.Methods) == 0 && rule.Header == "" && rule.Cookie == "" && rule.Query == "" && rule.ResponseHeader == "" {
+		return fmt.Errorf("至少需要一个匹配条件")
+	}
+	if (rule.HeaderValue != "" && rule.Header == "") || (rule.CookieValue != "" && rule.Cookie == "") ||
+		(rule.QueryValue != "" && rule.Query == "") || (rule.ResponseHeaderValue != "" && rule.ResponseHeader == "") {
+		return fmt.Errorf("填写了值但缺少对应的名称")
+	}
+	return nil
 }
 
 // validateConfig 验证配置
@@ -108,6 +158,12 @@ func (s *ConfigService) validateConfig(cfg *config.Config) error {
 		return fmt.Errorf("配置不能为空")
 	}
 
+	for name, cc := range map[string]config.CacheConfig{"Cache": cfg.Cache, "MirrorCache": cfg.MirrorCache} {
+		if err := validateEviction(cc); err != nil {
+			return fmt.Errorf("%s %v", name, err)
+		}
+	}
+
 	// 验证MAP配置
 	if cfg.MAP == nil {
 		return fmt.Errorf("MAP配置不能为空")
@@ -123,7 +179,177 @@ func (s *ConfigService) validateConfig(cfg *config.Config) error {
 		if _, err := url.Parse(pathConfig.DefaultTarget); err != nil {
 			return fmt.Errorf("路径 %s 的默认目标URL无效: %v", path, err)
 		}
+		if fh := pathConfig.ForwardHeaders; fh != nil {
+			switch fh.XForwardedFor {
+			case "", config.ForwardForAppend, config.ForwardForReplace, config.ForwardForOff:
+			default:
+				return fmt.Errorf("路径 %s 的 XForwardedFor 取值无效: %s", path, fh.XForwardedFor)
+			}
+		}
+		if err := errorpage.Validate(pathConfig.ErrorPages); err != nil {
+			return fmt.Errorf("路径 %s 的%v", path, err)
+		}
+		if ck := pathConfig.CacheKey; ck != nil {
+			switch ck.QueryMode {
+			case "", config.CacheKeyQueryAll, config.CacheKeyQueryIgnore, config.CacheKeyQueryInclude, config.CacheKeyQueryExclude:
+			default:
+				return fmt.Errorf("路径 %s 的 CacheKey.QueryMode 取值无效: %s", path, ck.QueryMode)
+			}
+		}
+		if cc := pathConfig.CacheConfig; cc != nil && (cc.MaxAge < 0 || cc.CleanupTick < 0 || cc.MaxCacheSize < 0 || cc.MaxObjectSize < 0) {
+			return fmt.Errorf("路径 %s 的 CacheConfig 取值不能为负数", path)
+		}
+		if cc := pathConfig.CacheConfig; cc != nil {
+			if err := validateEviction(*cc); err != nil {
+				return fmt.Errorf("路径 %s 的 CacheConfig %v", path, err)
+			}
+		}
+		if _, err := pathConfig.StatusCacheTTLs(); err != nil {
+			return fmt.Errorf("路径 %s 的%v", path, err)
+		}
+		for i, rule := range pathConfig.CacheRules {
+			if err := validateCacheRule(rule); err != nil {
+				return fmt.Errorf("路径 %s 的 CacheRules[%d] %v", path, i, err)
+			}
+		}
+		if bc := pathConfig.BodyCache; bc != nil {
+			if bc.MaxBodySize < 0 {
+				return fmt.Errorf("路径 %s 的 BodyCache.MaxBodySize 不能为负数", path)
+			}
+			for _, m := range bc.Methods {
+				if method := strings.ToUpper(strings.TrimSpace(m)); method != http.MethodPost && method != config.MethodQuery {
+					return fmt.Errorf("路径 %s 的 BodyCache.Methods 只支持 POST / QUERY: %s", path, m)
+				}
+			}
+		}
+		if err := validateFileTargets(pathConfig); err != nil {
+			return fmt.Errorf("路径 %s 的%v", path, err)
+		}
+		for target, auth := range pathConfig.OriginAuth {
+			if err := validateOriginAuth(auth); err != nil {
+				return fmt.Errorf("路径 %s 目标 %s 的回源鉴权配置无效: %v", path, target, err)
+			}
+		}
+	}
+	if err := errorpage.Validate(cfg.ErrorPages); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateFileTargets 校验本地目录 (file://) 目标: 必须是不带主机名的绝对路径, 且不能与 302 跳转模式同用
+func validateFileTargets(pc config.PathConfig) error {
+	check := func(target string, redirect bool) error {
+		if !config.IsFileTarget(target) {
+			return nil
+		}
+		if redirect {
+			return fmt.Errorf("本地目录目标 %s 不支持 RedirectMode", target)
+		}
+		if root := config.FileTargetRoot(target); !strings.HasPrefix(root, "/") {
+			return fmt.Errorf("本地目录目标 %s 必须为 file:///绝对路径 形式", target)
+		}
+		return nil
+	}
+	for _, target := range pc.GetTargets() {
+		if err := check(target, pc.RedirectMode); err != nil {
+			return err
+		}
+	}
+	for _, rule := range pc.ExtensionMap {
+		if err := check(rule.Target, rule.RedirectMode); err != nil {
+			return err
+		}
 	}
+	if fs := pc.FileServer; fs != nil {
+		switch strings.ToLower(fs.Listing) {
+		case "", config.FileListingHTML, config.FileListingJSON:
+		default:
+			return fmt.Errorf("FileServer.Listing 取值无效: %s", fs.Listing)
+		}
+	}
+	return nil
+}
 
+// validateOriginAuth 校验回源鉴权配置; 密钥取自环境变量时只校验变量名已填写
+func validateOriginAuth(auth config.OriginAuthConfig) error {
+	switch strings.ToLower(auth.Type) {
+	case config.OriginAuthSigV4:
+		if auth.Region == "" {
+			return fmt.Errorf("SigV4 缺少 Region")
+		}
+		if auth.AccessKeyID == "" && auth.AccessKeyIDEnv == "" {
+			return fmt.Errorf("SigV4 缺少 AccessKeyID 或 AccessKeyIDEnv")
+		}
+		if auth.SecretAccessKey == "" && auth.SecretAccessKeyEnv == "" {
+			return fmt.Errorf("SigV4 缺少 SecretAccessKey 或 SecretAccessKeyEnv")
+		}
+		if auth.PresignExpires < 0 || auth.PresignExpires > 7*24*3600 {
+			return fmt.Errorf("PresignExpires 需在 0-604800 秒之间")
+		}
+	case config.OriginAuthBearer:
+		if auth.TokenEnv == "" {
+			return fmt.Errorf("bearer 缺少 TokenEnv")
+		}
+	case config.OriginAuthBasic:
+		if auth.UsernameEnv == "" || auth.PasswordEnv == "" {
+			return fmt.Errorf("basic 缺少 UsernameEnv / PasswordEnv")
+		}
+	default:
+		return fmt.Errorf("不支持的类型: %s", auth.Type)
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// redactConfig 返回用于对外输出的配置副本: 内联的回源鉴权密钥替换为占位符, 不修改原配置
+func redactConfig(cfg *config.Config) *config.Config {
+	out := *cfg
+	out.MAP = make(map[string]config.PathConfig, len(cfg.MAP))
+	for path, pc := range cfg.MAP {
+		if len(pc.OriginAuth) > 0 {
+			auths := make(map[string]config.OriginAuthConfig, len(pc.OriginAuth))
+			for target, auth := range pc.OriginAuth {
+				auth.SecretAccessKey = redactSecret(auth.SecretAccessKey)
+				auth.SessionToken = redactSecret(auth.SessionToken)
+				auths[target] = auth
+			}
+			pc.OriginAuth = auths
+		}
+		out.MAP[path] = pc
+	}
+	return &out
+}
+
+func redactSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return config.RedactedSecret
+}
+
+// restoreRedactedSecrets 把新配置中仍为占位符的密钥还原为旧配置里同路径同目标的值
+func restoreRedactedSecrets(newCfg, oldCfg *config.Config) {
+	if newCfg == nil {
+		return
+	}
+	for path, pc := range newCfg.MAP {
+		if len(pc.OriginAuth) == 0 {
+			continue
+		}
+		var oldAuths map[string]config.OriginAuthConfig
+		if oldCfg != nil {
+			oldAuths = oldCfg.MAP[path].OriginAuth
+		}
+		for target, auth := range pc.OriginAuth {
+			old := oldAuths[target]
+			if auth.SecretAccessKey == config.RedactedSecret {
+				auth.SecretAccessKey = old.SecretAccessKey
+			}
+			if auth.SessionToken == config.RedactedSecret {
+				auth.SessionToken = old.SessionToken
+			}
+			pc.OriginAuth[target] = auth
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	"proxy-go/internal/cache"
+)
+
+// 缓存只保存未压缩内容: 可缓存的请求回源时不转发客户端的 Accept-Encoding, 由 Transport 协商 gzip 并自动解压;
+// 命中时按客户端的 Accept-Encoding 选择未压缩副本或 br / gzip 变体 (见 cache.OpenContent)
+
+// gzipMinSize 回源时即时压缩的最小响应大小, 更小的响应压缩收益不足以抵消开销
+const gzipMinSize = 1 << 10
+
+// cacheableEncoding 判断响应的编码能否写入缓存: 未压缩或 gzip (命中时可即时解压), 其它编码不缓存
+func cacheableEncoding(resp *http.Response) bool {
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity", "gzip":
+		return true
+	}
+	return false
+}
+
+// compressOnMiss 判断写入缓存的未压缩响应是否对客户端即时 gzip 压缩输出
+func compressOnMiss(r *http.Request, resp *http.Response) bool {
+	return resp.Header.Get("Content-Encoding") == "" &&
+		(resp.ContentLength < 0 || resp.ContentLength >= gzipMinSize) &&
+		cache.Compressible(resp.Header.Get("Content-Type")) &&
+		cache.PrefersEncoding(r.Header.Get("Accept-Encoding"), "gzip")
+}
+
+// setCompressedHeaders 设置即时压缩输出的响应头; 源站的 ETag / Content-Length 对应未压缩内容, 不再适用
+func setCompressedHeaders(h http.Header) {
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	h.Del("ETag")
+	h.Del("Accept-Ranges")
+}
+
+// gzipTo 把 write 的输出经 gzip 压缩后写入 w, 返回压缩前的字节数
+func gzipTo(w io.Writer, write func(io.Writer) (int64, error)) (int64, error) {
+	zw := gzip.NewWriter(w)
+	written, err := write(zw)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	return written, err
+}
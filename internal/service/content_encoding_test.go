@@ -0,0 +1,123 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"proxy-go/internal/cache"
+)
+
+func TestProcessResponseStoresIdentityAndCompressesOnMiss(t *testing.T) {
+	cm, err := cache.NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+	s := &ProxyService{cache: cm}
+
+	newReq := func(acceptEncoding string) *ProxyRequest {
+		r := httptest.NewRequest(http.MethodGet, "/app/main.js", nil)
+		if acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		return &ProxyRequest{OriginalRequest: r, MatchedPrefix: "/app", TargetPath: "/main.js", StartTime: time.Now()}
+	}
+
+	// 可缓存的请求不转发客户端的 Accept-Encoding
+	req := newReq("gzip, br")
+	proxyReq, err := s.CreateProxyRequest(req, "https://origin.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ae := proxyReq.Header.Get("Accept-Encoding"); ae != "" {
+		t.Fatalf("upstream Accept-Encoding = %q, want none", ae)
+	}
+
+	body := strings.Repeat("console.log('hello');\n", 200)
+	resp := &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": {"application/javascript"}, "Etag": {`"origin"`}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req.OriginalRequest,
+	}
+	w := httptest.NewRecorder()
+	if _, err := s.ProcessResponse(req, resp, w, false); err != nil {
+		t.Fatal(err)
+	}
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" || w.Header().Get("ETag") != "" {
+		t.Fatalf("miss headers = %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != body {
+		t.Fatal("compressed miss body does not decode to the origin body")
+	}
+
+	// 缓存中保存的是未压缩内容
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if item, hit, _ := s.CheckCache(newReq("")); hit {
+			if item.ContentEncoding != "" || item.Size != int64(len(body)) {
+				t.Fatalf("cached encoding = %q size = %d", item.ContentEncoding, item.Size)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("response was not cached")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestProcessResponseSkipsUndecodableEncoding(t *testing.T) {
+	cm, err := cache.NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+	s := &ProxyService{cache: cm}
+
+	r := httptest.NewRequest(http.MethodGet, "/app/data.json", nil)
+	req := &ProxyRequest{OriginalRequest: r, MatchedPrefix: "/app", TargetPath: "/data.json", StartTime: time.Now()}
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"br"}},
+		Body:       io.NopCloser(bytes.NewReader([]byte("br-bytes"))),
+		Request:    r,
+	}
+	w := httptest.NewRecorder()
+	if _, err := s.ProcessResponse(req, resp, w, false); err != nil {
+		t.Fatal(err)
+	}
+	if w.Header().Get("Content-Encoding") != "br" || w.Body.String() != "br-bytes" {
+		t.Fatalf("br response should pass through unchanged: %v %q", w.Header(), w.Body.String())
+	}
+	if s.shouldCache(req, resp) {
+		t.Fatal("br response should not be cached")
+	}
+}
+
+func TestCompressOnMissHonorsIdentityPreference(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Content-Type": {"text/css"}}, ContentLength: -1}
+	for ae, want := range map[string]bool{
+		"gzip":                 true,
+		"gzip;q=0.1, identity": false,
+		"gzip, identity;q=0.5": true,
+		"":                     false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/app/site.css", nil)
+		r.Header.Set("Accept-Encoding", ae)
+		if got := compressOnMiss(r, resp); got != want {
+			t.Errorf("compressOnMiss(%q) = %v, want %v", ae, got, want)
+		}
+	}
+}
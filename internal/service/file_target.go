@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"proxy-go/internal/config"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileTargetKey 回源请求 context 中携带的本地目录目标信息
+type fileTargetKey struct{}
+
+// fileTarget 本地目录目标: root 为 file:// 目标对应的根目录, 所有访问必须落在其内
+type fileTarget struct {
+	root string
+	cfg  *config.FileServerConfig
+}
+
+// withFileTarget 为指向 file:// 目标的回源请求附带根目录与目录浏览配置
+func withFileTarget(ctx context.Context, targetURL string, cfg *config.FileServerConfig) context.Context {
+	return context.WithValue(ctx, fileTargetKey{}, &fileTarget{root: config.FileTargetRoot(targetURL), cfg: cfg})
+}
+
+// FileTransport 处理 file:// 目标的 http.RoundTripper, 注册在回源 Transport 上 (RegisterProtocol)
+//
+// 响应由 http.ServeContent 生成, Range / If-Range / If-Modified-Since / If-None-Match 与普通文件服务一致,
+// 并以 文件大小 + 修改时间 生成 ETag; 产生的 *http.Response 与 HTTP 源站一样进入缓存 / 回落 / 统计流程。
+type FileTransport struct{}
+
+// NewFileTransport 创建本地目录 Transport
+func NewFileTransport() *FileTransport {
+	return &FileTransport{}
+}
+
+// RoundTrip 实现 http.RoundTripper
+func (t *FileTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return fileErrorResponse(req, http.StatusMethodNotAllowed), nil
+	}
+
+	target, _ := req.Context().Value(fileTargetKey{}).(*fileTarget)
+	name, status := resolveFileTargetPath(req.URL.Path, target)
+	if status != 0 {
+		return fileErrorResponse(req, status), nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return fileErrorResponse(req, fileErrorStatus(err)), nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fileErrorResponse(req, fileErrorStatus(err)), nil
+	}
+
+	if info.IsDir() {
+		f.Close()
+		return t.serveDirectory(req, name, target)
+	}
+	return serveFileContent(req, f, info), nil
+}
+
+// serveDirectory 目录: 先找 Index 文件, 其次按 Listing 输出列表, 都未配置时 404
+func (t *FileTransport) serveDirectory(req *http.Request, dir string, target *fileTarget) (*http.Response, error) {
+	cfg := target.cfg
+	if cfg == nil {
+		return fileErrorResponse(req, http.StatusNotFound), nil
+	}
+
+	for _, index := range cfg.Index {
+		// Index 文件同样可能是指向根目录之外的符号链接
+		name, status := target.contain(filepath.Join(dir, filepath.Base(index)))
+		if status != 0 {
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			f.Close()
+			continue
+		}
+		return serveFileContent(req, f, info), nil
+	}
+
+	switch strings.ToLower(cfg.Listing) {
+	case config.FileListingHTML, config.FileListingJSON:
+		entries, err := readDirListing(dir)
+		if err != nil {
+			return fileErrorResponse(req, fileErrorStatus(err)), nil
+		}
+		return serveDirListing(req, entries, strings.ToLower(cfg.Listing)), nil
+	default:
+		return fileErrorResponse(req, http.StatusNotFound), nil
+	}
+}
+
+// resolveFileTargetPath 把回源 URL 路径映射为磁盘路径并做越界检查; 返回非 0 状态码表示拒绝
+// 规则: 禁止 ".." 段与隐藏文件 (以 "." 开头的段, 如 .git); 解析符号链接后仍须落在根目录内;
+// 请求没有附带根目录 (不是经 CreateProxyRequest 发往 file:// 目标) 时一律拒绝, 不按原始路径读取
+func resolveFileTargetPath(urlPath string, target *fileTarget) (string, int) {
+	if target == nil || target.root == "" {
+		return "", http.StatusForbidden
+	}
+	if !strings.HasPrefix(urlPath, target.root) {
+		return "", http.StatusForbidden
+	}
+	for _, seg := range strings.Split(urlPath[len(target.root):], "/") {
+		if seg == ".." || strings.HasPrefix(seg, ".") && seg != "." {
+			return "", http.StatusNotFound
+		}
+	}
+	return target.contain(filepath.FromSlash(path.Clean("/" + urlPath)))
+}
+
+// contain 解析 name 的符号链接, 确认结果仍在根目录内; 返回解析后的路径, 越界时返回 403
+func (t *fileTarget) contain(name string) (string, int) {
+	resolvedRoot, err := filepath.EvalSymlinks(filepath.FromSlash(t.root))
+	if err != nil {
+		return "", http.StatusNotFound
+	}
+	resolved, err := filepath.EvalSymlinks(name)
+	if err != nil {
+		return "", http.StatusNotFound
+	}
+	prefix := resolvedRoot
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+	if resolved != resolvedRoot && !strings.HasPrefix(resolved, prefix) {
+		return "", http.StatusForbidden
+	}
+	return resolved, 0
+}
+
+// fileETag 与 nginx 一致: 修改时间 + 大小 (十六进制)
+func fileETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().Unix(), info.Size())
+}
+
+// serveFileContent 用 ServeContent 生成响应 (条件请求 / Range 由其处理), f 在响应体读完或关闭后释放
+func serveFileContent(req *http.Request, f *os.File, info os.FileInfo) *http.Response {
+	return pipeResponse(req, func(w http.ResponseWriter) {
+		defer f.Close()
+		w.Header().Set("ETag", fileETag(info))
+		http.ServeContent(w, req, info.Name(), info.ModTime(), f)
+	})
+}
+
+// fileListingEntry 目录列表条目 (JSON 输出格式)
+type fileListingEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	IsDir   bool      `json:"is_dir"`
+}
+
+// readDirListing 读取目录, 隐藏文件不出现在列表中; 目录在前, 同类按名称排序
+func readDirListing(dir string) ([]fileListingEntry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fileListingEntry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		if strings.HasPrefix(de.Name(), ".") {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, fileListingEntry{
+			Name:    de.Name(),
+			Size:    info.Size(),
+			ModTime: info.ModTime().UTC(),
+			IsDir:   de.IsDir(),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+var dirListingTemplate = template.Must(template.New("listing").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Index of {{.Title}}</title></head>
+<body><h1>Index of {{.Title}}</h1><table>
+<tr><th>Name</th><th>Size</th><th>Modified</th></tr>
+{{range .Entries}}<tr><td><a href="{{$.Base}}{{.Name}}{{if .IsDir}}/{{end}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td><td>{{if not .IsDir}}{{.Size}}{{end}}</td><td>{{.ModTime.Format "2006-01-02 15:04:05"}}</td></tr>
+{{end}}</table></body></html>
+`))
+
+// serveDirListing 输出目录列表; 链接使用相对地址, 请求路径不以 "/" 结尾时带上目录名, 无需额外重定向
+func serveDirListing(req *http.Request, entries []fileListingEntry, format string) *http.Response {
+	return pipeResponse(req, func(w http.ResponseWriter) {
+		w.Header().Set("Cache-Control", "no-cache")
+		if format == config.FileListingJSON {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			if req.Method != http.MethodHead {
+				json.NewEncoder(w).Encode(map[string]any{"entries": entries})
+			}
+			return
+		}
+
+		base := ""
+		if !strings.HasSuffix(req.URL.Path, "/") {
+			base = path.Base(req.URL.Path) + "/"
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		if req.Method != http.MethodHead {
+			dirListingTemplate.Execute(w, map[string]any{
+				"Title":   path.Base(req.URL.Path),
+				"Base":    base,
+				"Entries": entries,
+			})
+		}
+	})
+}
+
+func fileErrorStatus(err error) int {
+	switch {
+	case os.IsNotExist(err):
+		return http.StatusNotFound
+	case os.IsPermission(err):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func fileErrorResponse(req *http.Request, status int) *http.Response {
+	body := http.StatusText(status) + "\n"
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// pipeResponse 在独立 goroutine 中执行 serve, 把其写出的状态码 / 头部 / 响应体转成 *http.Response;
+// 响应体经 io.Pipe 流式传递, 大文件不落内存, 读取方关闭 Body 后写入方随即退出
+func pipeResponse(req *http.Request, serve func(http.ResponseWriter)) *http.Response {
+	pr, pw := io.Pipe()
+	w := &pipeResponseWriter{header: make(http.Header), pw: pw, ready: make(chan struct{})}
+	go func() {
+		defer pw.Close()
+		serve(w)
+		w.WriteHeader(http.StatusOK)
+	}()
+	<-w.ready
+
+	contentLength := int64(-1)
+	if cl, err := strconv.ParseInt(w.sent.Get("Content-Length"), 10, 64); err == nil {
+		contentLength = cl
+	}
+	var body io.ReadCloser = pr
+	if req.Method == http.MethodHead || w.status == http.StatusNotModified {
+		pr.Close()
+		body = http.NoBody
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.status, http.StatusText(w.status)),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.sent,
+		Body:          body,
+		ContentLength: contentLength,
+		Request:       req,
+	}
+}
+
+// pipeResponseWriter 把 http.ResponseWriter 的写入转到 io.Pipe
+type pipeResponseWriter struct {
+	header http.Header
+	sent   http.Header
+	status int
+	pw     *io.PipeWriter
+	ready  chan struct{}
+}
+
+func (w *pipeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *pipeResponseWriter) WriteHeader(status int) {
+	if w.sent != nil {
+		return
+	}
+	w.status = status
+	w.sent = w.header.Clone()
+	close(w.ready)
+}
+
+func (w *pipeResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.pw.Write(b)
+}
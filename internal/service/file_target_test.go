@@ -0,0 +1,135 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"proxy-go/internal/config"
+	"testing"
+)
+
+func newFileTargetFixture(t *testing.T) (string, *ProxyService) {
+	t.Helper()
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "releases"), 0755)
+	os.WriteFile(filepath.Join(root, "releases", "app.txt"), []byte("0123456789"), 0644)
+	os.WriteFile(filepath.Join(root, ".secret"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(filepath.Dir(root), "outside.txt"), []byte("x"), 0644)
+
+	transport := &http.Transport{}
+	transport.RegisterProtocol("file", NewFileTransport())
+	return root, &ProxyService{client: &http.Client{Transport: transport}}
+}
+
+func doFileTarget(t *testing.T, svc *ProxyService, root, reqPath string, fs *config.FileServerConfig, header map[string]string) *http.Response {
+	t.Helper()
+	orig := httptest.NewRequest("GET", "/dl"+reqPath, nil)
+	for k, v := range header {
+		orig.Header.Set(k, v)
+	}
+	target := "file://" + root
+	req := &ProxyRequest{
+		OriginalRequest: orig,
+		PathConfig:      config.PathConfig{DefaultTarget: target, FileServer: fs},
+		TargetPath:      reqPath,
+	}
+	httpReq, err := svc.CreateProxyRequest(req, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := svc.client.Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestFileTargetServesRangeAndConditional(t *testing.T) {
+	root, svc := newFileTargetFixture(t)
+
+	resp := doFileTarget(t, svc, root, "/releases/app.txt", nil, nil)
+	body, _ := io.ReadAll(resp.Body)
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode != 200 || string(body) != "0123456789" || etag == "" || lastModified == "" {
+		t.Fatalf("full GET: %d %q etag=%q", resp.StatusCode, body, etag)
+	}
+
+	resp = doFileTarget(t, svc, root, "/releases/app.txt", nil, map[string]string{"Range": "bytes=2-4"})
+	body, _ = io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "234" {
+		t.Fatalf("range GET: %d %q", resp.StatusCode, body)
+	}
+
+	resp = doFileTarget(t, svc, root, "/releases/app.txt", nil, map[string]string{"If-None-Match": etag})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("If-None-Match: %d", resp.StatusCode)
+	}
+
+	resp = doFileTarget(t, svc, root, "/releases/app.txt", nil, map[string]string{"If-Modified-Since": lastModified})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("If-Modified-Since: %d", resp.StatusCode)
+	}
+}
+
+func TestFileTargetRejectsTraversalAndHiddenFiles(t *testing.T) {
+	root, svc := newFileTargetFixture(t)
+
+	for _, p := range []string{"/../outside.txt", "/releases/../../outside.txt", "/.secret", "/releases"} {
+		if resp := doFileTarget(t, svc, root, p, nil, nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", p, resp.StatusCode)
+		}
+	}
+}
+
+func TestFileTargetDirectoryListing(t *testing.T) {
+	root, svc := newFileTargetFixture(t)
+
+	resp := doFileTarget(t, svc, root, "/", &config.FileServerConfig{Listing: "json"}, nil)
+	var listing struct {
+		Entries []fileListingEntry `json:"entries"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		t.Fatal(err)
+	}
+	if len(listing.Entries) != 1 || listing.Entries[0].Name != "releases" || !listing.Entries[0].IsDir {
+		t.Fatalf("listing = %+v", listing.Entries)
+	}
+
+	os.WriteFile(filepath.Join(root, "releases", "index.html"), []byte("<h1>releases</h1>"), 0644)
+	resp = doFileTarget(t, svc, root, "/releases/", &config.FileServerConfig{Index: []string{"index.html"}}, nil)
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || string(body) != "<h1>releases</h1>" {
+		t.Fatalf("index: %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestFileTargetRejectsEscapingSymlinks(t *testing.T) {
+	root, svc := newFileTargetFixture(t)
+	outside := filepath.Join(filepath.Dir(root), "outside.txt")
+	if err := os.Symlink(outside, filepath.Join(root, "releases", "link.txt")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	os.Symlink(outside, filepath.Join(root, "releases", "index.html"))
+
+	if resp := doFileTarget(t, svc, root, "/releases/link.txt", nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("symlink out of root: status %d, want 403", resp.StatusCode)
+	}
+	if resp := doFileTarget(t, svc, root, "/releases/", &config.FileServerConfig{Index: []string{"index.html"}}, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("index symlink out of root: status %d, want 404", resp.StatusCode)
+	}
+
+	// 没有附带根目录的请求不按原始路径读取
+	req := httptest.NewRequest("GET", "file://"+outside, nil)
+	resp, err := NewFileTransport().RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("request without root: status %d, want 403", resp.StatusCode)
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"proxy-go/internal/config"
+	"proxy-go/internal/security"
+	"strings"
+)
+
+// viaPseudonym Via 头中代表本代理的接收方名称 (RFC 7230 §5.7.1)
+const viaPseudonym = "proxy-go"
+
+// applyForwardHeaders 按路径级策略写入回源转发头
+//
+// policy 为 nil 时保持历史行为: X-Real-IP + 追加 X-Forwarded-For; X-Request-ID 由入口写入原始请求, 随 copyHeaders 透传。
+// Strip 最后执行, 保证列出的头部无论来自客户端还是本函数生成都不会发给源站。
+func applyForwardHeaders(dst http.Header, orig *http.Request, policy *config.ForwardHeadersConfig) {
+	clientIP := security.ClientIP(orig)
+	dst.Set("X-Real-IP", clientIP)
+
+	mode := config.ForwardForAppend
+	if policy != nil && policy.XForwardedFor != "" {
+		mode = policy.XForwardedFor
+	}
+
+	switch mode {
+	case config.ForwardForReplace:
+		if clientIP != "" {
+			dst.Set("X-Forwarded-For", clientIP)
+		}
+	case config.ForwardForOff:
+	default:
+		if clientIP != "" {
+			if prior := strings.Join(dst.Values("X-Forwarded-For"), ", "); prior != "" {
+				dst.Set("X-Forwarded-For", prior+", "+clientIP)
+			} else {
+				dst.Set("X-Forwarded-For", clientIP)
+			}
+		}
+	}
+
+	if policy == nil {
+		return
+	}
+
+	scheme := security.RequestScheme(orig)
+	if policy.XForwardedProto {
+		dst.Set("X-Forwarded-Proto", scheme)
+	}
+	if policy.XForwardedHost && orig.Host != "" {
+		dst.Set("X-Forwarded-Host", orig.Host)
+	}
+	if policy.Forwarded {
+		element := buildForwardedElement(clientIP, orig.Host, scheme)
+		if prior := strings.Join(dst.Values("Forwarded"), ", "); prior != "" && mode != config.ForwardForReplace {
+			element = prior + ", " + element
+		}
+		dst.Set("Forwarded", element)
+	}
+	if policy.Via {
+		via := fmt.Sprintf("%d.%d %s", orig.ProtoMajor, orig.ProtoMinor, viaPseudonym)
+		if prior := strings.Join(dst.Values("Via"), ", "); prior != "" {
+			via = prior + ", " + via
+		}
+		dst.Set("Via", via)
+	}
+
+	for _, name := range policy.Strip {
+		if name = strings.TrimSpace(name); name != "" {
+			dst.Del(textproto.CanonicalMIMEHeaderKey(name))
+		}
+	}
+}
+
+// buildForwardedElement 生成单个 RFC 7239 forwarded-element: for=...;host=...;proto=...
+// IPv6 地址按规范加方括号并整体加引号; host 含端口等特殊字符时同样加引号
+func buildForwardedElement(clientIP, host, scheme string) string {
+	parts := make([]string, 0, 3)
+	if clientIP != "" {
+		node := clientIP
+		if ip := net.ParseIP(clientIP); ip != nil && ip.To4() == nil {
+			node = `"[` + clientIP + `]"`
+		}
+		parts = append(parts, "for="+node)
+	}
+	if host != "" {
+		if strings.ContainsAny(host, ":[]") {
+			host = `"` + host + `"`
+		}
+		parts = append(parts, "host="+host)
+	}
+	parts = append(parts, "proto="+scheme)
+	return strings.Join(parts, ";")
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"proxy-go/internal/config"
+)
+
+func newForwardTestRequest(remoteAddr string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "http://files.example.com/a.jpg", nil)
+	r.RemoteAddr = remoteAddr
+	return r
+}
+
+func TestApplyForwardHeadersDefaultAppendsForwardedFor(t *testing.T) {
+	orig := newForwardTestRequest("203.0.113.5:4000")
+	dst := http.Header{}
+	dst.Set("X-Forwarded-For", "198.51.100.1")
+
+	applyForwardHeaders(dst, orig, nil)
+
+	if got := dst.Get("X-Forwarded-For"); got != "198.51.100.1, 203.0.113.5" {
+		t.Fatalf("X-Forwarded-For = %q", got)
+	}
+	if got := dst.Get("X-Real-IP"); got != "203.0.113.5" {
+		t.Fatalf("X-Real-IP = %q", got)
+	}
+	if dst.Get("Forwarded") != "" || dst.Get("Via") != "" {
+		t.Fatalf("nil policy must not add Forwarded/Via: %v", dst)
+	}
+}
+
+func TestApplyForwardHeadersPolicy(t *testing.T) {
+	orig := newForwardTestRequest("[2001:db8::7]:4000")
+	dst := http.Header{}
+	dst.Set("X-Forwarded-For", "198.51.100.1")
+	dst.Set("Forwarded", "for=198.51.100.1")
+	dst.Set("X-Request-ID", "abc")
+
+	applyForwardHeaders(dst, orig, &config.ForwardHeadersConfig{
+		XForwardedFor:   config.ForwardForReplace,
+		XForwardedProto: true,
+		XForwardedHost:  true,
+		Forwarded:       true,
+		Via:             true,
+		Strip:           []string{"x-request-id"},
+	})
+
+	want := map[string]string{
+		"X-Forwarded-For":   "2001:db8::7",
+		"X-Forwarded-Proto": "http",
+		"X-Forwarded-Host":  "files.example.com",
+		"Forwarded":         `for="[2001:db8::7]";host=files.example.com;proto=http`,
+		"Via":               "1.1 proxy-go",
+		"X-Request-Id":      "",
+	}
+	for name, value := range want {
+		if got := dst.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
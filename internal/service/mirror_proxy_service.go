@@ -6,11 +6,10 @@ import (
 	"net/http"
 	"net/url"
 	"proxy-go/internal/cache"
+	"proxy-go/internal/security"
 	"proxy-go/internal/utils"
 	"strings"
 	"time"
-
-	"github.com/woodchen-ink/go-web-utils/iputil"
 )
 
 // MirrorProxyRequest 镜像代理请求
@@ -109,6 +108,14 @@ func (s *MirrorProxyService) CheckCache(req *MirrorProxyRequest) (*cache.CacheIt
 	return item, hit, notModified
 }
 
+// InvalidateCache 使请求对应的缓存项失效 (命中后发现缓存文件已丢失时调用)
+func (s *MirrorProxyService) InvalidateCache(req *MirrorProxyRequest) {
+	if s.cache == nil {
+		return
+	}
+	s.cache.InvalidateCacheItem(s.getOrBuildCacheKey(req))
+}
+
 // getOrBuildCacheKey 从 MirrorProxyRequest 取缓存键，首次调用时生成并复用
 func (s *MirrorProxyService) getOrBuildCacheKey(req *MirrorProxyRequest) cache.CacheKey {
 	if !req.cacheKeySet {
@@ -127,6 +134,10 @@ func (s *MirrorProxyService) CreateProxyRequest(req *MirrorProxyRequest) (*http.
 
 	// 复制原始请求的header
 	s.copyHeaders(proxyReq.Header, req.OriginalRequest.Header)
+	if s.shouldCacheRequest(req) {
+		// 缓存只保存未压缩内容, 由 Transport 协商 gzip 并解压 (见 content_encoding.go)
+		proxyReq.Header.Del("Accept-Encoding")
+	}
 
 	// 设置必要的请求头
 	scheme := req.ParsedURL.Scheme
@@ -161,13 +172,27 @@ func (s *MirrorProxyService) ProcessResponse(req *MirrorProxyRequest, resp *http
 	// 复制响应头
 	s.copyHeaders(w.Header(), resp.Header)
 	w.Header().Set("CZL-Proxy-Cache-HIT", "0")
+	w.Header().Set(cache.CacheStatusHeader, cache.CacheStatusMiss)
+
+	// 如果是GET请求且响应成功，使用TeeReader同时写入缓存; 接受 gzip 的客户端即时压缩输出
+	cacheable := s.shouldCacheRequest(req) && resp.StatusCode == http.StatusOK && cacheableEncoding(resp)
+	compress := cacheable && compressOnMiss(req.OriginalRequest, resp)
+	if cacheable && cache.Compressible(resp.Header.Get("Content-Type")) {
+		cache.AddVary(w.Header(), "Accept-Encoding")
+	}
+	if compress {
+		setCompressedHeaders(w.Header())
+	}
 	w.WriteHeader(resp.StatusCode)
 
 	var written int64
 	var err error
 
-	// 如果是GET请求且响应成功，使用TeeReader同时写入缓存
-	if req.OriginalRequest.Method == http.MethodGet && resp.StatusCode == http.StatusOK && s.cache != nil {
+	if compress {
+		written, err = gzipTo(w, func(dst io.Writer) (int64, error) {
+			return s.processWithCache(req, resp, dst)
+		})
+	} else if cacheable {
 		written, err = s.processWithCache(req, resp, w)
 	} else {
 		// 🚀 零拷贝优化: 使用 buffer pool 复用缓冲区
@@ -183,44 +208,37 @@ func (s *MirrorProxyService) ProcessResponse(req *MirrorProxyRequest, resp *http
 	return written, nil
 }
 
+// shouldCacheRequest 判断请求的响应是否可能写入缓存 (GET)
+func (s *MirrorProxyService) shouldCacheRequest(req *MirrorProxyRequest) bool {
+	return req.OriginalRequest.Method == http.MethodGet && s.cache != nil
+}
+
 // processWithCache 处理带缓存的响应
-func (s *MirrorProxyService) processWithCache(req *MirrorProxyRequest, resp *http.Response, w http.ResponseWriter) (int64, error) {
+func (s *MirrorProxyService) processWithCache(req *MirrorProxyRequest, resp *http.Response, w io.Writer) (int64, error) {
 	cacheKey := s.getOrBuildCacheKey(req)
 
-	if cacheFile, err := s.cache.CreateTemp(cacheKey, resp); err == nil {
+	if cacheWriter, err := s.cache.NewCacheWriter(cacheKey, resp); err == nil {
 		// 🚀 零拷贝优化: 使用 buffer pool 复用缓冲区
 		buf := cache.GetBuffer(32 * 1024)
 		defer cache.PutBuffer(buf)
 
-		teeReader := io.TeeReader(resp.Body, cacheFile)
-		written, err := io.CopyBuffer(w, teeReader, buf)
-
-		// 🔧 修复: 确保文件完全写入并同步到磁盘后再关闭和提交
-		// 1. 先同步文件内容到磁盘
-		if syncErr := cacheFile.Sync(); syncErr != nil && err == nil {
-			// 如果同步失败，记录错误但继续（不影响客户端响应）
-			// 这里不设置 err，因为客户端已经收到了数据
+		// 边转发边写入临时文件并计算哈希, 超过最大缓存对象大小时写入器自行放弃缓存
+		written, err := io.CopyBuffer(w, io.TeeReader(resp.Body, cacheWriter), buf)
+		if err != nil {
+			cacheWriter.Abort()
+			return written, err
 		}
 
-		// 2. 关闭文件，确保所有缓冲区都被刷新
-		closeErr := cacheFile.Close()
-
-		// 3. 只有在写入成功且文件正确关闭的情况下才提交缓存
-		if err == nil && closeErr == nil {
+		// 只有在完整写入且文件正确同步关闭的情况下才提交缓存
+		if tempPath, size, digest, ok := cacheWriter.Finish(); ok {
 			// 异步提交缓存，不阻塞当前请求处理
-			fileName := cacheFile.Name()
 			respClone := *resp // 创建响应的浅拷贝
 			go func() {
-				s.cache.Commit(cacheKey, fileName, &respClone, written)
+				s.cache.Commit(cacheKey, tempPath, &respClone, size, digest)
 			}()
-		} else {
-			// 如果关闭失败，尝试再次关闭
-			if closeErr != nil {
-				cacheFile.Close()
-			}
 		}
 
-		return written, err
+		return written, nil
 	}
 
 	// 🚀 零拷贝优化: 使用 buffer pool 复用缓冲区
@@ -249,6 +267,6 @@ func (s *MirrorProxyService) isConnectionClosed(err error) bool {
 func (s *MirrorProxyService) CreateLogEntry(req *MirrorProxyRequest, statusCode int, duration time.Duration, bytesWritten int64) string {
 	return fmt.Sprintf("| %-6s | %3d | %12s | %15s | %10s | %-30s | %s",
 		req.OriginalRequest.Method, statusCode, duration,
-		iputil.GetClientIP(req.OriginalRequest), utils.FormatBytes(bytesWritten),
+		security.ClientIP(req.OriginalRequest), utils.FormatBytes(bytesWritten),
 		utils.GetRequestSource(req.OriginalRequest), req.ActualURL)
 }
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"proxy-go/internal/config"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm       = "AWS4-HMAC-SHA256"
+	sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+	sigV4TimeFormat      = "20060102T150405Z"
+	// defaultPresignExpires 回源 query 签名有效期; 只需覆盖本次请求及同源重试
+	defaultPresignExpires = 300
+)
+
+// sigV4Credentials 解析后的 SigV4 密钥
+type sigV4Credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// applyOriginAuth 为已构建好的回源请求写入鉴权信息
+// 必须在路径改写 (buildTargetURL) 之后调用; 同源重试克隆请求时沿用同一签名, 回落到其他源时按新目标重新签名。
+func applyOriginAuth(req *http.Request, auth *config.OriginAuthConfig, now time.Time) error {
+	switch strings.ToLower(auth.Type) {
+	case config.OriginAuthSigV4:
+		creds, err := resolveSigV4Credentials(auth)
+		if err != nil {
+			return err
+		}
+		service := auth.Service
+		if service == "" {
+			service = "s3"
+		}
+		// 客户端带来的 Authorization / x-amz-* 头会破坏签名或被源站当成未签名头拒绝
+		req.Header.Del("Authorization")
+		for name := range req.Header {
+			if strings.HasPrefix(strings.ToLower(name), "x-amz-") {
+				req.Header.Del(name)
+			}
+		}
+		if auth.Presign {
+			expires := auth.PresignExpires
+			if expires <= 0 {
+				expires = defaultPresignExpires
+			}
+			presignSigV4(req, creds, auth.Region, service, now, expires)
+		} else {
+			signSigV4(req, creds, auth.Region, service, now)
+		}
+		return nil
+	case config.OriginAuthBearer:
+		token := os.Getenv(auth.TokenEnv)
+		if token == "" {
+			return fmt.Errorf("环境变量 %s 未设置", auth.TokenEnv)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	case config.OriginAuthBasic:
+		username, password := os.Getenv(auth.UsernameEnv), os.Getenv(auth.PasswordEnv)
+		if username == "" {
+			return fmt.Errorf("环境变量 %s 未设置", auth.UsernameEnv)
+		}
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+		return nil
+	default:
+		return fmt.Errorf("不支持的回源鉴权类型: %s", auth.Type)
+	}
+}
+
+// resolveSigV4Credentials 环境变量优先, 其次内联值
+func resolveSigV4Credentials(auth *config.OriginAuthConfig) (sigV4Credentials, error) {
+	pick := func(env, inline string) string {
+		if env != "" {
+			if v := os.Getenv(env); v != "" {
+				return v
+			}
+		}
+		return inline
+	}
+	creds := sigV4Credentials{
+		accessKeyID:     pick(auth.AccessKeyIDEnv, auth.AccessKeyID),
+		secretAccessKey: pick(auth.SecretAccessKeyEnv, auth.SecretAccessKey),
+		sessionToken:    pick(auth.SessionTokenEnv, auth.SessionToken),
+	}
+	if creds.accessKeyID == "" || creds.secretAccessKey == "" {
+		return creds, fmt.Errorf("SigV4 缺少 AccessKeyID / SecretAccessKey")
+	}
+	return creds, nil
+}
+
+// signSigV4 头部签名: 只签 host / x-amz-content-sha256 / x-amz-date (/ x-amz-security-token),
+// 其余头部可能被 Transport 或中间层改写, 不纳入签名。请求体为流式转发, 一律 UNSIGNED-PAYLOAD。
+func signSigV4(req *http.Request, creds sigV4Credentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format(sigV4TimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", sigV4UnsignedPayload)
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+		headers = append(headers, "x-amz-security-token")
+	}
+
+	canonicalizeSigV4URL(req.URL)
+	signature, signedHeaders := sigV4Signature(req, headers, sigV4UnsignedPayload, creds.secretAccessKey, region, service, amzDate)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.accessKeyID, sigV4Scope(amzDate, region, service), signedHeaders, signature))
+}
+
+// presignSigV4 query 签名: 只签 host, 签名参数写入 URL
+func presignSigV4(req *http.Request, creds sigV4Credentials, region, service string, now time.Time, expires int) {
+	amzDate := now.UTC().Format(sigV4TimeFormat)
+	query := req.URL.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", creds.accessKeyID+"/"+sigV4Scope(amzDate, region, service))
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(expires))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if creds.sessionToken != "" {
+		query.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+	req.URL.RawQuery = query.Encode()
+
+	canonicalizeSigV4URL(req.URL)
+	signature, _ := sigV4Signature(req, []string{"host"}, sigV4UnsignedPayload, creds.secretAccessKey, region, service, amzDate)
+	req.URL.RawQuery += "&X-Amz-Signature=" + signature
+}
+
+// canonicalizeSigV4URL 把线上发送的 path / query 改写成与规范请求完全一致的编码,
+// 避免 url.PathEscape 保留的 "+", "=" 等字符在源站侧被另行编码导致签名不匹配
+func canonicalizeSigV4URL(u *url.URL) {
+	u.RawPath = sigV4Encode(u.Path, false)
+	u.RawQuery = sigV4CanonicalQuery(u.Query())
+}
+
+// sigV4Signature 计算签名; headers 为要签名的小写头名
+func sigV4Signature(req *http.Request, headers []string, payloadHash, secret, region, service, amzDate string) (string, string) {
+	sort.Strings(headers)
+	var canonicalHeaders strings.Builder
+	for _, name := range headers {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+			if value == "" {
+				value = req.URL.Host
+			}
+		}
+		canonicalHeaders.WriteString(name)
+		canonicalHeaders.WriteByte(':')
+		canonicalHeaders.WriteString(strings.Join(strings.Fields(value), " "))
+		canonicalHeaders.WriteByte('\n')
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalURI := sigV4Encode(req.URL.Path, false)
+	if service != "s3" {
+		// 非 S3 服务的规范 URI 需要二次编码, 线上仍发送一次编码的路径
+		canonicalURI = sigV4Encode(canonicalURI, false)
+	}
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := sigV4Scope(amzDate, region, service)
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secret), amzDate[:8])
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign)), signedHeaders
+}
+
+func sigV4Scope(amzDate, region, service string) string {
+	return amzDate[:8] + "/" + region + "/" + service + "/aws4_request"
+}
+
+// sigV4CanonicalQuery 按编码后的 key (再按 value) 排序, key 与 value 均按 SigV4 规则编码
+func sigV4CanonicalQuery(query url.Values) string {
+	type pair struct{ key, value string }
+	pairs := make([]pair, 0, len(query))
+	for key, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, pair{sigV4Encode(key, true), sigV4Encode(v, true)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].key != pairs[j].key {
+			return pairs[i].key < pairs[j].key
+		}
+		return pairs[i].value < pairs[j].value
+	})
+	parts := make([]string, len(pairs))
+	for i, p := range pairs {
+		parts[i] = p.key + "=" + p.value
+	}
+	return strings.Join(parts, "&")
+}
+
+// sigV4Encode SigV4 的 URI 编码: 仅保留 A-Z a-z 0-9 - _ . ~, 其余按 %XX (大写) 编码;
+// encodeSlash 为 false 时保留 "/" (用于路径)
+func sigV4Encode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hexDigits[c>>4])
+		b.WriteByte(hexDigits[c&15])
+	}
+	return b.String()
+}
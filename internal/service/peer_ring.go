@@ -0,0 +1,50 @@
+package service
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// peerRingReplicas 每个节点在环上的虚拟节点数, 节点较少时也能大致均分 URL
+const peerRingReplicas = 128
+
+// peerRing 一致性哈希环: 节点增减时只有相邻区间的 URL 改由其他节点负责
+type peerRing struct {
+	nodes  []string
+	hashes []uint32
+	owners map[uint32]string
+}
+
+// newPeerRing 按节点地址构建哈希环, nodes 需已去重并排序 (保证各节点构建出相同的环)
+func newPeerRing(nodes []string) *peerRing {
+	r := &peerRing{
+		nodes:  nodes,
+		owners: make(map[uint32]string, len(nodes)*peerRingReplicas),
+	}
+	for _, node := range nodes {
+		for i := 0; i < peerRingReplicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + node))
+			if _, exists := r.owners[h]; exists {
+				continue
+			}
+			r.owners[h] = node
+			r.hashes = append(r.hashes, h)
+		}
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+	return r
+}
+
+// owner 返回负责 key 的节点地址, 环为空时返回空串
+func (r *peerRing) owner(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
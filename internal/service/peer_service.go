@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"proxy-go/internal/config"
+	d1sync "proxy-go/pkg/sync"
+)
+
+const (
+	// PeerFetchPath 对等缓存内部接口, 其他节点缓存未命中时通过它向负责该 URL 的节点获取内容
+	PeerFetchPath = "/internal/peer/fetch"
+	// PeerHeader 响应头: 内容由对等节点提供时为 1
+	PeerHeader = "CZL-Proxy-Peer"
+
+	defaultPeerTimeout    = 2 * time.Second
+	peerDownBackoff       = 30 * time.Second // 节点请求失败后暂停转发的时长, 期间直接回源
+	peerDiscoveryInterval = time.Minute
+	peerNodeTTL           = 3 * time.Minute // 注册表中超过该时长没有心跳的节点视为离线
+)
+
+// peerSkipHeaders 转发给对等节点时不携带的请求头: 逐跳头, 以及会让对方返回部分内容或 304 的头
+// (请求方需要完整的 200 响应写入本地缓存); Accept-Encoding 交给 Transport 协商 gzip 并解压, 本地只缓存未压缩内容
+var peerSkipHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Connection", "Proxy-Authorization", "Te", "Trailer",
+	"Transfer-Encoding", "Upgrade", "Authorization", "Range", "If-Range",
+	"If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "Accept-Encoding",
+}
+
+type peerRequestKey struct{}
+
+// WithPeerRequest 标记请求来自对等节点; 这类请求缓存未命中时直接回源, 不再转给其他节点
+func WithPeerRequest(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), peerRequestKey{}, true))
+}
+
+// IsPeerRequest 判断请求是否来自对等节点
+func IsPeerRequest(r *http.Request) bool {
+	v, _ := r.Context().Value(peerRequestKey{}).(bool)
+	return v
+}
+
+// PeerService 多节点对等缓存: 静态配置与 D1 注册表发现的节点组成一致性哈希环,
+// 缓存未命中时先向负责该 URL 的节点获取, 该节点未命中时由它回源并缓存, 多个节点只回源一次
+type PeerService struct {
+	client *http.Client
+	self   string // 本节点地址 (PEER_SELF_URL)
+	token  string // 节点间鉴权令牌 (PEER_CACHE_TOKEN)
+
+	enabled    atomic.Bool
+	discovery  atomic.Bool
+	timeout    atomic.Int64
+	static     atomic.Pointer[[]string]
+	discovered atomic.Pointer[[]string]
+	ring       atomic.Pointer[peerRing]
+	down       sync.Map // 节点地址 -> 恢复转发的时间
+	startOnce  sync.Once
+}
+
+// NewPeerService 按配置创建对等缓存服务, 本节点地址与令牌读取环境变量 PEER_SELF_URL / PEER_CACHE_TOKEN
+func NewPeerService(cfg config.PeerCacheConfig) *PeerService {
+	return newPeerService(cfg, os.Getenv("PEER_SELF_URL"), strings.TrimSpace(os.Getenv("PEER_CACHE_TOKEN")))
+}
+
+func newPeerService(cfg config.PeerCacheConfig, self, token string) *PeerService {
+	s := &PeerService{
+		client: &http.Client{
+			// 对方的跳转直接交给调用方判断 (非 200 一律回源)
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		self:  normalizePeerURL(self),
+		token: token,
+	}
+	s.Update(cfg)
+	return s
+}
+
+// Update 应用新的对等缓存配置 (配置热更新)
+func (s *PeerService) Update(cfg config.PeerCacheConfig) {
+	enabled := cfg.Enabled && s.self != "" && s.token != ""
+	if cfg.Enabled && !enabled {
+		log.Printf("[Peer] Peer cache disabled: PEER_SELF_URL and PEER_CACHE_TOKEN are required")
+	}
+	timeout := defaultPeerTimeout
+	if cfg.TimeoutMs > 0 {
+		timeout = time.Duration(cfg.TimeoutMs) * time.Millisecond
+	}
+	static := append([]string(nil), cfg.Peers...)
+	s.timeout.Store(int64(timeout))
+	s.static.Store(&static)
+	s.discovery.Store(enabled && cfg.Discovery)
+	s.enabled.Store(enabled)
+	s.rebuild()
+
+	if s.discovery.Load() {
+		s.startOnce.Do(func() { go s.discoveryLoop() })
+	}
+}
+
+// Enabled 对等缓存是否启用
+func (s *PeerService) Enabled() bool {
+	return s.enabled.Load()
+}
+
+// Authorized 校验其他节点请求携带的令牌
+func (s *PeerService) Authorized(r *http.Request) bool {
+	if !s.Enabled() {
+		return false
+	}
+	got := r.Header.Get("Authorization")
+	return subtle.ConstantTimeCompare([]byte(got), []byte("Bearer "+s.token)) == 1
+}
+
+// Nodes 返回当前哈希环上的节点地址
+func (s *PeerService) Nodes() []string {
+	if ring := s.ring.Load(); ring != nil {
+		return append([]string(nil), ring.nodes...)
+	}
+	return nil
+}
+
+// owner 返回负责 key 的其他节点; 未启用、由本节点负责或该节点暂停转发时返回空串
+func (s *PeerService) owner(key string) string {
+	if !s.Enabled() {
+		return ""
+	}
+	ring := s.ring.Load()
+	if ring == nil {
+		return ""
+	}
+	node := ring.owner(key)
+	if node == "" || node == s.self {
+		return ""
+	}
+	if until, ok := s.down.Load(node); ok {
+		if time.Now().Before(until.(time.Time)) {
+			return ""
+		}
+		s.down.Delete(node)
+	}
+	return node
+}
+
+// Fetch 向负责 key 的节点获取 r 对应的内容, 超时只限制到收到响应头为止。
+// 没有需要转发的节点时返回 (nil, "", nil); 对方返回非 200 时视为失败, 由调用方回源
+func (s *PeerService) Fetch(r *http.Request, key string) (*http.Response, string, error) {
+	node := s.owner(key)
+	if node == "" {
+		return nil, "", nil
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	// 对方按原始 Host 匹配路由与缓存键, Host 随 uri 一起传递
+	target := node + PeerFetchPath + "?uri=" + url.QueryEscape(r.URL.RequestURI()) + "&host=" + url.QueryEscape(r.Host)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		cancel()
+		return nil, node, err
+	}
+	for name, values := range r.Header {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	for _, name := range peerSkipHeaders {
+		req.Header.Del(name)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+
+	timer := time.AfterFunc(time.Duration(s.timeout.Load()), cancel)
+	resp, err := s.client.Do(req)
+	if !timer.Stop() && err == nil {
+		// 超时与收到响应头同时发生, 按超时处理
+		resp.Body.Close()
+		err = context.DeadlineExceeded
+	}
+	if err != nil {
+		cancel()
+		if r.Context().Err() == nil {
+			s.markDown(node)
+		}
+		return nil, node, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusUnauthorized {
+			s.markDown(node)
+		}
+		return nil, node, fmt.Errorf("peer responded %s", resp.Status)
+	}
+	resp.Body = &peerBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, node, nil
+}
+
+// markDown 节点请求失败后一段时间内不再转发给它
+func (s *PeerService) markDown(node string) {
+	s.down.Store(node, time.Now().Add(peerDownBackoff))
+}
+
+// rebuild 合并本节点、静态节点与发现的节点, 重建哈希环
+func (s *PeerService) rebuild() {
+	seen := map[string]bool{}
+	var nodes []string
+	add := func(list []string) {
+		for _, raw := range list {
+			if node := normalizePeerURL(raw); node != "" && !seen[node] {
+				seen[node] = true
+				nodes = append(nodes, node)
+			}
+		}
+	}
+	add([]string{s.self})
+	if static := s.static.Load(); static != nil {
+		add(*static)
+	}
+	if discovered := s.discovered.Load(); discovered != nil && s.discovery.Load() {
+		add(*discovered)
+	}
+	sort.Strings(nodes)
+
+	if old := s.ring.Load(); old != nil && strings.Join(old.nodes, ",") == strings.Join(nodes, ",") {
+		return
+	}
+	s.ring.Store(newPeerRing(nodes))
+	if s.Enabled() {
+		log.Printf("[Peer] Ring updated: %d nodes %v", len(nodes), nodes)
+	}
+}
+
+// discoveryLoop 定期向 D1 节点注册表写入本节点心跳并拉取在线节点; 发现关闭时跳过本轮
+func (s *PeerService) discoveryLoop() {
+	ticker := time.NewTicker(peerDiscoveryInterval)
+	defer ticker.Stop()
+	for {
+		if s.discovery.Load() {
+			s.discover()
+		}
+		<-ticker.C
+	}
+}
+
+func (s *PeerService) discover() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	if err := d1sync.RegisterCacheNode(ctx, d1sync.CacheNode{NodeID: d1sync.NodeID(), URL: s.self, LastSeen: now.UnixMilli()}); err != nil {
+		log.Printf("[Peer] Failed to register node: %v", err)
+	}
+	nodes, err := d1sync.LoadCacheNodes(ctx, now.Add(-peerNodeTTL).UnixMilli())
+	if err != nil {
+		log.Printf("[Peer] Failed to load nodes: %v", err)
+		return
+	}
+	urls := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		urls = append(urls, node.URL)
+	}
+	s.discovered.Store(&urls)
+	s.rebuild()
+}
+
+// normalizePeerURL 统一节点地址格式 (去掉空白与末尾斜杠), 保证各节点构建出相同的环
+func normalizePeerURL(raw string) string {
+	return strings.TrimRight(strings.TrimSpace(raw), "/")
+}
+
+// peerBody 响应体读取完毕关闭时释放请求上下文
+type peerBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *peerBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
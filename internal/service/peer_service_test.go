@@ -0,0 +1,145 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"proxy-go/internal/cache"
+	"proxy-go/internal/config"
+)
+
+func TestPeerRingStableOwnership(t *testing.T) {
+	before := newPeerRing([]string{"http://a:3336", "http://b:3336", "http://c:3336"})
+	after := newPeerRing([]string{"http://a:3336", "http://b:3336", "http://c:3336", "http://d:3336"})
+
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("/static/file-%d.js", i)
+		owner := before.owner(key)
+		counts[owner]++
+		// 新增节点只接管部分 URL, 其余 URL 的负责节点不变
+		if moved := after.owner(key); moved != owner && moved != "http://d:3336" {
+			t.Fatalf("%s moved from %s to %s", key, owner, moved)
+		}
+	}
+	for node, n := range counts {
+		if n < 500 {
+			t.Errorf("%s owns only %d of 3000 keys", node, n)
+		}
+	}
+	if got := newPeerRing(nil).owner("/x"); got != "" {
+		t.Fatalf("empty ring owner = %q", got)
+	}
+}
+
+// newPeerTestService 创建本节点为 self、对等节点为 peerURL 的代理服务, 返回一个由对等节点负责的请求 URI
+func newPeerTestService(t *testing.T, peerURL string, timeoutMs int) (*ProxyService, string) {
+	t.Helper()
+	cm, err := cache.NewCacheManager(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cm.Stop)
+	peers := newPeerService(config.PeerCacheConfig{Enabled: true, Peers: []string{peerURL + "/"}, TimeoutMs: timeoutMs}, "http://self.invalid", "secret")
+	s := (&ProxyService{cache: cm}).WithPeers(peers)
+	for i := 0; ; i++ {
+		path := fmt.Sprintf("/app/file-%d.js?v=1", i)
+		if peers.owner(cm.GenerateCacheKey(httptest.NewRequest(http.MethodGet, path, nil), false).URL) == peerURL {
+			return s, path
+		}
+	}
+}
+
+func newPeerProxyRequest(r *http.Request) *ProxyRequest {
+	return &ProxyRequest{OriginalRequest: r, MatchedPrefix: "/app", TargetPath: r.URL.Path, StartTime: time.Now()}
+}
+
+func TestFetchFromPeer(t *testing.T) {
+	var hits atomic.Int32
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path != PeerFetchPath || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Range") != "" || r.Header.Get("If-None-Match") != "" {
+			http.Error(w, "conditional headers forwarded", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "peer:%s:%s:%s", r.URL.Query().Get("host"), r.URL.Query().Get("uri"), r.Header.Get("Accept"))
+	}))
+	defer peer.Close()
+
+	s, path := newPeerTestService(t, peer.URL, 0)
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.Header.Set("Accept", "text/javascript")
+	r.Header.Set("Range", "bytes=0-1")
+	r.Header.Set("If-None-Match", `"x"`)
+	req := newPeerProxyRequest(r)
+	resp, ok := s.FetchFromPeer(req)
+	if !ok {
+		t.Fatal("expected response from peer")
+	}
+	defer resp.Body.Close()
+	w := httptest.NewRecorder()
+	if _, err := s.ProcessResponse(req, resp, w, false); err != nil {
+		t.Fatal(err)
+	}
+	if want := "peer:" + r.Host + ":" + path + ":text/javascript"; w.Body.String() != want {
+		t.Fatalf("body = %q, want %q", w.Body.String(), want)
+	}
+	if w.Header().Get(PeerHeader) != "1" {
+		t.Fatalf("%s header missing", PeerHeader)
+	}
+
+	// 来自对等节点的请求与带 Authorization 的请求不再转发
+	for _, r := range []*http.Request{
+		WithPeerRequest(httptest.NewRequest(http.MethodGet, path, nil)),
+		func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			r.Header.Set("Authorization", "Bearer user")
+			return r
+		}(),
+	} {
+		if _, ok := s.FetchFromPeer(newPeerProxyRequest(r)); ok {
+			t.Fatal("request should not be forwarded to peer")
+		}
+	}
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("peer hits = %d, want 1", got)
+	}
+}
+
+func TestFetchFromPeerFallsBackOnTimeout(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer peer.Close()
+	defer close(release)
+
+	s, path := newPeerTestService(t, peer.URL, 50)
+	start := time.Now()
+	if _, ok := s.FetchFromPeer(newPeerProxyRequest(httptest.NewRequest(http.MethodGet, path, nil))); ok {
+		t.Fatal("slow peer should fall back to origin")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("fallback took %v", elapsed)
+	}
+	// 失败后暂停转发给该节点
+	if _, ok := s.FetchFromPeer(newPeerProxyRequest(httptest.NewRequest(http.MethodGet, path, nil))); ok {
+		t.Fatal("peer marked down should be skipped")
+	}
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("peer hits = %d, want 1", got)
+	}
+}
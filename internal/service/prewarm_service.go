@@ -0,0 +1,552 @@
+package service
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// PrewarmUserAgent 预热请求使用的 User-Agent, 便于在日志中区分
+	PrewarmUserAgent = "CZL-Proxy-Prewarm/1.0"
+
+	maxPrewarmURLs            = 10000
+	defaultPrewarmConcurrency = 4
+	maxPrewarmConcurrency     = 32
+	maxPrewarmJobs            = 20 // 保留的最近任务数
+	maxSitemapBytes           = 10 * 1024 * 1024
+	maxNestedSitemaps         = 20
+	prewarmRequestTimeout     = 60 * time.Second
+)
+
+const (
+	PrewarmStatusExpanding = "expanding"
+	PrewarmStatusRunning   = "running"
+	PrewarmStatusDone      = "done"
+	PrewarmStatusCancelled = "cancelled"
+	PrewarmStatusFailed    = "failed"
+)
+
+var (
+	ErrPrewarmEmpty       = errors.New("urls, sitemap or prefix with manifest is required")
+	ErrPrewarmInvalidType = errors.New("invalid cache type")
+	ErrPrewarmJobNotFound = errors.New("prewarm job not found")
+	ErrPrewarmInvalidHost = errors.New("invalid host")
+)
+
+// PrewarmRequest 预热请求; URLs / Sitemap / Prefix+Manifest 可同时提供, 展开后去重
+type PrewarmRequest struct {
+	URLs        []string          `json:"urls"`
+	Sitemap     string            `json:"sitemap"`     // sitemap.xml 或 sitemap 索引的完整 URL, 支持 .gz
+	Prefix      string            `json:"prefix"`      // 路径前缀, 与 Manifest 中的相对路径拼接
+	Manifest    []string          `json:"manifest"`    // 相对 Prefix 的路径列表
+	Type        string            `json:"type"`        // proxy (默认) / mirror
+	Concurrency int               `json:"concurrency"` // 并发数, 默认 4, 最大 32
+	Headers     map[string]string `json:"headers"`     // 附加请求头 (如 Accept), 用于预热对应的缓存变体
+	Host        string            `json:"host"`        // 路径形式的目标使用的 Host; 完整 URL 使用自身的 Host
+}
+
+// PrewarmResult 单个 URL 的预热结果
+type PrewarmResult struct {
+	URL        string `json:"url"`
+	Host       string `json:"host,omitempty"`
+	Status     int    `json:"status"`
+	Cache      string `json:"cache"` // HIT (已在缓存中) / MISS (本次回源写入)
+	Bytes      int64  `json:"bytes"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// PrewarmJob 预热任务快照
+type PrewarmJob struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Status     string          `json:"status"`
+	Total      int             `json:"total"`
+	Completed  int             `json:"completed"`
+	Succeeded  int             `json:"succeeded"`
+	Failed     int             `json:"failed"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	Results    []PrewarmResult `json:"results,omitempty"`
+}
+
+type prewarmJob struct {
+	mu     sync.Mutex
+	job    PrewarmJob
+	cancel context.CancelFunc
+}
+
+// snapshot 返回任务快照; withResults 为 false 时不含逐条结果
+func (j *prewarmJob) snapshot(withResults bool) PrewarmJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job := j.job
+	job.Results = nil
+	if withResults {
+		job.Results = append([]PrewarmResult(nil), j.job.Results...)
+	}
+	return job
+}
+
+func (j *prewarmJob) update(fn func(job *PrewarmJob)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(&j.job)
+}
+
+// PrewarmService 缓存预热服务: 把 URL 列表 / sitemap / 路径前缀+清单展开为站内 GET 请求,
+// 以有限并发交给代理处理器执行。与真实访问走同一条流水线 (路径匹配 / 规则 / 缓存键 / 回源),
+// 因此结果写入的正是访客会命中的缓存键。任务异步执行, 可按 ID 查询进度与逐条结果。
+type PrewarmService struct {
+	handlers map[string]http.Handler // "proxy" / "mirror"
+	client   *http.Client            // 拉取 sitemap
+
+	mu    sync.Mutex
+	jobs  map[string]*prewarmJob
+	order []string // 创建顺序, 用于淘汰旧任务
+}
+
+// NewPrewarmService 创建预热服务
+func NewPrewarmService(proxy, mirror http.Handler) *PrewarmService {
+	return &PrewarmService{
+		handlers: map[string]http.Handler{"proxy": proxy, "mirror": mirror},
+		client:   &http.Client{Timeout: 30 * time.Second},
+		jobs:     make(map[string]*prewarmJob),
+	}
+}
+
+// Start 校验请求并创建异步预热任务, 返回初始快照
+func (s *PrewarmService) Start(req PrewarmRequest) (PrewarmJob, error) {
+	cacheType := strings.TrimSpace(req.Type)
+	if cacheType == "" {
+		cacheType = "proxy"
+	}
+	handler, ok := s.handlers[cacheType]
+	if !ok || handler == nil {
+		return PrewarmJob{}, ErrPrewarmInvalidType
+	}
+	if len(req.URLs) == 0 && strings.TrimSpace(req.Sitemap) == "" && len(req.Manifest) == 0 {
+		return PrewarmJob{}, ErrPrewarmEmpty
+	}
+	req.Host = strings.TrimSpace(req.Host)
+	if strings.ContainsAny(req.Host, "/?#@ \t") {
+		return PrewarmJob{}, ErrPrewarmInvalidHost
+	}
+	if req.Sitemap != "" {
+		if err := validateSitemapURL(req.Sitemap); err != nil {
+			return PrewarmJob{}, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &prewarmJob{
+		job: PrewarmJob{
+			ID:        newPrewarmID(),
+			Type:      cacheType,
+			Status:    PrewarmStatusExpanding,
+			CreatedAt: time.Now(),
+		},
+		cancel: cancel,
+	}
+	s.addJob(job)
+
+	go s.run(ctx, job, handler, req)
+	return job.snapshot(false), nil
+}
+
+// Get 返回任务快照 (含逐条结果)
+func (s *PrewarmService) Get(id string) (PrewarmJob, error) {
+	s.mu.Lock()
+	job, ok := s.jobs[id]
+	s.mu.Unlock()
+	if !ok {
+		return PrewarmJob{}, ErrPrewarmJobNotFound
+	}
+	return job.snapshot(true), nil
+}
+
+// List 返回最近任务的概要, 新任务在前
+func (s *PrewarmService) List() []PrewarmJob {
+	s.mu.Lock()
+	jobs := make([]*prewarmJob, 0, len(s.order))
+	for _, id := range s.order {
+		jobs = append(jobs, s.jobs[id])
+	}
+	s.mu.Unlock()
+
+	list := make([]PrewarmJob, 0, len(jobs))
+	for i := len(jobs) - 1; i >= 0; i-- {
+		list = append(list, jobs[i].snapshot(false))
+	}
+	return list
+}
+
+// Cancel 取消进行中的任务; 已发出的请求会随上下文取消而中止
+func (s *PrewarmService) Cancel(id string) error {
+	s.mu.Lock()
+	job, ok := s.jobs[id]
+	s.mu.Unlock()
+	if !ok {
+		return ErrPrewarmJobNotFound
+	}
+	job.cancel()
+	return nil
+}
+
+// addJob 登记任务, 超过保留数时淘汰最早的已结束任务
+func (s *PrewarmService) addJob(job *prewarmJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.job.ID] = job
+	s.order = append(s.order, job.job.ID)
+
+	for i := 0; len(s.order) > maxPrewarmJobs && i < len(s.order); {
+		id := s.order[i]
+		if status := s.jobs[id].snapshot(false).Status; status == PrewarmStatusExpanding || status == PrewarmStatusRunning {
+			i++
+			continue
+		}
+		delete(s.jobs, id)
+		s.order = append(s.order[:i], s.order[i+1:]...)
+	}
+}
+
+// run 展开目标并以有限并发执行
+func (s *PrewarmService) run(ctx context.Context, job *prewarmJob, handler http.Handler, req PrewarmRequest) {
+	defer job.cancel()
+
+	targets, err := s.expandTargets(ctx, job.job.Type, req)
+	if err != nil {
+		s.finish(job, PrewarmStatusFailed, err.Error())
+		log.Printf("[Prewarm] Job %s failed: %v", job.job.ID, err)
+		return
+	}
+	job.update(func(j *PrewarmJob) {
+		j.Status = PrewarmStatusRunning
+		j.Total = len(targets)
+		j.Results = make([]PrewarmResult, 0, len(targets))
+	})
+	log.Printf("[Prewarm] Job %s started: %d urls (%s)", job.job.ID, len(targets), job.job.Type)
+
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultPrewarmConcurrency
+	}
+	concurrency = min(concurrency, maxPrewarmConcurrency, max(len(targets), 1))
+
+	queue := make(chan prewarmTarget)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range queue {
+				result := warmOne(ctx, handler, target, req.Headers)
+				job.update(func(j *PrewarmJob) {
+					j.Completed++
+					if result.Error == "" {
+						j.Succeeded++
+					} else {
+						j.Failed++
+					}
+					j.Results = append(j.Results, result)
+				})
+			}
+		}()
+	}
+
+feed:
+	for _, target := range targets {
+		select {
+		case queue <- target:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	status := PrewarmStatusDone
+	if ctx.Err() != nil {
+		status = PrewarmStatusCancelled
+	}
+	s.finish(job, status, "")
+	snap := job.snapshot(false)
+	log.Printf("[Prewarm] Job %s %s: %d/%d succeeded, %d failed", snap.ID, status, snap.Succeeded, snap.Total, snap.Failed)
+}
+
+func (s *PrewarmService) finish(job *prewarmJob, status, errMsg string) {
+	now := time.Now()
+	job.update(func(j *PrewarmJob) {
+		j.Status = status
+		j.Error = errMsg
+		j.FinishedAt = &now
+	})
+}
+
+// prewarmTarget 一个预热目标: 站内请求路径 (含 query) 与请求使用的 Host (为空时不设置)
+type prewarmTarget struct {
+	host string
+	uri  string
+}
+
+// expandTargets 把请求展开为预热目标, 按 Host + 路径去重并限制数量
+func (s *PrewarmService) expandTargets(ctx context.Context, cacheType string, req PrewarmRequest) ([]prewarmTarget, error) {
+	var raw []string
+	raw = append(raw, req.URLs...)
+
+	if len(req.Manifest) > 0 {
+		prefix := "/" + strings.Trim(strings.TrimSpace(req.Prefix), "/")
+		for _, entry := range req.Manifest {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			raw = append(raw, strings.TrimSuffix(prefix, "/")+"/"+strings.TrimLeft(entry, "/"))
+		}
+	}
+
+	if sitemap := strings.TrimSpace(req.Sitemap); sitemap != "" {
+		locs, err := s.fetchSitemap(ctx, sitemap)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, locs...)
+	}
+
+	seen := make(map[prewarmTarget]bool)
+	var targets []prewarmTarget
+	for _, value := range raw {
+		target, err := parsePrewarmTarget(cacheType, value, req.Host)
+		if err != nil {
+			return nil, err
+		}
+		if target.uri == "" || seen[target] {
+			continue
+		}
+		seen[target] = true
+		targets = append(targets, target)
+		if len(targets) > maxPrewarmURLs {
+			return nil, fmt.Errorf("too many urls: at most %d per job", maxPrewarmURLs)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, ErrPrewarmEmpty
+	}
+	return targets, nil
+}
+
+// parsePrewarmTarget 把输入转为预热目标:
+// proxy 类型接受站内路径 (Host 取 host 参数) 或完整 URL (取路径与 query, 保留其 Host, 站点可能按 Host 区分路由与缓存);
+// mirror 类型接受上游完整 URL, 转为 /mirror/<url>
+func parsePrewarmTarget(cacheType, value, host string) (prewarmTarget, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return prewarmTarget{}, nil
+	}
+	if cacheType == "mirror" {
+		if strings.HasPrefix(value, "/mirror/") {
+			return prewarmTarget{uri: value}, nil
+		}
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return prewarmTarget{}, fmt.Errorf("invalid mirror url: %s", value)
+		}
+		return prewarmTarget{uri: "/mirror/" + value}, nil
+	}
+
+	if strings.HasPrefix(value, "/") {
+		if i := strings.IndexByte(value, '#'); i >= 0 {
+			value = value[:i]
+		}
+		return prewarmTarget{host: host, uri: value}, nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return prewarmTarget{}, fmt.Errorf("invalid url: %s", value)
+	}
+	target := prewarmTarget{host: u.Host, uri: u.EscapedPath()}
+	if target.uri == "" {
+		target.uri = "/"
+	}
+	if u.RawQuery != "" {
+		target.uri += "?" + u.RawQuery
+	}
+	return target, nil
+}
+
+// sitemapDocument 兼容 <urlset> 与 <sitemapindex>
+type sitemapDocument struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// fetchSitemap 拉取 sitemap, sitemap 索引最多展开一层 (至多 maxNestedSitemaps 个子 sitemap)
+func (s *PrewarmService) fetchSitemap(ctx context.Context, sitemapURL string) ([]string, error) {
+	doc, err := s.fetchSitemapDocument(ctx, sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var locs []string
+	for _, u := range doc.URLs {
+		locs = append(locs, u.Loc)
+	}
+	for i, sm := range doc.Sitemaps {
+		if i >= maxNestedSitemaps {
+			log.Printf("[Prewarm] Sitemap index %s has more than %d sitemaps, rest ignored", sitemapURL, maxNestedSitemaps)
+			break
+		}
+		if err := validateSitemapURL(sm.Loc); err != nil {
+			return nil, err
+		}
+		child, err := s.fetchSitemapDocument(ctx, strings.TrimSpace(sm.Loc))
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range child.URLs {
+			locs = append(locs, u.Loc)
+		}
+	}
+	return locs, nil
+}
+
+func (s *PrewarmService) fetchSitemapDocument(ctx context.Context, sitemapURL string) (*sitemapDocument, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sitemapURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sitemap url: %v", err)
+	}
+	req.Header.Set("User-Agent", PrewarmUserAgent)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sitemap: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch sitemap %s: status %d", sitemapURL, resp.StatusCode)
+	}
+
+	var body io.Reader = io.LimitReader(resp.Body, maxSitemapBytes)
+	if strings.HasSuffix(req.URL.Path, ".gz") && resp.Header.Get("Content-Encoding") == "" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip sitemap: %v", err)
+		}
+		defer gz.Close()
+		body = io.LimitReader(gz, maxSitemapBytes)
+	}
+
+	var doc sitemapDocument
+	if err := xml.NewDecoder(body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid sitemap %s: %v", sitemapURL, err)
+	}
+	return &doc, nil
+}
+
+func validateSitemapURL(raw string) error {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid sitemap url: %s", raw)
+	}
+	return nil
+}
+
+// warmOne 通过代理处理器执行一次站内 GET, 读完响应体以便缓存写入完成
+func warmOne(ctx context.Context, handler http.Handler, target prewarmTarget, headers map[string]string) PrewarmResult {
+	start := time.Now()
+	result := PrewarmResult{URL: target.uri, Host: target.host}
+
+	reqCtx, cancel := context.WithTimeout(ctx, prewarmRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, target.uri, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if target.host != "" {
+		req.Host = target.host
+	}
+	req.RemoteAddr = "127.0.0.1:0"
+	req.Header.Set("User-Agent", PrewarmUserAgent)
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		req.Header.Set(k, headers[k])
+	}
+
+	w := &prewarmResponseWriter{header: make(http.Header)}
+	handler.ServeHTTP(w, req)
+
+	result.Status = w.statusCode()
+	result.Bytes = w.written
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.Cache = "MISS"
+	if w.header.Get("CZL-Proxy-Cache-HIT") == "1" {
+		result.Cache = "HIT"
+	}
+	if result.Status >= http.StatusBadRequest {
+		result.Error = http.StatusText(result.Status)
+	} else if err := reqCtx.Err(); err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// prewarmResponseWriter 丢弃响应体, 只记录状态码与字节数
+type prewarmResponseWriter struct {
+	header  http.Header
+	status  int
+	written int64
+}
+
+func (w *prewarmResponseWriter) Header() http.Header { return w.header }
+
+func (w *prewarmResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *prewarmResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.written += int64(len(p))
+	return len(p), nil
+}
+
+func (w *prewarmResponseWriter) Flush() {}
+
+func (w *prewarmResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func newPrewarmID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitPrewarmJob(t *testing.T, s *PrewarmService, id string) PrewarmJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := s.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.FinishedAt != nil {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("prewarm job did not finish")
+	return PrewarmJob{}
+}
+
+func TestPrewarmServiceExpandsSourcesWithBoundedConcurrency(t *testing.T) {
+	var origin *httptest.Server
+	origin = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/posts.xml</loc></sitemap></sitemapindex>`, origin.URL)
+		case "/posts.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%[1]s/blog/a</loc></url><url><loc>%[1]s/blog/b?page=2</loc></url><url><loc>%[1]s/static/app.js</loc></url></urlset>`, origin.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer origin.Close()
+
+	var (
+		mu       sync.Mutex
+		paths    []string
+		inFlight atomic.Int32
+		peak     atomic.Int32
+	)
+	proxy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		paths = append(paths, r.Host+r.URL.RequestURI()+" "+r.Header.Get("Accept"))
+		mu.Unlock()
+		if r.URL.Path == "/static/missing.css" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/static/app.js" {
+			w.Header().Set("CZL-Proxy-Cache-HIT", "1")
+		}
+		w.Write([]byte("body"))
+	})
+
+	s := NewPrewarmService(proxy, nil)
+	job, err := s.Start(PrewarmRequest{
+		URLs:        []string{"/static/app.js", "https://example.com/static/app.js#top"},
+		Sitemap:     origin.URL + "/sitemap.xml",
+		Prefix:      "/static",
+		Manifest:    []string{"missing.css", "/logo.png"},
+		Concurrency: 2,
+		Headers:     map[string]string{"Accept": "image/webp"},
+		Host:        "example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := waitPrewarmJob(t, s, job.ID)
+	// sitemap 中的 URL 保留各自的 Host, 与 example.com 上的同名路径是不同的目标
+	if done.Status != PrewarmStatusDone || done.Total != 6 || done.Completed != 6 || done.Succeeded != 5 || done.Failed != 1 {
+		t.Fatalf("job = %+v", done)
+	}
+	if peak.Load() > 2 {
+		t.Fatalf("peak concurrency = %d, want <= 2", peak.Load())
+	}
+
+	originHost := strings.TrimPrefix(origin.URL, "http://")
+	sort.Strings(paths)
+	want := []string{
+		originHost + "/blog/a image/webp",
+		originHost + "/blog/b?page=2 image/webp",
+		originHost + "/static/app.js image/webp",
+		"example.com/static/app.js image/webp",
+		"example.com/static/logo.png image/webp",
+		"example.com/static/missing.css image/webp",
+	}
+	sort.Strings(want)
+	if fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Fatalf("paths = %v", paths)
+	}
+	for _, r := range done.Results {
+		if r.URL == "/static/app.js" && r.Cache != "HIT" {
+			t.Fatalf("result = %+v", r)
+		}
+		if r.URL == "/static/missing.css" && (r.Status != http.StatusNotFound || r.Error == "") {
+			t.Fatalf("result = %+v", r)
+		}
+	}
+	if list := s.List(); len(list) != 1 || list[0].Results != nil {
+		t.Fatalf("list = %+v", list)
+	}
+}
+
+func TestPrewarmServiceValidation(t *testing.T) {
+	s := NewPrewarmService(http.NotFoundHandler(), nil)
+	if _, err := s.Start(PrewarmRequest{}); err != ErrPrewarmEmpty {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := s.Start(PrewarmRequest{URLs: []string{"/a"}, Type: "mirror"}); err != ErrPrewarmInvalidType {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := s.Start(PrewarmRequest{Sitemap: "ftp://example.com/sitemap.xml"}); err == nil {
+		t.Fatal("non-http sitemap should be rejected")
+	}
+
+	if _, err := s.Start(PrewarmRequest{URLs: []string{"/a"}, Host: "evil.com/x"}); err != ErrPrewarmInvalidHost {
+		t.Fatalf("err = %v", err)
+	}
+
+	if target, _ := parsePrewarmTarget("mirror", "https://cdn.example.com/a.js", ""); target.uri != "/mirror/https://cdn.example.com/a.js" {
+		t.Fatalf("mirror target = %+v", target)
+	}
+	if target, _ := parsePrewarmTarget("proxy", "https://a.example.com/x.js?v=1", "b.example.com"); target != (prewarmTarget{host: "a.example.com", uri: "/x.js?v=1"}) {
+		t.Fatalf("full url target = %+v", target)
+	}
+	if target, _ := parsePrewarmTarget("proxy", "/x.js", "b.example.com"); target != (prewarmTarget{host: "b.example.com", uri: "/x.js"}) {
+		t.Fatalf("path target = %+v", target)
+	}
+	if _, err := parsePrewarmTarget("proxy", "example.com/a.js", ""); err == nil {
+		t.Fatal("relative url without leading slash should be rejected")
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -8,11 +9,10 @@ import (
 	"net/url"
 	"proxy-go/internal/cache"
 	"proxy-go/internal/config"
+	"proxy-go/internal/security"
 	"proxy-go/internal/utils"
 	"strings"
 	"time"
-
-	"github.com/woodchen-ink/go-web-utils/iputil"
 )
 
 // ProxyRequest 代理请求结构
@@ -26,6 +26,21 @@ type ProxyRequest struct {
 	// cacheKey 是延迟生成的缓存键，每次请求最多生成一次
 	cacheKey    cache.CacheKey
 	cacheKeySet bool
+	// cacheStatus 本次请求的缓存处理结果 (cache.CacheStatus*), 空值视为 MISS
+	cacheStatus string
+	// fromPeer 响应由对等节点提供
+	fromPeer bool
+	// body / bodyKey 按请求体缓存的 POST / QUERY 请求: 缓冲的请求体与其缓存键维度, 见 BufferBody
+	body    []byte
+	bodyKey string
+}
+
+// CacheStatus 返回本次请求的缓存处理结果, 用于 CZL-Proxy-Cache-Status 响应头与统计
+func (req *ProxyRequest) CacheStatus() string {
+	if req.cacheStatus == "" {
+		return cache.CacheStatusMiss
+	}
+	return req.cacheStatus
 }
 
 // ProxyResponse 代理响应结构
@@ -56,7 +71,8 @@ type ProxyService struct {
 	cache           *cache.CacheManager
 	ruleService     *RuleService
 	redirectService *RedirectService
-	retryConfig     RetryConfig // 重试配置
+	retryConfig     RetryConfig  // 重试配置
+	peers           *PeerService // 对等缓存 (可选)
 }
 
 func NewProxyService(client *http.Client, cache *cache.CacheManager, ruleService *RuleService) *ProxyService {
@@ -71,21 +87,124 @@ func NewProxyService(client *http.Client, cache *cache.CacheManager, ruleService
 	}
 }
 
+// WithPeers 启用对等缓存, 缓存未命中时先向负责该 URL 的节点获取
+func (s *ProxyService) WithPeers(peers *PeerService) *ProxyService {
+	s.peers = peers
+	return s
+}
+
+// Peers 返回对等缓存服务, 未设置时为 nil
+func (s *ProxyService) Peers() *PeerService {
+	return s.peers
+}
+
 // CheckCache 检查缓存
 func (s *ProxyService) CheckCache(req *ProxyRequest) (*cache.CacheItem, bool, bool) {
-	if req.OriginalRequest.Method != http.MethodGet || s.cache == nil {
+	if !req.cacheableMethod() || s.cache == nil || s.cacheBypassed(req) {
 		return nil, false, false
 	}
 
 	cacheKey := s.getOrBuildCacheKey(req)
+	existed := s.cache.Contains(cacheKey)
 	item, hit, notModified := s.cache.Get(cacheKey, req.OriginalRequest, req.PathConfig.CFImageOpt)
+	switch {
+	case hit:
+		req.cacheStatus = cache.CacheStatusHit
+	case existed:
+		req.cacheStatus = cache.CacheStatusExpired
+	}
 	return item, hit, notModified
 }
 
+// InvalidateCache 删除本次请求对应的缓存项 (缓存文件丢失或无法按客户端编码输出时)
+func (s *ProxyService) InvalidateCache(req *ProxyRequest) {
+	if s.cache != nil {
+		s.cache.InvalidateCacheItem(s.getOrBuildCacheKey(req))
+	}
+}
+
+// CheckStaleCache 回源失败时查找软清理后保留的旧内容 (stale-if-error), 找到时标记为 STALE
+func (s *ProxyService) CheckStaleCache(req *ProxyRequest) (*cache.CacheItem, bool) {
+	if !req.cacheableMethod() || s.cache == nil || req.cacheStatus == cache.CacheStatusBypass {
+		return nil, false
+	}
+	item, ok := s.cache.GetStale(s.getOrBuildCacheKey(req))
+	if ok {
+		req.cacheStatus = cache.CacheStatusStale
+	}
+	return item, ok
+}
+
+// FetchFromPeer 缓存未命中时向负责该 URL 的对等节点获取内容 (仅 GET)。
+// 未启用、由本节点负责、请求本身来自对等节点或获取失败时返回 false, 由调用方回源
+func (s *ProxyService) FetchFromPeer(req *ProxyRequest) (*http.Response, bool) {
+	r := req.OriginalRequest
+	if s.peers == nil || !s.peers.Enabled() || s.cache == nil || r.Method != http.MethodGet ||
+		IsPeerRequest(r) || s.cacheBypassed(req) || r.Header.Get("Authorization") != "" {
+		return nil, false
+	}
+	cacheKey := s.getOrBuildCacheKey(req)
+	// 软清理的缓存项需要回源重新验证, 对方节点可能仍是旧内容
+	if _, stale := s.cache.GetStale(cacheKey); stale {
+		return nil, false
+	}
+	resp, peer, err := s.peers.Fetch(r, cacheKey.URL)
+	if err != nil {
+		log.Printf("[Peer] %s %s from %s failed, fallback to origin: %v", r.Method, r.URL.Path, peer, err)
+		return nil, false
+	}
+	if resp == nil {
+		return nil, false
+	}
+	req.fromPeer = true
+	return resp, true
+}
+
+// cacheBypassed 判断请求是否命中路径的 bypass 规则, 命中时标记为 BYPASS
+func (s *ProxyService) cacheBypassed(req *ProxyRequest) bool {
+	if req.cacheStatus == cache.CacheStatusBypass {
+		return true
+	}
+	if cacheBypassedByRequest(req.PathConfig.CacheRules, req.OriginalRequest) {
+		req.cacheStatus = cache.CacheStatusBypass
+		return true
+	}
+	return false
+}
+
+// passRedirectsKey 回源请求 context 标记: 源站返回的重定向不跟随, 见 PassRedirects
+type passRedirectsKey struct{}
+
+// PassRedirects 判断回源请求的重定向是否应原样返回给客户端, 供回源 http.Client 的 CheckRedirect 使用
+func PassRedirects(req *http.Request) bool {
+	pass, _ := req.Context().Value(passRedirectsKey{}).(bool)
+	return pass
+}
+
+// cachesRedirects 判断路径的 StatusCache 是否配置了重定向状态码 (301 / 302 / 308)
+func cachesRedirects(pc config.PathConfig) bool {
+	ttls, _ := pc.StatusCacheTTLs()
+	for code := range ttls {
+		if code >= 300 && code < 400 {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckStatusCache 检查状态码缓存 (按路径 StatusCache 配置缓存的 404 / 301 等响应)
+func (s *ProxyService) CheckStatusCache(req *ProxyRequest) (*cache.StatusEntry, bool) {
+	if !req.cacheableMethod() || s.cache == nil || s.cacheBypassed(req) {
+		return nil, false
+	}
+	return s.cache.GetStatus(s.getOrBuildCacheKey(req))
+}
+
 // getOrBuildCacheKey 从 ProxyRequest 取缓存键，首次调用时生成并复用
 func (s *ProxyService) getOrBuildCacheKey(req *ProxyRequest) cache.CacheKey {
 	if !req.cacheKeySet {
 		req.cacheKey = s.cache.GenerateCacheKey(req.OriginalRequest, req.PathConfig.CFImageOpt)
+		req.cacheKey.Body = req.bodyKey
 		req.cacheKeySet = true
 	}
 	return req.cacheKey
@@ -139,12 +258,22 @@ func (s *ProxyService) CreateProxyRequest(req *ProxyRequest, targetURL string) (
 	// 构建完整的目标URL
 	fullTargetURL := s.buildTargetURL(targetURL, req.TargetPath, req.OriginalRequest.URL.RawQuery)
 
+	// 本地目录目标: 把根目录与目录访问策略带给 FileTransport, 由其做越界检查
+	ctx := req.OriginalRequest.Context()
+	if config.IsFileTarget(targetURL) {
+		ctx = withFileTarget(ctx, targetURL, req.PathConfig.FileServer)
+	}
+	// 路径缓存重定向状态码时, 源站的 3xx 原样返回给客户端 (并写入状态码缓存), 不由回源客户端跟随
+	if cachesRedirects(req.PathConfig) {
+		ctx = context.WithValue(ctx, passRedirectsKey{}, true)
+	}
+
 	// 创建新请求
 	proxyReq, err := http.NewRequestWithContext(
-		req.OriginalRequest.Context(),
+		ctx,
 		req.OriginalRequest.Method,
 		fullTargetURL,
-		req.OriginalRequest.Body,
+		req.requestBody(),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create proxy request: %v", err)
@@ -173,8 +302,8 @@ func (s *ProxyService) CreateProxyRequest(req *ProxyRequest, targetURL string) (
 			proxyReq.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7")
 		}
 
-		// 处理Accept-Encoding
-		if ae := req.OriginalRequest.Header.Get("Accept-Encoding"); ae != "" {
+		// 处理Accept-Encoding: 可缓存的请求不转发客户端的编码偏好, 缓存只保存未压缩内容 (见 content_encoding.go)
+		if ae := req.OriginalRequest.Header.Get("Accept-Encoding"); ae != "" && !s.cacheableRequest(req) {
 			proxyReq.Header.Set("Accept-Encoding", ae)
 		} else {
 			proxyReq.Header.Del("Accept-Encoding")
@@ -193,18 +322,8 @@ func (s *ProxyService) CreateProxyRequest(req *ProxyRequest, targetURL string) (
 			proxyReq.Header.Set("CF-Image-Format", "auto")
 		}
 
-		// 设置代理头部
-		clientIP := iputil.GetClientIP(req.OriginalRequest)
-		proxyReq.Header.Set("X-Real-IP", clientIP)
-
-		// 设置X-Forwarded-For
-		if clientIP != "" {
-			if prior := proxyReq.Header.Get("X-Forwarded-For"); prior != "" {
-				proxyReq.Header.Set("X-Forwarded-For", prior+", "+clientIP)
-			} else {
-				proxyReq.Header.Set("X-Forwarded-For", clientIP)
-			}
-		}
+		// 设置代理头部 (X-Real-IP / X-Forwarded-* / Forwarded / Via), 按路径级策略写入或剥离
+		applyForwardHeaders(proxyReq.Header, req.OriginalRequest, req.PathConfig.ForwardHeaders)
 
 		// 处理Cookie安全属性
 		if req.OriginalRequest.TLS != nil && len(proxyReq.Cookies()) > 0 {
@@ -220,6 +339,14 @@ func (s *ProxyService) CreateProxyRequest(req *ProxyRequest, targetURL string) (
 		}
 	}
 
+	// 回源鉴权: 放在路径改写与头部整理之后, 保证签名覆盖最终发出的 URL / Host;
+	// 每个源 (含回落) 各自构建请求, 因此按目标分别签名
+	if auth := req.PathConfig.OriginAuthFor(targetURL); auth != nil {
+		if err := applyOriginAuth(proxyReq, auth, time.Now()); err != nil {
+			return nil, fmt.Errorf("origin auth for %s: %w", targetURL, err)
+		}
+	}
+
 	return proxyReq, nil
 }
 
@@ -229,7 +356,7 @@ func (s *ProxyService) ExecuteRequest(proxyReq *http.Request) (*http.Response, e
 	resp, err := ExecuteWithRetry(s.client, proxyReq, s.retryConfig)
 
 	if err != nil {
-		return nil, fmt.Errorf("proxy request failed after retries: %v", err)
+		return nil, fmt.Errorf("proxy request failed after retries: %w", err)
 	}
 	return resp, nil
 }
@@ -240,8 +367,14 @@ func (s *ProxyService) ExecuteRequest(proxyReq *http.Request) (*http.Response, e
 //  1. 方法幂等 (GET/HEAD/OPTIONS): 非幂等方法 (POST/PUT/PATCH/DELETE) 换源会放大副作用 (重复下单 / 重复写入), 一律只打首源
 //  2. 无请求体 (ContentLength <= 0): CreateProxyRequest 直接复用 OriginalRequest.Body, 读一次即耗尽, 无法重放给下一个源
 //
+// 例外: 路径配置了 BodyCache 的 POST / QUERY 请求已声明为只读查询, 请求体已缓冲 (见 BufferBody), 每个源重放同一份请求体。
+//
 // 注: 服务端的 r.Body 永不为 nil (空体也是 http.NoBody), 故用 ContentLength 判断有无 body, 不靠 Body 身份。
-func canFailover(r *http.Request) bool {
+func canFailover(req *ProxyRequest) bool {
+	if req.body != nil {
+		return true
+	}
+	r := req.OriginalRequest
 	switch r.Method {
 	case http.MethodGet, http.MethodHead, http.MethodOptions:
 		return r.ContentLength <= 0
@@ -257,7 +390,7 @@ func canFailover(r *http.Request) bool {
 //
 // 边界处理:
 //   - targets 为空: 返回 error
-//   - 单源 / 请求不可回落 (带 body 的非幂等请求): 只打首源, 等价于旧行为
+//   - 单源 / 请求不可回落 (带 body 且未缓冲的请求 / 非幂等请求): 只打首源, 等价于旧行为
 //   - 全部源都是可回落状态码: 返回最后一个响应 (让客户端拿到真实错误, 而不是吞掉)
 //   - 全部源都是连接错误: 返回最后一个 error
 func (s *ProxyService) ExecuteRequestWithFailover(req *ProxyRequest, targets []string) (*http.Response, string, bool, error) {
@@ -266,7 +399,7 @@ func (s *ProxyService) ExecuteRequestWithFailover(req *ProxyRequest, targets []s
 	}
 
 	// 不可回落或单源: 只打首源, 走原有单源路径
-	if len(targets) == 1 || !canFailover(req.OriginalRequest) {
+	if len(targets) == 1 || !canFailover(req) {
 		target := targets[0]
 		httpReq, err := s.CreateProxyRequest(req, target)
 		if err != nil {
@@ -315,7 +448,7 @@ func (s *ProxyService) ExecuteRequestWithFailover(req *ProxyRequest, targets []s
 	}
 
 	// 所有源都因构建请求失败 / 连接错误而无响应
-	return nil, targets[len(targets)-1], true, fmt.Errorf("all %d upstreams failed, last error: %v", len(targets), lastErr)
+	return nil, targets[len(targets)-1], true, fmt.Errorf("all %d upstreams failed, last error: %w", len(targets), lastErr)
 }
 
 // ProcessResponse 处理代理响应
@@ -323,8 +456,19 @@ func (s *ProxyService) ProcessResponse(req *ProxyRequest, resp *http.Response, w
 	// 复制响应头
 	s.copyHeaders(w.Header(), resp.Header)
 
-	// 设置代理相关头部
+	// 设置代理相关头部; 请求 ID 在复制上游头之后回写, 避免被源站同名头覆盖
+	if requestID := req.OriginalRequest.Header.Get(utils.RequestIDHeader); requestID != "" {
+		w.Header().Set(utils.RequestIDHeader, requestID)
+	}
+	// 命中 no-store 规则 (如响应带 Set-Cookie) 的响应不写入缓存
+	if s.cache != nil && req.cacheStatus != cache.CacheStatusBypass && cacheNoStore(req.PathConfig.CacheRules, req.OriginalRequest, resp) {
+		req.cacheStatus = cache.CacheStatusBypass
+	}
 	w.Header().Set("CZL-Proxy-Cache-HIT", "0")
+	w.Header().Set(cache.CacheStatusHeader, req.CacheStatus())
+	if req.fromPeer {
+		w.Header().Set(PeerHeader, "1")
+	}
 	if altTarget {
 		w.Header().Set("CZL-Proxy-AltTarget", "1")
 	} else {
@@ -343,6 +487,16 @@ func (s *ProxyService) ProcessResponse(req *ProxyRequest, resp *http.Response, w
 		}
 	}
 
+	// 可压缩内容的缓存命中会按 Accept-Encoding 返回不同编码; 接受 gzip 的客户端在回源时即时压缩输出
+	cacheable := s.shouldCache(req, resp)
+	compress := cacheable && compressOnMiss(req.OriginalRequest, resp)
+	if cacheable && cache.Compressible(resp.Header.Get("Content-Type")) {
+		cache.AddVary(w.Header(), "Accept-Encoding")
+	}
+	if compress {
+		setCompressedHeaders(w.Header())
+	}
+
 	// 设置状态码
 	w.WriteHeader(resp.StatusCode)
 
@@ -350,9 +504,17 @@ func (s *ProxyService) ProcessResponse(req *ProxyRequest, resp *http.Response, w
 	var err error
 
 	// 处理缓存写入
-	if s.shouldCache(req, resp) {
+	if compress {
+		written, err = gzipTo(w, func(dst io.Writer) (int64, error) {
+			return s.processWithCache(req, resp, dst)
+		})
+	} else if cacheable {
 		written, err = s.processWithCache(req, resp, w)
 	} else {
+		// 非 200 响应按路径的 StatusCache 配置只缓存状态码与响应头
+		if s.shouldCacheStatus(req, resp) {
+			s.cache.PutStatus(s.getOrBuildCacheKey(req), resp)
+		}
 		// 🚀 零拷贝优化: 使用 buffer pool 复用缓冲区
 		buf := cache.GetBuffer(32 * 1024)
 		defer cache.PutBuffer(buf)
@@ -368,49 +530,52 @@ func (s *ProxyService) ProcessResponse(req *ProxyRequest, resp *http.Response, w
 
 // shouldCache 判断是否应该缓存
 func (s *ProxyService) shouldCache(req *ProxyRequest, resp *http.Response) bool {
-	return req.OriginalRequest.Method == http.MethodGet &&
+	return req.cacheableMethod() &&
 		resp.StatusCode == http.StatusOK &&
-		s.cache != nil
+		s.cache != nil &&
+		req.cacheStatus != cache.CacheStatusBypass &&
+		cacheableEncoding(resp)
+}
+
+// cacheableRequest 判断请求的响应是否可能写入缓存 (GET 或按请求体缓存的请求, 且未命中 bypass 规则)
+func (s *ProxyService) cacheableRequest(req *ProxyRequest) bool {
+	return req.cacheableMethod() && s.cache != nil && !s.cacheBypassed(req)
+}
+
+// shouldCacheStatus 判断是否尝试缓存非 200 响应 (是否缓存由路径的 StatusCache 配置决定)
+func (s *ProxyService) shouldCacheStatus(req *ProxyRequest, resp *http.Response) bool {
+	return req.cacheableMethod() &&
+		resp.StatusCode != http.StatusOK &&
+		s.cache != nil &&
+		req.cacheStatus != cache.CacheStatusBypass
 }
 
 // processWithCache 处理带缓存的响应
-func (s *ProxyService) processWithCache(req *ProxyRequest, resp *http.Response, w http.ResponseWriter) (int64, error) {
+func (s *ProxyService) processWithCache(req *ProxyRequest, resp *http.Response, w io.Writer) (int64, error) {
 	cacheKey := s.getOrBuildCacheKey(req)
 
-	if cacheFile, err := s.cache.CreateTemp(cacheKey, resp); err == nil {
+	if cacheWriter, err := s.cache.NewCacheWriter(cacheKey, resp); err == nil {
 		// 🚀 零拷贝优化: 使用 buffer pool 复用缓冲区
 		buf := cache.GetBuffer(32 * 1024)
 		defer cache.PutBuffer(buf)
 
-		teeReader := io.TeeReader(resp.Body, cacheFile)
-		written, err := io.CopyBuffer(w, teeReader, buf)
-
-		// 🔧 修复: 确保文件完全写入并同步到磁盘后再关闭和提交
-		// 1. 先同步文件内容到磁盘
-		if syncErr := cacheFile.Sync(); syncErr != nil && err == nil {
-			// 如果同步失败，记录错误但继续（不影响客户端响应）
-			// 这里不设置 err，因为客户端已经收到了数据
+		// 边转发边写入临时文件并计算哈希, 超过最大缓存对象大小时写入器自行放弃缓存
+		written, err := io.CopyBuffer(w, io.TeeReader(resp.Body, cacheWriter), buf)
+		if err != nil {
+			cacheWriter.Abort()
+			return written, err
 		}
 
-		// 2. 关闭文件，确保所有缓冲区都被刷新
-		closeErr := cacheFile.Close()
-
-		// 3. 只有在写入成功且文件正确关闭的情况下才提交缓存
-		if err == nil && closeErr == nil {
+		// 只有在完整写入且文件正确同步关闭的情况下才提交缓存
+		if tempPath, size, digest, ok := cacheWriter.Finish(); ok {
 			// 异步提交缓存，不阻塞当前请求处理
-			fileName := cacheFile.Name()
 			respClone := *resp // 创建响应的浅拷贝
 			go func() {
-				s.cache.Commit(cacheKey, fileName, &respClone, written)
+				s.cache.Commit(cacheKey, tempPath, &respClone, size, digest)
 			}()
-		} else {
-			// 如果关闭失败，删除临时文件
-			if closeErr != nil {
-				cacheFile.Close() // 尝试再次关闭
-			}
 		}
 
-		return written, err
+		return written, nil
 	}
 
 	// 🚀 零拷贝优化: 使用 buffer pool 复用缓冲区
@@ -459,8 +624,8 @@ func (s *ProxyService) isConnectionClosed(err error) bool {
 
 // CreateLogEntry 创建访问日志条目
 func (s *ProxyService) CreateLogEntry(req *ProxyRequest, statusCode int, duration time.Duration, bytesWritten int64, targetURL string) string {
-	return fmt.Sprintf("[Proxy] %s %s -> %d (%s) %s from %s (target: %s)",
+	return fmt.Sprintf("[Proxy] %s %s -> %d (%s) %s from %s (target: %s, rid: %s)",
 		req.OriginalRequest.Method, req.OriginalRequest.URL.Path, statusCode,
-		iputil.GetClientIP(req.OriginalRequest), utils.FormatBytes(bytesWritten),
-		utils.GetRequestSource(req.OriginalRequest), targetURL)
+		security.ClientIP(req.OriginalRequest), utils.FormatBytes(bytesWritten),
+		utils.GetRequestSource(req.OriginalRequest), targetURL, req.OriginalRequest.Header.Get(utils.RequestIDHeader))
 }
@@ -172,7 +172,7 @@ func ExecuteWithRetry(client *http.Client, req *http.Request, config RetryConfig
 
 	// 返回最后一次的错误
 	log.Printf("[Retry] Max retries exceeded for %s: %v", req.URL.String(), lastErr)
-	return nil, fmt.Errorf("max retries exceeded: %v", lastErr)
+	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
 }
 
 // cloneRequest 克隆HTTP请求（处理请求体）
@@ -180,9 +180,13 @@ func cloneRequest(req *http.Request) *http.Request {
 	// 创建新的请求
 	reqClone := req.Clone(req.Context())
 
-	// 如果有请求体,需要特殊处理
-	// 注意: 这里假设请求体可以被多次读取（对于代理场景通常是true）
-	// 对于POST等请求,调用者需要确保Body支持重复读取
+	// 如果有请求体,需要特殊处理: 可重放的请求体 (已缓冲, 见 BufferBody) 每次重试取新的副本;
+	// 其它请求体只能读取一次, 对于POST等请求,调用者需要确保Body支持重复读取
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			reqClone.Body = body
+		}
+	}
 
 	return reqClone
 }
@@ -213,6 +213,9 @@ func cleanExpiredCache() {
 	})
 }
 
+// RequestIDHeader 请求 ID 头部: 入口处写入原始请求, 透传给源站并回显在响应与日志中
+const RequestIDHeader = "X-Request-ID"
+
 // GenerateRequestID 生成唯一的请求ID
 func GenerateRequestID() string {
 	b := make([]byte, 8)
@@ -223,6 +226,23 @@ func GenerateRequestID() string {
 	return hex.EncodeToString(b)
 }
 
+// maxRequestIDLen 沿用上游请求 ID 时允许的最大长度
+const maxRequestIDLen = 128
+
+// ValidRequestID 只接受可打印的 token 字符, 长度受限 (空串无效)
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
 // 获取请求来源
 func GetRequestSource(r *http.Request) string {
 	if r == nil {
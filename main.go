@@ -2,12 +2,16 @@ package main
 
 import (
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"proxy-go/internal/cache"
+	"proxy-go/internal/errorpage"
 	"proxy-go/internal/initapp"
 	"proxy-go/internal/metrics"
 	"proxy-go/internal/router"
+	"proxy-go/internal/security"
 	"proxy-go/pkg/sync"
 	"syscall"
 	"time"
@@ -48,7 +52,7 @@ func main() {
 		}
 
 		log.Printf("[Debug] 未找到处理器: %s", r.URL.Path)
-		http.NotFound(w, r)
+		errorpage.Render(w, r, "", http.StatusNotFound, errorpage.Info{})
 	})
 
 	// 构建中间件链
@@ -59,9 +63,15 @@ func main() {
 		handler = components.SecurityMiddleware.IPBanMiddleware(handler)
 	}
 
+	// 监听端口, 环境变量 PORT 可覆盖 (同机运行多个节点时使用)
+	addr := ":3336"
+	if port := os.Getenv("PORT"); port != "" {
+		addr = ":" + port
+	}
+
 	// 创建服务器
 	server := &http.Server{
-		Addr:    ":3336",
+		Addr:    addr,
 		Handler: handler,
 	}
 
@@ -72,6 +82,15 @@ func main() {
 		<-sigChan
 		log.Println("Shutting down server...")
 
+		// 持久化缓存索引 (含缓存标签), 重启后继续使用已有缓存
+		for _, cm := range []*cache.CacheManager{components.ProxyHandler.Cache, components.MirrorHandler.Cache} {
+			if cm != nil {
+				if err := cm.SaveIndex(); err != nil {
+					log.Printf("Error saving cache index: %v", err)
+				}
+			}
+		}
+
 		// 停止安全管理器
 		if components.BanManager != nil {
 			components.BanManager.Stop()
@@ -99,8 +118,18 @@ func main() {
 	}()
 
 	// 启动服务器
-	log.Println("Starting proxy server on :3336")
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatal("Error starting server:", err)
+	}
+	// L4 负载均衡之后: 解析 PROXY protocol 头, 只接受可信代理发出的协议头
+	if components.Config.Security.ProxyProtocol {
+		listener = security.NewProxyProtocolListener(listener, security.IsTrustedProxy)
+		log.Println("PROXY protocol enabled on listener")
+	}
+
+	log.Printf("Starting proxy server on %s", server.Addr)
+	if err := server.Serve(listener); err != http.ErrServerClosed {
 		log.Fatal("Error starting server:", err)
 	}
 }
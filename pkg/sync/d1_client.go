@@ -909,3 +909,73 @@ func (c *D1Client) BatchUpsertLatencyDistribution(ctx context.Context, metrics [
 
 	return nil
 }
+
+// CacheNode 对等缓存节点注册记录
+type CacheNode struct {
+	NodeID   string `json:"node_id"`
+	URL      string `json:"url"`       // 其他节点访问本节点的地址
+	LastSeen int64  `json:"last_seen"` // 最近一次心跳 (毫秒时间戳)
+}
+
+// UpsertCacheNode 写入本节点的注册记录 (心跳), 按 node_id 覆盖
+func (c *D1Client) UpsertCacheNode(ctx context.Context, node CacheNode) error {
+	reqData, err := json.Marshal(map[string]any{"node": node})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/nodes", c.endpoint)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(reqData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("D1 API error (status %d): %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// GetCacheNodes 拉取 last_seen >= since (毫秒) 的节点注册记录
+func (c *D1Client) GetCacheNodes(ctx context.Context, since int64) ([]CacheNode, error) {
+	url := fmt.Sprintf("%s/nodes?since=%d", c.endpoint, since)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("D1 API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Success bool        `json:"success"`
+		Data    []CacheNode `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return result.Data, nil
+}
@@ -175,6 +175,19 @@ var migrations = []Migration{
 			`ALTER TABLE config_maps ADD COLUMN extra_config TEXT`,
 		},
 	},
+	{
+		// cache_nodes 对等缓存节点注册表: 开启节点发现的节点定期写入自己的地址 (心跳),
+		// 其他节点按 last_seen 读取最近在线的节点组成一致性哈希环
+		ID: "0006_add_cache_nodes",
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS cache_nodes (
+				node_id TEXT PRIMARY KEY,
+				url TEXT NOT NULL,
+				last_seen INTEGER NOT NULL
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_cache_nodes_last_seen ON cache_nodes(last_seen)`,
+		},
+	},
 }
 
 // MigrationResult 启动期迁移汇总, 仅用于日志
@@ -410,3 +410,25 @@ func PruneRefererDaily(ctx context.Context, cutoffDate int64) (int, error) {
 	}
 	return globalSyncService.manager.storage.PruneRefererDaily(ctx, cutoffDate)
 }
+
+// RegisterCacheNode 向 D1 节点注册表写入本节点心跳
+func RegisterCacheNode(ctx context.Context, node CacheNode) error {
+	globalSyncMutex.RLock()
+	defer globalSyncMutex.RUnlock()
+
+	if globalSyncService == nil || !globalSyncService.isEnabled {
+		return nil
+	}
+	return globalSyncService.manager.storage.UpsertCacheNode(ctx, node)
+}
+
+// LoadCacheNodes 拉取最近在线 (last_seen >= since 毫秒) 的对等缓存节点; 同步未启用时返回空
+func LoadCacheNodes(ctx context.Context, since int64) ([]CacheNode, error) {
+	globalSyncMutex.RLock()
+	defer globalSyncMutex.RUnlock()
+
+	if globalSyncService == nil || !globalSyncService.isEnabled {
+		return nil, nil
+	}
+	return globalSyncService.manager.storage.GetCacheNodes(ctx, since)
+}